@@ -2,17 +2,32 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
-	"syscall"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"consumer-service/internal/config"
+	"consumer-service/internal/delivery/http/httpx"
+	"consumer-service/internal/domain"
 	"consumer-service/internal/infrastructure/kafka"
+	"consumer-service/internal/infrastructure/logging"
 	"consumer-service/internal/infrastructure/metrics"
+	"consumer-service/internal/infrastructure/repository"
+	"consumer-service/internal/infrastructure/sink"
 	"consumer-service/internal/usecase"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
@@ -27,74 +42,230 @@ func main() {
 		logger.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	// Под высокой нагрузкой debug/info логи семплируются, warn и error — нет
+	if cfg.Logging.SampleRate > 1 {
+		logger.SetFormatter(logging.NewSamplingFormatter(logger.Formatter, cfg.Logging.SampleRate))
+	}
+
+	// Лимит размера данных события действует для всей доменной валидации
+	domain.SetMaxDataLength(cfg.Event.MaxDataBytes)
+
 	logger.WithFields(logrus.Fields{
 		"app_name":    cfg.App.Name,
 		"version":     cfg.App.Version,
 		"environment": cfg.App.Environment,
 	}).Info("Starting consumer service")
 
-	// Инициализируем метрики
-	consumerMetrics := metrics.NewConsumerMetrics()
-
 	// Инициализируем обработчик событий
-	eventProcessor := usecase.NewEventProcessor(logger)
+	eventProcessor := usecase.NewEventProcessor(logger, domain.NewRedactor(cfg.Logging.RedactFields))
+
+	// Инициализируем хранилище результатов обработки
+	resultRepository := repository.NewMemoryRepository(cfg.Consumer.ResultsCapacity)
+
+	// Consumer group'ы: по умолчанию одна KAFKA_GROUP_ID. У каждой группы
+	// свои worker'ы, метрики (с меткой group) и webhook sink.
+	groups := cfg.Consumer.Groups
+	if len(groups) == 0 {
+		groups = []string{cfg.Kafka.GroupID}
+	}
 
-	// Инициализируем Kafka consumer
-	kafkaConsumer, err := kafka.NewConsumer(cfg.Kafka, cfg.Consumer, eventProcessor, logger, consumerMetrics)
+	headerLabels, err := kafka.HeaderLabelNames(cfg.Consumer.LabelHeaders)
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to create Kafka consumer")
+		logger.WithError(err).Fatal("Invalid CONSUMER_LABEL_HEADERS")
 	}
-	defer func() {
-		if err := kafkaConsumer.Close(); err != nil {
-			logger.WithError(err).Error("Failed to close Kafka consumer")
+
+	// Внешнее хранилище offset'ов общее для всех групп: записи различаются группой и топиком
+	var offsetStore kafka.OffsetStore
+	if cfg.Kafka.OffsetStorePath != "" {
+		offsetStore, err = repository.NewFileOffsetStore(cfg.Kafka.OffsetStorePath)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open offset store")
 		}
-	}()
+	}
+
+	kafkaConsumer := kafka.NewConsumerManager(logger)
+	var resultSinks []domain.ResultSink
+	for _, group := range groups {
+		registerer := prometheus.DefaultRegisterer
+		if len(groups) > 1 {
+			registerer = prometheus.WrapRegistererWith(prometheus.Labels{"group": group}, registerer)
+		}
+		consumerMetrics := metrics.NewConsumerMetrics(registerer, headerLabels...)
+
+		// Отправка результатов обработки на внешний webhook (если задан URL)
+		var resultSink domain.ResultSink
+		if cfg.Webhook.URL != "" {
+			webhookSink, err := sink.NewWebhookResultSink(cfg.Webhook, logger, consumerMetrics)
+			if err != nil {
+				logger.WithError(err).Fatal("Failed to create webhook result sink")
+			}
+			resultSink = webhookSink
+			resultSinks = append(resultSinks, webhookSink)
+		}
+
+		kafkaCfg := cfg.Kafka
+		kafkaCfg.GroupID = group
+		groupConsumer, err := kafka.NewConsumer(kafkaCfg, cfg.Consumer, eventProcessor, resultRepository, resultSink, logger, consumerMetrics)
+		if err != nil {
+			logger.WithError(err).WithField("group_id", group).Fatal("Failed to create Kafka consumer")
+		}
+		if offsetStore != nil {
+			groupConsumer.SetOffsetStore(offsetStore, cfg.Kafka.OffsetStoreExclusive)
+		}
+		kafkaConsumer.Add(group, groupConsumer)
+	}
 
 	// Запускаем метрики сервер если включен
+	var metricsServer *http.Server
 	if cfg.Metrics.Enabled {
-		go startMetricsServer(cfg.Metrics, logger)
+		metricsServer = newMetricsServer(cfg, resultRepository, kafkaConsumer, logger)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.WithError(err).Error("Metrics server failed")
+			}
+		}()
 	}
 
 	// Создаем контекст для graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Ждем появления топика перед началом чтения
+	if err := kafkaConsumer.WaitForTopic(ctx); err != nil {
+		logger.WithError(err).Fatal("Kafka topic is not available")
+	}
+
 	// Запускаем consumer в горутине
+	consumerDone := make(chan struct{})
+	consumerErr := make(chan error, 1)
 	go func() {
+		defer close(consumerDone)
 		logger.Info("Starting Kafka consumer")
 		if err := kafkaConsumer.Start(ctx); err != nil {
 			if err != context.Canceled {
 				logger.WithError(err).Error("Kafka consumer failed")
+				consumerErr <- err
 			}
 		}
 	}()
 
 	// Graceful shutdown
+	// SIGHUP (перезагрузка) игнорируется, чтобы не завершать процесс по умолчанию
+	shutdownSignals, _ := config.ParseSignals(cfg.App.ShutdownSignals) // проверено в config.Load
+	signal.Ignore(config.ReloadSignal)
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, shutdownSignals...)
 
-	logger.Info("Shutting down consumer service...")
+	// Подписанная команда shutdown в управляющем топике останавливает сервис
+	// через Drain: чтение прекращается, прочитанные сообщения дообрабатываются
+	// и коммитятся, и только затем сервис останавливается как по сигналу ОС
+	var controlListener *kafka.ControlListener
+	var drain <-chan kafka.ControlCommand
+	if cfg.Control.Topic != "" {
+		controlListener, err = kafka.NewControlListener(cfg.Kafka.Brokers, cfg.Control, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to create control topic listener")
+		}
 
-	// Отменяем контекст для остановки consumer
-	cancel()
+		commands := make(chan kafka.ControlCommand, 1)
+		drain = commands
+		go func() {
+			command, err := controlListener.Listen(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.WithError(err).Error("Control topic listener stopped")
+				}
+				return
+			}
+			commands <- command
+		}()
+	}
+
+	// В режиме backfill consumer завершается сам после обработки диапазона
+	var finished <-chan struct{}
+	if cfg.Consumer.Backfill.Enabled {
+		finished = consumerDone
+	}
+
+	// Фатальная ошибка consumer'а завершает процесс с ненулевым кодом,
+	// чтобы оркестратор перезапустил его
+	exitCode := 0
+	draining := false
+	select {
+	case <-quit:
+	case command := <-drain:
+		logger.WithFields(logrus.Fields{
+			"issued_at": command.IssuedAt,
+			"reason":    command.Reason,
+		}).Info("Shutdown requested via control topic")
+		draining = true
+	case <-finished:
+		logger.Info("Backfill finished")
+	case <-consumerErr:
+		exitCode = 1
+	}
+
+	logger.Info("Shutting down consumer service...")
 
-	// Даем время на graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Останавливаем компоненты по очереди в пределах общего таймаута
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
 	defer shutdownCancel()
 
-	// Ждем завершения с таймаутом
+	var steps []shutdownStep
+	if draining {
+		steps = append(steps, shutdownStep{name: "kafka consumer drain", stop: kafkaConsumer.Drain})
+	}
+	steps = append(steps, shutdownStep{name: "kafka consumer", stop: func(context.Context) error {
+		// Отменяем контекст для остановки consumer
+		cancel()
+		return kafkaConsumer.Close()
+	}})
+	if controlListener != nil {
+		steps = append(steps, shutdownStep{name: "control listener", stop: func(context.Context) error { return controlListener.Close() }})
+	}
+	for _, resultSink := range resultSinks {
+		steps = append(steps, shutdownStep{name: "result sink", stop: func(context.Context) error { return resultSink.Close() }})
+	}
+	if metricsServer != nil {
+		steps = append(steps, shutdownStep{name: "metrics server", stop: metricsServer.Shutdown})
+	}
+
+	runShutdown(shutdownCtx, steps, logger, os.Exit)
+
+	if exitCode != 0 {
+		logger.Error("Consumer service stopped after consumer failure")
+		os.Exit(exitCode)
+	}
+	logger.Info("Consumer service exited gracefully")
+}
+
+// shutdownStep шаг остановки сервиса
+type shutdownStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// runShutdown выполняет шаги остановки последовательно. Если общий таймаут
+// ctx истек, логирует незавершенный шаг и вызывает exit(1), не дожидаясь его.
+func runShutdown(ctx context.Context, steps []shutdownStep, logger *logrus.Logger, exit func(code int)) {
+	var current atomic.Value
 	done := make(chan struct{})
+
 	go func() {
 		defer close(done)
-		// Consumer уже получит сигнал через отмененный контекст
+		for _, step := range steps {
+			current.Store(step.name)
+			if err := step.stop(ctx); err != nil {
+				logger.WithError(err).WithField("component", step.name).Error("Failed to stop component")
+			}
+		}
 	}()
 
 	select {
 	case <-done:
-		logger.Info("Consumer service exited gracefully")
-	case <-shutdownCtx.Done():
-		logger.Warn("Consumer service shutdown timeout exceeded")
+	case <-ctx.Done():
+		logger.WithField("component", current.Load()).Error("Consumer service shutdown timeout exceeded, forcing exit")
+		exit(1)
 	}
 }
 
@@ -130,32 +301,182 @@ func setupLogger() *logrus.Logger {
 	return logger
 }
 
-// startMetricsServer запускает отдельный сервер для метрик
-func startMetricsServer(cfg config.MetricsConfig, logger *logrus.Logger) {
+// consumerStatus состояние consumer group'ов для /ready и /stats
+type consumerStatus interface {
+	Ready() bool
+	Stats() domain.ConsumerStats
+	GroupStats() map[string]domain.ConsumerStats
+}
+
+// newMetricsServer создает отдельный сервер для метрик, health check и статистики
+func newMetricsServer(appCfg *config.Config, results domain.EventRepository, consumer consumerStatus, logger *logrus.Logger) *http.Server {
+	cfg, debugCfg := appCfg.Metrics, appCfg.Debug
+	startedAt := time.Now()
+
 	metricsPath := "/metrics"
 	healthPath := "/health"
+	readyPath := "/ready"
+	statsPath := "/stats"
 
 	mux := http.NewServeMux()
 	mux.Handle(metricsPath, promhttp.Handler())
 
 	// Health check endpoint
 	mux.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
+		response := healthResponse{
+			Status:    "healthy",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Service:   appCfg.App.Name,
+			Version:   appCfg.App.Version,
+		}
+		if appCfg.Health.Verbose {
+			response.Uptime = time.Since(startedAt).Round(time.Second).String()
+			response.Commit = buildCommit()
+			response.GoVersion = runtime.Version()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	})
+
+	// Readiness: 503, пока consumer не подтвердил связь с брокером
+	mux.HandleFunc(readyPath, func(w http.ResponseWriter, r *http.Request) {
+		status, code := "ready", http.StatusOK
+		if !consumer.Ready() {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":    status,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"service":   appCfg.App.Name,
+		})
+	})
+
+	// Статистика чтения и последние неудачные результаты обработки
+	mux.HandleFunc(statsPath, func(w http.ResponseWriter, r *http.Request) {
+		failed := false
+		failures, err := results.GetResults(r.Context(), domain.ResultFilter{Success: &failed, Limit: 100})
+		if err != nil {
+			httpx.WriteError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get results")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"consumer":        consumer.Stats(),
+			"groups":          consumer.GroupStats(),
+			"recent_failures": failures,
+		})
 	})
 
+	if debugCfg.PprofEnabled {
+		registerPprof(mux, allowlist(debugCfg.PprofAllowedIPs))
+		logger.WithField("allowed_ips", debugCfg.PprofAllowedIPs).Warn("pprof endpoints enabled")
+	}
+
 	srv := &http.Server{
 		Addr:    cfg.Port,
-		Handler: mux,
+		Handler: withRequestID(mux),
 	}
 
 	logger.WithFields(logrus.Fields{
 		"address":      cfg.Port,
 		"metrics_path": metricsPath,
 		"health_path":  healthPath,
+		"ready_path":   readyPath,
+		"stats_path":   statsPath,
 	}).Info("Metrics server starting")
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.WithError(err).Error("Metrics server failed")
+	return srv
+}
+
+// healthResponse ответ health check; расширенные поля заполняются при HEALTH_VERBOSE=true
+type healthResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Service   string `json:"service"`
+	Version   string `json:"version"`
+	Uptime    string `json:"uptime,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+}
+
+// buildCommit возвращает ревизию VCS из информации о сборке
+func buildCommit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return "unknown"
+}
+
+// registerPprof регистрирует обработчики net/http/pprof под /debug/pprof/
+func registerPprof(mux *http.ServeMux, guard func(http.Handler) http.Handler) {
+	mux.Handle("/debug/pprof/", guard(http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", guard(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", guard(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", guard(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", guard(http.HandlerFunc(pprof.Trace)))
+}
+
+// requestIDHeader заголовок с идентификатором запроса, как в producer-service
+const requestIDHeader = "X-Request-ID"
+
+// withRequestID берет X-Request-ID из запроса или генерирует новый, возвращает
+// его в ответе и кладет в контекст как correlation ID: из него httpx.WriteError
+// заполняет trace_id
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := strings.TrimSpace(r.Header.Get(requestIDHeader))
+		if requestID == "" {
+			requestID = generateRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(domain.WithCorrelationID(r.Context(), requestID)))
+	})
+}
+
+// generateRequestID генерирует случайный идентификатор запроса
+func generateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// allowlist пропускает только запросы с разрешенных IP адресов
+func allowlist(allowedIPs []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(allowedIPs))
+	for _, ip := range allowedIPs {
+		allowed[strings.TrimSpace(ip)] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			if _, ok := allowed[host]; !ok {
+				httpx.WriteError(w, r, http.StatusForbidden, "FORBIDDEN", "Access from this address is not allowed")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
 	}
 }
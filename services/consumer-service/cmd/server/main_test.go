@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/delivery/http/httpx"
+	"consumer-service/internal/domain"
+	"consumer-service/internal/infrastructure/kafka"
+	"consumer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRunShutdown(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	const limit = 50 * time.Millisecond
+	tests := []struct {
+		name        string
+		slow        bool
+		wantStopped []string
+		wantExit    []int
+	}{
+		{name: "all components stop", wantStopped: []string{"consumer", "sink", "metrics"}},
+		{name: "slow component forces exit", slow: true, wantStopped: []string{"consumer"}, wantExit: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var stopped []string
+			stop := func(name string) shutdownStep {
+				return shutdownStep{name: name, stop: func(context.Context) error {
+					mu.Lock()
+					defer mu.Unlock()
+					stopped = append(stopped, name)
+					return nil
+				}}
+			}
+
+			// Медленный компонент не смотрит на ctx и не завершается до конца теста
+			release := make(chan struct{})
+			defer close(release)
+			sink := stop("sink")
+			if tt.slow {
+				sink.stop = func(context.Context) error { <-release; return nil }
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), limit)
+			defer cancel()
+
+			var exits []int
+			start := time.Now()
+			runShutdown(ctx, []shutdownStep{stop("consumer"), sink, stop("metrics")}, logger, func(code int) {
+				exits = append(exits, code)
+			})
+			if elapsed := time.Since(start); elapsed > limit+time.Second {
+				t.Fatalf("shutdown took %v, cap %v", elapsed, limit)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(stopped, tt.wantStopped) {
+				t.Fatalf("stopped %v, want %v", stopped, tt.wantStopped)
+			}
+			if !reflect.DeepEqual(exits, tt.wantExit) {
+				t.Fatalf("exit codes %v, want %v", exits, tt.wantExit)
+			}
+		})
+	}
+}
+
+// failingResults репозиторий результатов, который всегда возвращает ошибку
+type failingResults struct{}
+
+func (failingResults) SaveResult(context.Context, *domain.ProcessingResult) error { return nil }
+
+func (failingResults) GetResults(context.Context, domain.ResultFilter) ([]*domain.ProcessingResult, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+// idleConsumer состояние consumer'а без групп
+type idleConsumer struct{}
+
+func (idleConsumer) Ready() bool                                 { return true }
+func (idleConsumer) Stats() domain.ConsumerStats                 { return domain.ConsumerStats{} }
+func (idleConsumer) GroupStats() map[string]domain.ConsumerStats { return nil }
+
+func TestMetricsServerErrorTraceID(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.Config{Debug: config.DebugConfig{PprofEnabled: true, PprofAllowedIPs: []string{"127.0.0.1"}}}
+	handler := newMetricsServer(cfg, failingResults{}, idleConsumer{}, logger).Handler
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		requestID  string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "request ID propagated", path: "/stats", requestID: "req-42", wantStatus: http.StatusInternalServerError, wantCode: "INTERNAL_ERROR"},
+		{name: "request ID generated", path: "/stats", wantStatus: http.StatusInternalServerError, wantCode: "INTERNAL_ERROR"},
+		{name: "pprof allowlist", path: "/debug/pprof/", remoteAddr: "192.0.2.1:4321", requestID: "req-43", wantStatus: http.StatusForbidden, wantCode: "FORBIDDEN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.requestID != "" {
+				req.Header.Set(requestIDHeader, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var resp httpx.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode error response: %v", err)
+			}
+			// trace_id совпадает с ID запроса в ответе, переданным или сгенерированным
+			traceID := rec.Header().Get(requestIDHeader)
+			if traceID == "" || resp.TraceID != traceID || (tt.requestID != "" && traceID != tt.requestID) {
+				t.Fatalf("trace_id = %q, X-Request-ID = %q, want both %q", resp.TraceID, traceID, tt.requestID)
+			}
+			if resp.Code != tt.wantCode || resp.Error != http.StatusText(tt.wantStatus) {
+				t.Fatalf("error response = %+v, want code %s", resp, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMetricsServerPprof(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		path       string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "index enabled", enabled: true, path: "/debug/pprof/", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "named profile enabled", enabled: true, path: "/debug/pprof/goroutine", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "cmdline enabled", enabled: true, path: "/debug/pprof/cmdline", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "address outside allowlist", enabled: true, path: "/debug/pprof/", remoteAddr: "192.0.2.1:5000", wantStatus: http.StatusForbidden},
+		{name: "index disabled", path: "/debug/pprof/", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusNotFound},
+		{name: "cmdline disabled", path: "/debug/pprof/cmdline", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Debug: config.DebugConfig{PprofEnabled: tt.enabled, PprofAllowedIPs: []string{"127.0.0.1"}}}
+			handler := newMetricsServer(cfg, failingResults{}, idleConsumer{}, logger).Handler
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// unavailableReader отклоняет чтение, пока не закрыт available, затем
+// отдает одно сообщение и ждет отмены контекста
+type unavailableReader struct {
+	available chan struct{}
+	attempts  atomic.Int64
+	delivered atomic.Bool
+}
+
+func (r *unavailableReader) ReadMessage(ctx context.Context) (kafkago.Message, error) {
+	r.attempts.Add(1)
+	select {
+	case <-r.available:
+	default:
+		return kafkago.Message{}, errors.New("kafka: broker not available")
+	}
+	if r.delivered.CompareAndSwap(false, true) {
+		return kafkago.Message{Topic: "events", Value: []byte(`{}`)}, nil
+	}
+	<-ctx.Done()
+	return kafkago.Message{}, ctx.Err()
+}
+
+func (r *unavailableReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	return r.ReadMessage(ctx)
+}
+
+func (r *unavailableReader) CommitMessages(context.Context, ...kafkago.Message) error { return nil }
+
+func (r *unavailableReader) Stats() kafkago.ReaderStats { return kafkago.ReaderStats{} }
+
+func (r *unavailableReader) Close() error { return nil }
+
+// nopProcessor принимает любое событие
+type nopProcessor struct{}
+
+func (nopProcessor) ProcessEvent(context.Context, *domain.Event) error { return nil }
+
+func TestReadyWaitsForFirstRead(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	consumer, err := kafka.NewConsumer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", ReadTimeout: time.Minute, RetryBackoff: time.Millisecond, CloseTimeout: time.Second},
+		config.ConsumerConfig{WorkerCount: 1, BatchSize: 1},
+		nopProcessor{}, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+	)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	reader := &unavailableReader{available: make(chan struct{})}
+	consumer.SetReader(reader)
+	manager := kafka.NewConsumerManager(logger)
+	manager.Add("test", consumer)
+	handler := newMetricsServer(&config.Config{}, failingResults{}, manager, logger).Handler
+
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return rec.Code
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErr := make(chan error, 1)
+	go func() { startErr <- manager.Start(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-startErr; err != nil {
+			t.Errorf("Start: %v", err)
+		}
+		manager.Close()
+	}()
+
+	// Несколько неудачных попыток чтения не делают consumer готовым
+	for reader.attempts.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if status := ready(); status != http.StatusServiceUnavailable {
+		t.Fatalf("/ready before the first read = %d, want 503", status)
+	}
+
+	close(reader.available)
+	deadline := time.Now().Add(5 * time.Second)
+	for ready() != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("/ready still 503 after the first successful read")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// errNoBrokers в KAFKA_BROKER_LIST нет ни одного адреса
+var errNoBrokers = errors.New("KAFKA_BROKER_LIST must contain at least one broker address")
+
+// normalizeBrokers убирает пробелы вокруг адресов и пустые элементы списка.
+// cleanenv делит значение по запятым как есть, поэтому "a, b" дает " b",
+// а ",," — список пустых строк, который проходит проверку длины, но
+// приводит к невнятной ошибке подключения.
+func normalizeBrokers(brokers []string) ([]string, error) {
+	normalized := make([]string, 0, len(brokers))
+	for _, broker := range brokers {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			normalized = append(normalized, broker)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, errNoBrokers
+	}
+	return normalized, nil
+}
@@ -13,6 +13,11 @@ type Config struct {
 	Consumer ConsumerConfig `env-prefix:"CONSUMER_"`
 	Logging  LoggingConfig  `env-prefix:"LOG_"`
 	Metrics  MetricsConfig  `env-prefix:"METRICS_"`
+	Debug    DebugConfig    `env-prefix:"PPROF_"`
+	Event    EventConfig    `env-prefix:"EVENT_"`
+	Webhook  WebhookConfig  `env-prefix:"RESULT_WEBHOOK_"`
+	Health   HealthConfig   `env-prefix:"HEALTH_"`
+	Control  ControlConfig  `env-prefix:"CONTROL_"`
 	App      AppConfig      `env-prefix:"APP_"`
 }
 
@@ -29,18 +34,133 @@ type KafkaConfig struct {
 	StartOffset    string        `env:"START_OFFSET" env-default:"latest"`
 	MaxRetries     int           `env:"MAX_RETRIES" env-default:"3"`
 	RetryBackoff   time.Duration `env:"RETRY_BACKOFF" env-default:"100ms"`
+	CloseTimeout   time.Duration `env:"CLOSE_TIMEOUT" env-default:"10s"`
+
+	// Опрос пустого топика: таймаут чтения (0 — MaxWait*2) и предел паузы между опросами (0 — без паузы)
+	ReadTimeout    time.Duration `env:"READ_TIMEOUT" env-default:"0s"`
+	IdleBackoffMax time.Duration `env:"IDLE_BACKOFF_MAX" env-default:"0s"`
+
+	// Ожидание и автосоздание топика при старте
+	TopicWaitTimeout       time.Duration `env:"TOPIC_WAIT_TIMEOUT" env-default:"60s"`
+	AutoCreateTopic        bool          `env:"AUTO_CREATE_TOPIC" env-default:"false"`
+	TopicPartitions        int           `env:"TOPIC_PARTITIONS" env-default:"3"`
+	TopicReplicationFactor int           `env:"TOPIC_REPLICATION_FACTOR" env-default:"1"`
+
+	// Маршрутизация в DLQ по типу события, например "payment_processed:dlq-payments,default:dlq";
+	// запись default обязательна
+	DLQTopics string `env:"DLQ_TOPICS" env-default:""`
+
+	// Выходной топик stream-этапа: события, возвращенные EventTransformer,
+	// публикуются в него до коммита входного сообщения. Пусто — этап отключен.
+	// Требует CONSUMER_COMMIT_MODE=batch (и, следовательно, KAFKA_DLQ_TOPICS).
+	OutputTopic string `env:"OUTPUT_TOPIC" env-default:""`
+
+	// Внешнее хранилище offset'ов: JSON-файл, из которого группа продолжает
+	// чтение при старте. Пусто — offset'ы хранит только Kafka. При
+	// OffsetStoreExclusive offset'ы после старта в Kafka не коммитятся.
+	OffsetStorePath      string `env:"OFFSET_STORE_PATH" env-default:""`
+	OffsetStoreExclusive bool   `env:"OFFSET_STORE_EXCLUSIVE" env-default:"false"`
 }
 
 // ConsumerConfig содержит конфигурацию обработки сообщений
 type ConsumerConfig struct {
-	WorkerCount int `env:"WORKER_COUNT" env-default:"10"`
-	BatchSize   int `env:"BATCH_SIZE" env-default:"100"`
+	WorkerCount     int `env:"WORKER_COUNT" env-default:"10"`
+	BatchSize       int `env:"BATCH_SIZE" env-default:"100"`
+	ResultsCapacity int `env:"RESULTS_CAPACITY" env-default:"1000"`
+
+	// Политика для неизвестных полей события: ignore, warn (лог и метрика) или reject (в DLQ)
+	UnknownFields string `env:"UNKNOWN_FIELDS" env-default:"ignore"`
+
+	// Пропуск Event.Validate для доверенных внутренних топиков. JSON по-прежнему
+	// разбирается, но события с неверной схемой дойдут до обработчика: включать
+	// только для топиков, куда пишут сервисы, уже валидирующие события.
+	SkipValidation bool `env:"SKIP_VALIDATION" env-default:"false"`
+
+	// Строгий порядок: один worker обрабатывает сообщения последовательно в порядке
+	// чтения (WORKER_COUNT игнорируется), батчевый коммит сохраняется
+	StrictOrder bool `env:"STRICT_ORDER" env-default:"false"`
+
+	// Веса типов событий при разборе бэклога, например "payment_processed:5,user_created:1,default:1".
+	// Пусто — общая очередь в порядке чтения. Несовместимо с STRICT_ORDER.
+	TypePriorities string `env:"TYPE_PRIORITIES" env-default:""`
+
+	// JSON Lines: сообщение с несколькими строками обрабатывается как набор
+	// событий, по одному на строку; однострочные сообщения обрабатываются как обычно
+	JSONLines bool `env:"JSON_LINES" env-default:"false"`
+
+	// Несколько consumer group в одном процессе (fan-out), например "billing,analytics".
+	// Пусто — одна группа KAFKA_GROUP_ID. Метрики групп различаются меткой group.
+	Groups []string `env:"GROUPS" env-default:""`
+
+	// Режим коммита: message или batch (all-or-nothing для транзакционных downstream'ов)
+	CommitMode string `env:"COMMIT_MODE" env-default:"message"`
+
+	// Срок жизни события: более старые события при разборе бэклога коммитятся
+	// без обработки, чтобы downstream не действовал по устаревшим данным. 0 — без ограничения.
+	EventTTL time.Duration `env:"EVENT_TTL" env-default:"0s"`
+
+	// Метка tenant для метрик: значение заголовка TenantHeader, если оно в allowlist,
+	// иначе "other". Пустой allowlist отключает метку (ограничение кардинальности).
+	TenantHeader    string   `env:"TENANT_HEADER" env-default:"tenant"`
+	TenantAllowlist []string `env:"TENANT_ALLOWLIST" env-default:""`
+
+	// Заголовки сообщений, переносимые в метки consumer_events_consumed_total
+	// (например, "region,channel"). На каждый заголовок не больше
+	// LabelHeaderMaxValues различных значений, остальные — "other".
+	LabelHeaders         []string `env:"LABEL_HEADERS" env-default:""`
+	LabelHeaderMaxValues int      `env:"LABEL_HEADER_MAX_VALUES" env-default:"20"`
+
+	// Детектор отставания: интервал сравнения скоростей поступления и обработки
+	// и число интервалов роста лага до сигнала
+	LagCheckInterval  time.Duration `env:"LAG_CHECK_INTERVAL" env-default:"10s"`
+	LagCheckIntervals int           `env:"LAG_CHECK_INTERVALS" env-default:"3"`
+
+	// Период выборки утилизации worker'ов (доля времени на обработку); 0 — метрика не обновляется
+	UtilizationInterval time.Duration `env:"UTILIZATION_INTERVAL" env-default:"10s"`
+
+	// Прогрев после старта: consumer_starting=1 до первого коммита, но не дольше
+	// WarmupPeriod; алерты на лаг и ошибки в это время подавляются. 0 — без прогрева.
+	WarmupPeriod time.Duration `env:"WARMUP_PERIOD" env-default:"2m"`
+
+	Backfill BackfillConfig `env-prefix:"BACKFILL_"`
+}
+
+// Режимы коммита offset'ов
+const (
+	// CommitModeMessage коммитит каждое успешно обработанное сообщение
+	CommitModeMessage = "message"
+	// CommitModeBatch коммитит batch (CONSUMER_BATCH_SIZE сообщений или все
+	// обработанное за KAFKA_COMMIT_INTERVAL) целиком: сообщения с ошибкой
+	// обрабатываются повторно, оставшиеся уходят в DLQ, и только затем
+	// коммитится весь batch. Требует KAFKA_DLQ_TOPICS
+	CommitModeBatch = "batch"
+)
+
+// Политики обработки неизвестных полей события
+const (
+	UnknownFieldsIgnore = "ignore"
+	UnknownFieldsWarn   = "warn"
+	UnknownFieldsReject = "reject"
+)
+
+// BackfillConfig содержит настройки чтения диапазона offset'ов одной партиции
+type BackfillConfig struct {
+	Enabled     bool  `env:"ENABLED" env-default:"false"`
+	Partition   int   `env:"PARTITION" env-default:"0"`
+	StartOffset int64 `env:"START_OFFSET" env-default:"0"`
+	EndOffset   int64 `env:"END_OFFSET" env-default:"0"`
 }
 
 // LoggingConfig содержит конфигурацию логирования
 type LoggingConfig struct {
 	Level  string `env:"LEVEL" env-default:"info"`
 	Format string `env:"FORMAT" env-default:"json"`
+
+	// Семплирование debug/info: выводится 1 строка из SampleRate (1 — без семплирования)
+	SampleRate int `env:"SAMPLE_RATE" env-default:"1"`
+
+	// Поля JSON в data событий, значения которых скрываются в логах (например, "email,ssn")
+	RedactFields []string `env:"REDACT_FIELDS" env-default:""`
 }
 
 // MetricsConfig содержит конфигурацию метрик
@@ -49,12 +169,60 @@ type MetricsConfig struct {
 	Port    string `env:"PORT" env-default:":9090"`
 }
 
+// EventConfig содержит ограничения на принимаемые события
+type EventConfig struct {
+	MaxDataBytes int `env:"MAX_DATA_BYTES" env-default:"10000"`
+}
+
+// WebhookConfig содержит настройки отправки результатов обработки на webhook.
+// Пустой URL отключает отправку.
+type WebhookConfig struct {
+	URL          string        `env:"URL" env-default:""`
+	AuthHeader   string        `env:"AUTH_HEADER" env-default:""`
+	QueueSize    int           `env:"QUEUE_SIZE" env-default:"1000"`
+	Timeout      time.Duration `env:"TIMEOUT" env-default:"5s"`
+	MaxRetries   int           `env:"MAX_RETRIES" env-default:"3"`
+	RetryBackoff time.Duration `env:"RETRY_BACKOFF" env-default:"500ms"`
+}
+
+// HealthConfig содержит настройки health check эндпоинта
+type HealthConfig struct {
+	Verbose bool `env:"VERBOSE" env-default:"false"`
+}
+
+// ControlConfig содержит настройки управляющего топика. Подписанная команда
+// shutdown в нем останавливает чтение, после чего прочитанные сообщения
+// дообрабатываются и коммитятся, а сервис завершается.
+// Пустой Topic отключает прослушивание.
+type ControlConfig struct {
+	Topic     string `env:"TOPIC" env-default:""`
+	Partition int    `env:"PARTITION" env-default:"0"`
+
+	// Ключ HMAC-SHA256 для проверки подписи команд (заголовок signature, hex)
+	Secret string `env:"SECRET" env-default:""`
+
+	// Команды старше MaxAge (или из будущего больше чем на MaxAge) отклоняются
+	MaxAge time.Duration `env:"MAX_AGE" env-default:"5m"`
+}
+
+// DebugConfig содержит настройки отладочных эндпоинтов
+type DebugConfig struct {
+	PprofEnabled    bool     `env:"ENABLED" env-default:"false"`
+	PprofAllowedIPs []string `env:"ALLOWED_IPS" env-default:"127.0.0.1,::1"`
+}
+
 // AppConfig содержит общие настройки приложения
 type AppConfig struct {
 	Name        string `env:"NAME" env-default:"consumer-service"`
 	Version     string `env:"VERSION" env-default:"1.0.0"`
 	Environment string `env:"ENV" env-default:"development"`
 	Debug       bool   `env:"DEBUG" env-default:"false"`
+
+	// Общий предел времени остановки сервиса; по истечении процесс завершается принудительно
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" env-default:"30s"`
+
+	// Сигналы graceful остановки; SIGHUP зарезервирован под перезагрузку конфигурации
+	ShutdownSignals []string `env:"SHUTDOWN_SIGNALS" env-default:"SIGINT,SIGTERM"`
 }
 
 // Load загружает и валидирует конфигурацию из переменных окружения
@@ -65,5 +233,98 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	brokers, err := normalizeBrokers(cfg.Kafka.Brokers)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Kafka.Brokers = brokers
+
+	if bf := cfg.Consumer.Backfill; bf.Enabled {
+		if bf.Partition < 0 || bf.StartOffset < 0 || bf.EndOffset < bf.StartOffset {
+			return nil, fmt.Errorf("invalid backfill range: partition=%d start=%d end=%d",
+				bf.Partition, bf.StartOffset, bf.EndOffset)
+		}
+	}
+
+	// Событие максимального размера должно помещаться в один fetch
+	if cfg.Event.MaxDataBytes <= 0 {
+		return nil, fmt.Errorf("EVENT_MAX_DATA_BYTES must be positive, got %d", cfg.Event.MaxDataBytes)
+	}
+	if cfg.Kafka.MaxBytes < 2*cfg.Event.MaxDataBytes {
+		return nil, fmt.Errorf("KAFKA_MAX_BYTES (%d) must be at least twice EVENT_MAX_DATA_BYTES (%d)",
+			cfg.Kafka.MaxBytes, cfg.Event.MaxDataBytes)
+	}
+
+	switch cfg.Consumer.UnknownFields {
+	case UnknownFieldsIgnore, UnknownFieldsWarn, UnknownFieldsReject:
+	default:
+		return nil, fmt.Errorf("invalid unknown fields policy: %q", cfg.Consumer.UnknownFields)
+	}
+
+	if len(cfg.Consumer.LabelHeaders) > 0 && cfg.Consumer.LabelHeaderMaxValues <= 0 {
+		return nil, fmt.Errorf("CONSUMER_LABEL_HEADER_MAX_VALUES must be positive, got %d", cfg.Consumer.LabelHeaderMaxValues)
+	}
+
+	seenGroups := make(map[string]struct{}, len(cfg.Consumer.Groups))
+	for _, group := range cfg.Consumer.Groups {
+		if _, dup := seenGroups[group]; dup || group == "" {
+			return nil, fmt.Errorf("invalid CONSUMER_GROUPS: empty or duplicate group %q", group)
+		}
+		seenGroups[group] = struct{}{}
+	}
+	if len(cfg.Consumer.Groups) > 1 && cfg.Consumer.Backfill.Enabled {
+		return nil, fmt.Errorf("backfill mode supports a single consumer group")
+	}
+
+	if cfg.Kafka.OutputTopic != "" && cfg.Kafka.OutputTopic == cfg.Kafka.Topic {
+		return nil, fmt.Errorf("KAFKA_OUTPUT_TOPIC must differ from KAFKA_TOPIC")
+	}
+	if cfg.Kafka.OutputTopic != "" && cfg.Consumer.CommitMode != CommitModeBatch {
+		return nil, fmt.Errorf("KAFKA_OUTPUT_TOPIC requires CONSUMER_COMMIT_MODE=batch")
+	}
+
+	if cfg.Kafka.OffsetStoreExclusive && cfg.Kafka.OffsetStorePath == "" {
+		return nil, fmt.Errorf("KAFKA_OFFSET_STORE_EXCLUSIVE requires KAFKA_OFFSET_STORE_PATH")
+	}
+
+	if cfg.Consumer.WorkerCount <= 0 {
+		return nil, fmt.Errorf("CONSUMER_WORKER_COUNT must be positive, got %d", cfg.Consumer.WorkerCount)
+	}
+	if cfg.Consumer.BatchSize <= 0 {
+		return nil, fmt.Errorf("CONSUMER_BATCH_SIZE must be positive, got %d", cfg.Consumer.BatchSize)
+	}
+
+	switch cfg.Consumer.CommitMode {
+	case CommitModeMessage, CommitModeBatch:
+	default:
+		return nil, fmt.Errorf("invalid commit mode: %q", cfg.Consumer.CommitMode)
+	}
+	if cfg.Consumer.CommitMode == CommitModeBatch && cfg.Kafka.DLQTopics == "" {
+		return nil, fmt.Errorf("CONSUMER_COMMIT_MODE=batch requires KAFKA_DLQ_TOPICS")
+	}
+
+	if cfg.Consumer.StrictOrder && cfg.Consumer.TypePriorities != "" {
+		return nil, fmt.Errorf("CONSUMER_STRICT_ORDER and CONSUMER_TYPE_PRIORITIES are mutually exclusive")
+	}
+
+	if _, err := ParseSignals(cfg.App.ShutdownSignals); err != nil {
+		return nil, fmt.Errorf("invalid APP_SHUTDOWN_SIGNALS: %w", err)
+	}
+
+	if cfg.Control.Topic != "" && cfg.Control.Secret == "" {
+		return nil, fmt.Errorf("CONTROL_SECRET is required when CONTROL_TOPIC is set")
+	}
+
+	if cfg.Consumer.LagCheckInterval <= 0 {
+		return nil, fmt.Errorf("CONSUMER_LAG_CHECK_INTERVAL must be positive, got %s", cfg.Consumer.LagCheckInterval)
+	}
+	if cfg.Consumer.LagCheckIntervals <= 0 {
+		return nil, fmt.Errorf("CONSUMER_LAG_CHECK_INTERVALS must be positive, got %d", cfg.Consumer.LagCheckIntervals)
+	}
+
+	if cfg.Logging.SampleRate < 1 {
+		return nil, fmt.Errorf("LOG_SAMPLE_RATE must be at least 1, got %d", cfg.Logging.SampleRate)
+	}
+
 	return &cfg, nil
 }
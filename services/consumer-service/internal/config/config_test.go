@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string // подстрока ошибки; "" — конфигурация валидна
+	}{
+		{name: "defaults"},
+		{name: "batch commit with DLQ", env: map[string]string{"CONSUMER_COMMIT_MODE": "batch", "KAFKA_DLQ_TOPICS": "default:dlq"}},
+		{name: "invalid commit mode", env: map[string]string{"CONSUMER_COMMIT_MODE": "auto"}, wantErr: "invalid commit mode"},
+		{name: "batch commit without DLQ", env: map[string]string{"CONSUMER_COMMIT_MODE": "batch"}, wantErr: "requires KAFKA_DLQ_TOPICS"},
+		{name: "zero workers", env: map[string]string{"CONSUMER_WORKER_COUNT": "0"}, wantErr: "CONSUMER_WORKER_COUNT"},
+		{name: "zero batch size", env: map[string]string{"CONSUMER_BATCH_SIZE": "0"}, wantErr: "CONSUMER_BATCH_SIZE"},
+		{name: "fetch smaller than max event", env: map[string]string{"KAFKA_MAX_BYTES": "15000"}, wantErr: "KAFKA_MAX_BYTES"},
+		{name: "non-positive max data", env: map[string]string{"EVENT_MAX_DATA_BYTES": "0"}, wantErr: "EVENT_MAX_DATA_BYTES"},
+		{name: "unknown fields policy", env: map[string]string{"CONSUMER_UNKNOWN_FIELDS": "drop"}, wantErr: "unknown fields policy"},
+		{name: "duplicate group", env: map[string]string{"CONSUMER_GROUPS": "a,b,a"}, wantErr: "CONSUMER_GROUPS"},
+		{name: "backfill range", env: map[string]string{"CONSUMER_BACKFILL_ENABLED": "true", "CONSUMER_BACKFILL_START_OFFSET": "10", "CONSUMER_BACKFILL_END_OFFSET": "5"}, wantErr: "invalid backfill range"},
+		{name: "backfill with several groups", env: map[string]string{"CONSUMER_BACKFILL_ENABLED": "true", "CONSUMER_GROUPS": "a,b"}, wantErr: "single consumer group"},
+		{name: "output topic equals input", env: map[string]string{"KAFKA_OUTPUT_TOPIC": "events"}, wantErr: "must differ"},
+		{name: "output topic without batch commit", env: map[string]string{"KAFKA_OUTPUT_TOPIC": "enriched"}, wantErr: "requires CONSUMER_COMMIT_MODE=batch"},
+		{name: "exclusive offset store without path", env: map[string]string{"KAFKA_OFFSET_STORE_EXCLUSIVE": "true"}, wantErr: "KAFKA_OFFSET_STORE_PATH"},
+		{name: "strict order with priorities", env: map[string]string{"CONSUMER_STRICT_ORDER": "true", "CONSUMER_TYPE_PRIORITIES": "user_created:1"}, wantErr: "mutually exclusive"},
+		{name: "unknown shutdown signal", env: map[string]string{"APP_SHUTDOWN_SIGNALS": "SIGFOO"}, wantErr: "APP_SHUTDOWN_SIGNALS"},
+		{name: "control topic without secret", env: map[string]string{"CONTROL_TOPIC": "control"}, wantErr: "CONTROL_SECRET"},
+		{name: "label headers without value cap", env: map[string]string{"CONSUMER_LABEL_HEADERS": "region", "CONSUMER_LABEL_HEADER_MAX_VALUES": "0"}, wantErr: "CONSUMER_LABEL_HEADER_MAX_VALUES"},
+		{name: "zero lag check interval", env: map[string]string{"CONSUMER_LAG_CHECK_INTERVAL": "0s"}, wantErr: "CONSUMER_LAG_CHECK_INTERVAL"},
+		{name: "zero log sample rate", env: map[string]string{"LOG_SAMPLE_RATE": "0"}, wantErr: "LOG_SAMPLE_RATE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v, want nil", err)
+				}
+				if cfg == nil {
+					t.Fatal("Load() returned nil config")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
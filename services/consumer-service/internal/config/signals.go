@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ReloadSignal зарезервирован под перезагрузку конфигурации и не может
+// входить в набор сигналов остановки
+var ReloadSignal os.Signal = syscall.SIGHUP
+
+// shutdownSignals сигналы, допустимые в наборе сигналов остановки
+var shutdownSignals = map[string]os.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
+// ParseSignals преобразует имена сигналов ("SIGTERM" или "TERM") в os.Signal
+func ParseSignals(names []string) ([]os.Signal, error) {
+	signals := make([]os.Signal, 0, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+
+		if name == "SIGHUP" {
+			return nil, fmt.Errorf("SIGHUP is reserved for config reload and cannot stop the service")
+		}
+		sig, ok := shutdownSignals[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported shutdown signal %q", name)
+		}
+		signals = append(signals, sig)
+	}
+
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("shutdown signal set is empty")
+	}
+	return signals, nil
+}
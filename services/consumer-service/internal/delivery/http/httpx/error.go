@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"consumer-service/internal/domain"
+)
+
+// ErrorResponse единый формат ответа с ошибкой для обработчиков и middleware.
+// TraceID — correlation ID запроса (X-Request-ID), по нему ответ связывается
+// с логами и событиями, опубликованными в рамках запроса.
+type ErrorResponse struct {
+	Error     string       `json:"error"`
+	Message   string       `json:"message"`
+	Code      string       `json:"code,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// FieldError описывает нарушение валидации отдельного поля
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+// NewErrorResponse собирает ответ с ошибкой; trace ID берется из контекста запроса
+func NewErrorResponse(r *http.Request, statusCode int, code, message string, details []FieldError) ErrorResponse {
+	traceID, _ := domain.CorrelationIDFromContext(r.Context())
+	return ErrorResponse{
+		Error:     http.StatusText(statusCode),
+		Message:   message,
+		Code:      code,
+		TraceID:   traceID,
+		Details:   details,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// WriteError записывает ответ с ошибкой в формате ErrorResponse
+func WriteError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, details ...FieldError) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	return json.NewEncoder(w).Encode(NewErrorResponse(r, statusCode, code, message, details))
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock источник текущего времени. Позволяет подменять время в тестах
+// проверки timestamp, TTL и прочих зависящих от времени путей.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc позволяет использовать функцию как Clock
+type ClockFunc func() time.Time
+
+// Now возвращает текущее время
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock возвращает системное время
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FakeClock управляемые часы для детерминированных тестов
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock создает часы, остановленные на now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now возвращает установленное время
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set устанавливает текущее время
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance сдвигает время на d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
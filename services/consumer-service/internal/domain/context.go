@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Ключи метаданных события, передаваемые между сервисами
+const (
+	MetadataCorrelationID = "correlation_id"
+	MetadataTenantID      = "tenant_id"
+
+	// MetadataPartition явно задает партицию топика (например, для co-partitioning)
+	MetadataPartition = "partition"
+)
+
+// ErrReservedMetadataKey клиент передал ключ метаданных, который выставляет сам сервис
+var ErrReservedMetadataKey = errors.New("metadata key is reserved")
+
+// reservedMetadataKeys ключи метаданных обогащения и имена заголовков сообщения
+// Kafka. Заголовки строятся из метаданных, поэтому клиентский ключ с таким
+// именем позволил бы подменить, например, event-type или tenant.
+var reservedMetadataKeys = map[string]struct{}{
+	"event-type":      {},
+	"event-id":        {},
+	"event-version":   {},
+	"event-source":    {},
+	"correlation-id":  {},
+	"tenant":          {},
+	"tenant-id":       {},
+	"partition":       {},
+	"data-encoding":   {},
+	"service-version": {},
+	"environment":     {},
+}
+
+// IsReservedMetadataKey сообщает, зарезервирован ли ключ метаданных.
+// Регистр не учитывается, '_' и '-' считаются одинаковыми.
+func IsReservedMetadataKey(key string) bool {
+	normalized := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "_", "-")
+	_, ok := reservedMetadataKeys[normalized]
+	return ok
+}
+
+type (
+	correlationIDKey struct{}
+	tenantKey        struct{}
+)
+
+// WithCorrelationID возвращает контекст с correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext возвращает correlation ID из контекста
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// WithTenant возвращает контекст с идентификатором tenant'а
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext возвращает идентификатор tenant'а из контекста
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ProcessingResult результат обработки события
+type ProcessingResult struct {
+	EventID     string        `json:"event_id"`
+	EventType   EventType     `json:"event_type"`
+	Success     bool          `json:"success"`
+	Error       string        `json:"error,omitempty"`
+	Duration    time.Duration `json:"duration"`
+	ProcessedAt time.Time     `json:"processed_at"`
+}
+
+// ResultFilter фильтр для выборки результатов обработки
+type ResultFilter struct {
+	Success   *bool
+	EventType EventType
+	Limit     int
+}
+
+// Matches проверяет, подходит ли результат под фильтр
+func (f ResultFilter) Matches(result *ProcessingResult) bool {
+	if f.Success != nil && result.Success != *f.Success {
+		return false
+	}
+	if f.EventType != "" && result.EventType != f.EventType {
+		return false
+	}
+	return true
+}
+
+// EventConsumer интерфейс для pull-чтения событий
+type EventConsumer interface {
+	// ConsumeBatch читает до batchSize событий без коммита offset'ов
+	ConsumeBatch(ctx context.Context, batchSize int) ([]*Event, error)
+
+	// Stats возвращает накопленную статистику чтения
+	Stats() ConsumerStats
+
+	// Ready сообщает, подтверждена ли связь с брокером
+	Ready() bool
+}
+
+// ConsumerStats статистика чтения сообщений consumer'ом
+type ConsumerStats struct {
+	MessagesConsumed int64      `json:"messages_consumed"`
+	BytesConsumed    int64      `json:"bytes_consumed"`
+	Errors           int64      `json:"errors"`
+	LastMessageTime  *time.Time `json:"last_message_time,omitempty"`
+	Lag              int64      `json:"lag"`
+}
+
+// EventRepository интерфейс для хранения результатов обработки событий
+type EventRepository interface {
+	// SaveResult сохраняет результат обработки события
+	SaveResult(ctx context.Context, result *ProcessingResult) error
+
+	// GetResults возвращает результаты обработки, начиная с самых свежих
+	GetResults(ctx context.Context, filter ResultFilter) ([]*ProcessingResult, error)
+}
+
+// ResultSink получатель результатов обработки (например, внешний аудит).
+// Send не должен блокировать обработку событий.
+type ResultSink interface {
+	// Send передает результат обработки
+	Send(result *ProcessingResult)
+
+	// Close дожидается отправки накопленных результатов
+	Close() error
+}
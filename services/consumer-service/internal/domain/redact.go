@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// redactedValue значение, которым заменяются скрытые поля
+const redactedValue = "[REDACTED]"
+
+// Redactor скрывает значения заданных полей JSON в data события перед
+// записью в лог (LOG_REDACT_FIELDS), чтобы PII вроде email не попадала
+// в логи. Имена полей сравниваются без учета регистра на любой глубине
+// вложенности. Nil Redactor возвращает data без изменений.
+type Redactor struct {
+	fields map[string]struct{}
+}
+
+// NewRedactor создает Redactor для заданных полей; без полей возвращает nil
+func NewRedactor(fields []string) *Redactor {
+	set := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			set[field] = struct{}{}
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return &Redactor{fields: set}
+}
+
+// Redact возвращает data со скрытыми значениями заданных полей. Data,
+// который не разбирается как JSON, скрывается целиком: найти в нем поля
+// нельзя, а вывести как есть небезопасно.
+func (r *Redactor) Redact(data string) string {
+	if r == nil {
+		return data
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return redactedValue
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(r.redactValue(value)); err != nil {
+		return redactedValue
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// redactValue рекурсивно заменяет значения заданных полей
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if _, ok := r.fields[strings.ToLower(key)]; ok {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = r.redactValue(nested)
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = r.redactValue(nested)
+		}
+	}
+	return value
+}
@@ -1,9 +1,15 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"consumer-service/internal/config"
@@ -15,10 +21,21 @@ import (
 
 // ConsumerMetrics интерфейс для метрик consumer
 type ConsumerMetrics interface {
-	IncConsumedEvents(eventType string)
+	IncConsumedEvents(eventType, tenant string, headerValues ...string)
 	IncFailedEvents(eventType string, reason string)
-	ObserveProcessingDuration(eventType string, duration time.Duration)
+	ObserveProcessingDuration(eventType, status string, duration time.Duration)
+	ObserveE2ELatency(eventType string, latency time.Duration)
 	ObserveCommitDuration(duration time.Duration)
+	ObserveQueueWait(wait time.Duration)
+	ObserveMessageDwell(dwell time.Duration)
+	ObserveMessageSize(topic string, size int)
+	IncCommitFailures(reason string)
+	SetFallingBehind(behind bool)
+	SetSaturated(saturated bool)
+	AddEnqueueBlocked(blocked time.Duration)
+	SetStarting(starting bool)
+	SetWorkerUtilization(utilization float64)
+	IncUnknownFields(eventType string)
 }
 
 // EventProcessor интерфейс для обработки событий
@@ -26,30 +43,113 @@ type EventProcessor interface {
 	ProcessEvent(ctx context.Context, event *domain.Event) error
 }
 
+// queuedMessage сообщение в очереди worker'ов с временем постановки
+type queuedMessage struct {
+	message    kafka.Message
+	enqueuedAt time.Time
+}
+
 // MessageBatch представляет batch сообщений для обработки
 type MessageBatch struct {
 	Messages []kafka.Message
 	Events   []*domain.Event
 }
 
+// MessageReader читает и коммитит сообщения Kafka; реализуется *kafka.Reader.
+// Подменяется через SetReader, чтобы прогнать чтение, обработку и коммит
+// по заранее заданным сообщениям без брокера.
+type MessageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Stats() kafka.ReaderStats
+	Close() error
+}
+
+var _ MessageReader = (*kafka.Reader)(nil)
+
 // Consumer реализует Kafka consumer с поддержкой параллельной обработки
 type Consumer struct {
-	reader      *kafka.Reader
-	processor   EventProcessor
-	logger      *logrus.Logger
-	metrics     ConsumerMetrics
-	config      config.KafkaConfig
-	mu          sync.RWMutex
-	closed      bool
-	wg          sync.WaitGroup
-	workerCount int
-	batchSize   int
-	messageChan chan kafka.Message
-	commitChan  chan kafka.Message
+	reader         MessageReader
+	processor      EventProcessor
+	repository     domain.EventRepository
+	sink           domain.ResultSink
+	dlq            *DLQPublisher
+	output         *OutputPublisher
+	transformer    EventTransformer
+	logger         *logrus.Logger
+	metrics        ConsumerMetrics
+	config         config.KafkaConfig
+	mu             sync.RWMutex
+	closed         bool
+	wg             sync.WaitGroup
+	workerCount    int
+	batchSize      int
+	readTimeout    time.Duration
+	backfill       config.BackfillConfig
+	unknown        string
+	tenants        *tenantLabeler
+	headerLabels   *headerLabeler
+	skipValidation bool
+	jsonLines      bool
+	eventTTL       time.Duration
+	clock          domain.Clock
+	messageChan    chan queuedMessage
+	dispatcher     *priorityDispatcher
+	commitChan     chan commitItem
+	batchCommit    bool
+	commitInterval time.Duration
+
+	// Внешнее хранилище offset'ов; nil — offset'ы хранит только Kafka
+	offsetStore     OffsetStore
+	offsetStoreOnly bool
+	offsetCommitter groupOffsetCommitter
+
+	// Детектор отставания обработки от поступления сообщений в топик
+	processedCount    atomic.Int64
+	lagCheckInterval  time.Duration
+	lagCheckThreshold int
+
+	// Утилизация пула worker'ов, выгружается раз в utilizationInterval
+	utilization         *utilizationTracker
+	utilizationInterval time.Duration
+
+	// Прогрев после старта: до первого коммита или истечения warmupPeriod
+	warmupPeriod time.Duration
+	warmupDone   atomic.Bool
+
+	// Статистика чтения для Stats()
+	messagesConsumed atomic.Int64
+	bytesConsumed    atomic.Int64
+	errorCount       atomic.Int64
+	lastMessageTime  atomic.Int64 // UnixNano, 0 — сообщений еще не было
+
+	// Готовность: связь с брокером подтверждена чтением или метаданными топика
+	ready atomic.Bool
+
+	// abort останавливает consumer с фатальной ошибкой (задается в Start)
+	abort context.CancelCauseFunc
+
+	// Drain: закрытие drainCh останавливает чтение, stopped закрывается по выходу из Start
+	drainCh   chan struct{}
+	drainOnce sync.Once
+	stopped   chan struct{}
+}
+
+// commitItem результат обработки сообщения для batch committer'а;
+// сообщения с ошибкой передаются только в режиме batch
+type commitItem struct {
+	message kafka.Message
+	err     error
 }
 
+var _ domain.EventConsumer = (*Consumer)(nil)
+
+// ErrShutdownTimeout возвращается, если закрытие reader'а не уложилось в KAFKA_CLOSE_TIMEOUT
+var ErrShutdownTimeout = errors.New("shutdown timed out")
+
 // NewConsumer создает новый Kafka consumer с параллельной обработкой
-func NewConsumer(cfg config.KafkaConfig, consumerCfg config.ConsumerConfig, processor EventProcessor, logger *logrus.Logger, metrics ConsumerMetrics) (*Consumer, error) {
+func NewConsumer(cfg config.KafkaConfig, consumerCfg config.ConsumerConfig, processor EventProcessor, repository domain.EventRepository, sink domain.ResultSink, logger *logrus.Logger, metrics ConsumerMetrics) (*Consumer, error) {
 	if len(cfg.Brokers) == 0 {
 		return nil, fmt.Errorf("kafka brokers list is empty")
 	}
@@ -58,10 +158,20 @@ func NewConsumer(cfg config.KafkaConfig, consumerCfg config.ConsumerConfig, proc
 		return nil, fmt.Errorf("kafka topic is empty")
 	}
 
-	if cfg.GroupID == "" {
+	backfill := consumerCfg.Backfill
+	if cfg.GroupID == "" && !backfill.Enabled {
 		return nil, fmt.Errorf("kafka group ID is empty")
 	}
 
+	// Буферы каналов выводятся из этих значений: нулевая емкость
+	// messageChan или commitChan приводит к взаимной блокировке worker'ов
+	if consumerCfg.WorkerCount <= 0 && !consumerCfg.StrictOrder {
+		return nil, fmt.Errorf("worker count must be positive, got %d", consumerCfg.WorkerCount)
+	}
+	if consumerCfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", consumerCfg.BatchSize)
+	}
+
 	// Определяем начальный offset
 	var startOffset int64
 	switch cfg.StartOffset {
@@ -73,8 +183,7 @@ func NewConsumer(cfg config.KafkaConfig, consumerCfg config.ConsumerConfig, proc
 		startOffset = kafka.LastOffset
 	}
 
-	// Создаем Kafka reader
-	reader := kafka.NewReader(kafka.ReaderConfig{
+	readerCfg := kafka.ReaderConfig{
 		Brokers:        cfg.Brokers,
 		Topic:          cfg.Topic,
 		GroupID:        cfg.GroupID,
@@ -84,28 +193,157 @@ func NewConsumer(cfg config.KafkaConfig, consumerCfg config.ConsumerConfig, proc
 		CommitInterval: cfg.CommitInterval,
 		StartOffset:    startOffset,
 		ErrorLogger:    kafka.LoggerFunc(logger.Errorf),
-	})
+	}
+
+	// В режиме backfill читаем одну партицию без consumer group
+	if backfill.Enabled {
+		readerCfg.GroupID = ""
+		readerCfg.Partition = backfill.Partition
+	}
+
+	// Создаем Kafka reader
+	reader := kafka.NewReader(readerCfg)
+
+	if backfill.Enabled {
+		if err := reader.SetOffset(backfill.StartOffset); err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to set backfill start offset: %w", err)
+		}
+	}
+
+	// Таймаут одного чтения; по умолчанию два интервала MaxWait
+	readTimeout := cfg.ReadTimeout
+	if readTimeout <= 0 {
+		readTimeout = cfg.MaxWait * 2
+	}
+
+	// Настраиваем DLQ, если заданы топики
+	var dlq *DLQPublisher
+	if cfg.DLQTopics != "" {
+		resolver, err := NewDLQTopicResolver(cfg.DLQTopics)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("invalid DLQ configuration: %w", err)
+		}
+		dlq = NewDLQPublisher(cfg.Brokers, resolver, logger)
+	}
+
+	// Stream-этап: результат трансформации публикуется в выходной топик
+	var output *OutputPublisher
+	var transformer EventTransformer
+	if cfg.OutputTopic != "" {
+		var ok bool
+		if transformer, ok = processor.(EventTransformer); !ok {
+			reader.Close()
+			return nil, fmt.Errorf("output topic %q requires a processor implementing EventTransformer", cfg.OutputTopic)
+		}
+		output = NewOutputPublisher(cfg.Brokers, cfg.OutputTopic, logger)
+	}
+
+	// В режиме строгого порядка параллельная обработка отключается
+	workerCount := consumerCfg.WorkerCount
+	if consumerCfg.StrictOrder {
+		workerCount = 1
+	}
+
+	if _, err := HeaderLabelNames(consumerCfg.LabelHeaders); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("invalid label headers: %w", err)
+	}
+
+	// Режим batch не останавливается на сообщении, которое не удается
+	// обработать: после повторов оно уходит в DLQ, и без DLQ режим невозможен
+	batchCommit := consumerCfg.CommitMode == config.CommitModeBatch
+	if batchCommit && dlq == nil {
+		reader.Close()
+		return nil, fmt.Errorf("batch commit mode requires DLQ topics")
+	}
+
+	// В режиме message offset следующего сообщения партиции закоммитил бы
+	// входное сообщение с неудачной записью в выходной топик
+	if output != nil && !batchCommit {
+		reader.Close()
+		return nil, fmt.Errorf("output topic %q requires batch commit mode", cfg.OutputTopic)
+	}
+
+	// Batch закрывается по BatchSize сообщений или по интервалу коммита
+	commitInterval := cfg.CommitInterval
+	if commitInterval <= 0 {
+		commitInterval = time.Second
+	}
+
+	// Приоритеты типов: сообщения раскладываются по очередям до worker'ов
+	var dispatcher *priorityDispatcher
+	if consumerCfg.TypePriorities != "" {
+		var err error
+		dispatcher, err = newPriorityDispatcher(consumerCfg.TypePriorities, workerCount*2)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("invalid type priorities: %w", err)
+		}
+	}
 
 	consumer := &Consumer{
-		reader:      reader,
-		processor:   processor,
-		logger:      logger,
-		metrics:     metrics,
-		config:      cfg,
-		workerCount: consumerCfg.WorkerCount,
-		batchSize:   consumerCfg.BatchSize,
-		messageChan: make(chan kafka.Message, consumerCfg.WorkerCount*2),
-		commitChan:  make(chan kafka.Message, consumerCfg.BatchSize*2),
+		reader:         reader,
+		dlq:            dlq,
+		output:         output,
+		transformer:    transformer,
+		processor:      processor,
+		repository:     repository,
+		sink:           sink,
+		logger:         logger,
+		metrics:        metrics,
+		config:         cfg,
+		workerCount:    workerCount,
+		batchSize:      consumerCfg.BatchSize,
+		readTimeout:    readTimeout,
+		backfill:       backfill,
+		unknown:        consumerCfg.UnknownFields,
+		tenants:        newTenantLabeler(consumerCfg.TenantHeader, consumerCfg.TenantAllowlist),
+		headerLabels:   newHeaderLabeler(consumerCfg.LabelHeaders, consumerCfg.LabelHeaderMaxValues),
+		skipValidation: consumerCfg.SkipValidation,
+		eventTTL:       consumerCfg.EventTTL,
+		clock:          domain.SystemClock,
+		jsonLines:      consumerCfg.JSONLines,
+		messageChan:    make(chan queuedMessage, workerCount*2),
+		dispatcher:     dispatcher,
+		commitChan:     make(chan commitItem, consumerCfg.BatchSize*2),
+		batchCommit:    batchCommit,
+		commitInterval: commitInterval,
+		drainCh:        make(chan struct{}),
+		stopped:        make(chan struct{}),
+
+		offsetCommitter: &kafka.Client{Addr: kafka.TCP(cfg.Brokers...)},
+
+		lagCheckInterval:  consumerCfg.LagCheckInterval,
+		lagCheckThreshold: consumerCfg.LagCheckIntervals,
+		warmupPeriod:      consumerCfg.WarmupPeriod,
+
+		utilization:         newUtilizationTracker(workerCount),
+		utilizationInterval: consumerCfg.UtilizationInterval,
 	}
 
 	logger.WithFields(logrus.Fields{
 		"brokers":      cfg.Brokers,
 		"topic":        cfg.Topic,
 		"group_id":     cfg.GroupID,
-		"worker_count": consumerCfg.WorkerCount,
+		"worker_count": workerCount,
+		"strict_order": consumerCfg.StrictOrder,
+		"priorities":   consumerCfg.TypePriorities,
 		"batch_size":   consumerCfg.BatchSize,
+		"commit_mode":  consumerCfg.CommitMode,
+		"dlq_enabled":  dlq != nil,
+		"backfill":     backfill.Enabled,
 	}).Info("Kafka consumer initialized with parallel processing")
 
+	if backfill.Enabled {
+		logger.WithFields(logrus.Fields{
+			"partition":    backfill.Partition,
+			"start_offset": backfill.StartOffset,
+			"end_offset":   backfill.EndOffset,
+		}).Info("Consumer running in backfill mode")
+	}
+
 	return consumer, nil
 }
 
@@ -117,36 +355,127 @@ func (c *Consumer) Start(ctx context.Context) error {
 		return fmt.Errorf("consumer is closed")
 	}
 	c.mu.Unlock()
+	defer close(c.stopped)
 
 	c.logger.Info("Starting Kafka consumer with parallel processing")
 
-	// Запускаем worker'ы для обработки сообщений
+	// Offset'ы внешнего хранилища переносятся в группу до первого чтения
+	if err := c.seedOffsets(ctx); err != nil {
+		return err
+	}
+
+	// Фатальная ошибка любого компонента останавливает consumer и возвращается из Start
+	ctx, c.abort = context.WithCancelCause(ctx)
+	defer c.abort(nil)
+
+	// Прогрев завершается первым коммитом или по истечении warmupPeriod
+	if c.warmupPeriod > 0 {
+		c.metrics.SetStarting(true)
+		warmup := time.AfterFunc(c.warmupPeriod, func() { c.endWarmup("period elapsed") })
+		defer warmup.Stop()
+	} else {
+		c.endWarmup("disabled")
+	}
+
+	// Детектор отставания работает, пока работают reader и worker'ы
+	if c.lagCheckInterval > 0 {
+		detectorCtx, stopDetector := context.WithCancel(ctx)
+		defer stopDetector()
+		go c.slowConsumerDetector(detectorCtx)
+	}
+
+	if c.utilizationInterval > 0 {
+		samplerCtx, stopSampler := context.WithCancel(ctx)
+		defer stopSampler()
+		go c.utilizationSampler(samplerCtx)
+	}
+
+	// Запускаем worker'ы для обработки сообщений. commitChan закрывается только
+	// после выхода всех worker'ов: отправка в закрытый канал вызвала бы панику.
+	var workers sync.WaitGroup
 	for i := 0; i < c.workerCount; i++ {
 		c.wg.Add(1)
-		go c.messageWorker(ctx, i)
+		workers.Add(1)
+		go func(workerID int) {
+			defer workers.Done()
+			c.messageWorker(ctx, workerID)
+		}(i)
 	}
-
-	// Запускаем batch committer
 	c.wg.Add(1)
-	go c.batchCommitter(ctx)
+	go func() {
+		defer c.wg.Done()
+		workers.Wait()
+		close(c.commitChan)
+	}()
+
+	// Запускаем batch committer (в режиме backfill offset'ы не коммитятся)
+	if !c.backfill.Enabled {
+		c.wg.Add(1)
+		go c.batchCommitter(ctx)
+	}
+
+	// Диспетчер приоритетов передает сообщения worker'ам
+	if c.dispatcher != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.dispatcher.run(ctx, c.messageChan)
+		}()
+	}
+
+	// Основной цикл чтения сообщений; Drain останавливает только чтение,
+	// worker'ы и committer завершаются после обработки прочитанного
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+	go func() {
+		select {
+		case <-c.drainCh:
+			stopReading()
+		case <-readCtx.Done():
+		}
+	}()
 
-	// Основной цикл чтения сообщений
 	c.wg.Add(1)
-	go c.messageReader(ctx)
+	go c.messageReader(ctx, readCtx)
 
 	// Ждем завершения всех горутин
 	c.wg.Wait()
+
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
 	return nil
 }
 
-// messageReader читает сообщения из Kafka и отправляет их в канал для обработки
-func (c *Consumer) messageReader(ctx context.Context) {
+// Drain останавливает чтение, дожидается обработки уже прочитанных сообщений
+// и коммита их offset'ов, после чего Start возвращает nil. Возвращает ошибку,
+// если consumer не остановился до отмены ctx. Вызывается после Start.
+func (c *Consumer) Drain(ctx context.Context) error {
+	c.drainOnce.Do(func() {
+		c.logger.Info("Draining Kafka consumer")
+		close(c.drainCh)
+	})
+
+	select {
+	case <-c.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("consumer drain interrupted: %w", ctx.Err())
+	}
+}
+
+// messageReader читает сообщения из Kafka и отправляет их в канал для обработки.
+// Чтение прекращается с отменой readCtx (в том числе по Drain), а уже
+// прочитанное сообщение передается worker'ам, пока не отменен ctx.
+func (c *Consumer) messageReader(ctx, readCtx context.Context) {
 	defer c.wg.Done()
-	defer close(c.messageChan)
+	defer c.closeQueue()
+
+	var idleBackoff time.Duration
 
 	for {
 		select {
-		case <-ctx.Done():
+		case <-readCtx.Done():
 			c.logger.Info("Message reader context cancelled, stopping")
 			return
 		default:
@@ -159,32 +488,156 @@ func (c *Consumer) messageReader(ctx context.Context) {
 			c.mu.RUnlock()
 
 			// Создаем контекст с таймаутом для чтения сообщения
-			readCtx, cancel := context.WithTimeout(ctx, c.config.MaxWait*2)
+			timeoutCtx, cancel := context.WithTimeout(readCtx, c.readTimeout)
 
-			// Читаем сообщение с таймаутом
-			message, err := reader.ReadMessage(readCtx)
+			// Читаем сообщение с таймаутом. FetchMessage не коммитит offset:
+			// коммит выполняет только batchCommitter после обработки
+			message, err := reader.FetchMessage(timeoutCtx)
 			cancel()
 
 			if err != nil {
-				if err == context.Canceled || err == context.DeadlineExceeded {
+				if readCtx.Err() != nil {
+					return
+				}
+
+				// Таймаут чтения означает пустой топик — продолжаем опрос
+				if errors.Is(err, context.DeadlineExceeded) {
+					c.confirmIdleConnectivity(readCtx)
+					idleBackoff = c.nextIdleBackoff(idleBackoff)
+					if !sleepContext(readCtx, idleBackoff) {
+						return
+					}
+					continue
+				}
+
+				// Ошибки аутентификации и авторизации не исправятся повтором:
+				// останавливаем consumer, чтобы ошибка конфигурации была видна
+				if isAuthError(err) {
+					c.logger.WithError(err).Error("Kafka authentication failed, stopping consumer")
+					c.abort(fmt.Errorf("kafka authentication failed: %w", err))
 					return
 				}
 
 				c.logger.WithError(err).Warn("Error reading message from Kafka")
-				time.Sleep(c.config.RetryBackoff)
+				if !sleepContext(readCtx, c.config.RetryBackoff) {
+					return
+				}
 				continue
 			}
+			idleBackoff = 0
+			c.markReady()
+
+			// В режиме backfill не выходим за границу диапазона
+			if c.backfill.Enabled && message.Offset > c.backfill.EndOffset {
+				c.logger.WithField("end_offset", c.backfill.EndOffset).Info("Backfill range completed")
+				return
+			}
+
+			c.observeDwell(message)
 
 			// Отправляем сообщение в канал для обработки
-			select {
-			case c.messageChan <- message:
-			case <-ctx.Done():
+			if !c.enqueue(ctx, queuedMessage{message: message, enqueuedAt: time.Now()}) {
+				return
+			}
+
+			if c.backfill.Enabled && message.Offset >= c.backfill.EndOffset {
+				c.logger.WithField("end_offset", c.backfill.EndOffset).Info("Backfill range completed")
 				return
 			}
 		}
 	}
 }
 
+// enqueue передает прочитанное сообщение worker'ам напрямую или через диспетчер приоритетов
+func (c *Consumer) enqueue(ctx context.Context, queued queuedMessage) bool {
+	if c.dispatcher == nil {
+		return c.sendQueued(ctx, c.messageChan, queued)
+	}
+
+	if !c.sendQueued(ctx, c.dispatcher.queueFor(queued.message), queued) {
+		return false
+	}
+	c.dispatcher.signal()
+	return true
+}
+
+// sendQueued помещает сообщение в очередь, учитывая время, которое reader ждал места
+func (c *Consumer) sendQueued(ctx context.Context, queue chan<- queuedMessage, queued queuedMessage) bool {
+	select {
+	case queue <- queued:
+		return true
+	default:
+	}
+
+	// Очередь полна: все worker'ы заняты, и reader ждет. Насыщение сигнализирует,
+	// что пул пора масштабировать; время ожидания копится в счетчике.
+	c.metrics.SetSaturated(true)
+	blockedSince := time.Now()
+	defer func() {
+		c.metrics.AddEnqueueBlocked(time.Since(blockedSince))
+		c.metrics.SetSaturated(false)
+	}()
+
+	select {
+	case queue <- queued:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// closeQueue сообщает worker'ам, что чтение завершено
+func (c *Consumer) closeQueue() {
+	if c.dispatcher != nil {
+		c.dispatcher.close()
+		return
+	}
+	close(c.messageChan)
+}
+
+// endWarmup завершает прогрев после старта (однократно)
+func (c *Consumer) endWarmup(reason string) {
+	if !c.warmupDone.CompareAndSwap(false, true) {
+		return
+	}
+
+	c.metrics.SetStarting(false)
+	c.logger.WithField("reason", reason).Info("Consumer warmup finished")
+}
+
+// nextIdleBackoff увеличивает паузу между опросами пустого топика до IdleBackoffMax
+func (c *Consumer) nextIdleBackoff(current time.Duration) time.Duration {
+	if c.config.IdleBackoffMax <= 0 {
+		return 0
+	}
+
+	next := current * 2
+	if next == 0 {
+		next = c.config.RetryBackoff
+	}
+	if next > c.config.IdleBackoffMax {
+		next = c.config.IdleBackoffMax
+	}
+	return next
+}
+
+// sleepContext ждет d или отмены контекста; возвращает false при отмене
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 // messageWorker обрабатывает сообщения из канала
 func (c *Consumer) messageWorker(ctx context.Context, workerID int) {
 	defer c.wg.Done()
@@ -197,20 +650,34 @@ func (c *Consumer) messageWorker(ctx context.Context, workerID int) {
 		case <-ctx.Done():
 			logger.Info("Message worker context cancelled, stopping")
 			return
-		case message, ok := <-c.messageChan:
+		case queued, ok := <-c.messageChan:
 			if !ok {
 				logger.Info("Message channel closed, stopping worker")
 				return
 			}
 
-			if err := c.processMessage(ctx, message); err != nil {
+			// Время ожидания свободного worker'а (внутренняя очередь, не лаг брокера)
+			c.metrics.ObserveQueueWait(time.Since(queued.enqueuedAt))
+			message := queued.message
+
+			c.utilization.begin(workerID)
+			err := c.processMessage(ctx, message)
+			c.utilization.end(workerID)
+			c.processedCount.Add(1)
+			if err != nil {
 				logger.WithError(err).Error("Failed to process message")
+				if !c.batchCommit {
+					continue
+				}
+			}
+
+			if c.backfill.Enabled {
 				continue
 			}
 
 			// Отправляем сообщение для коммита
 			select {
-			case c.commitChan <- message:
+			case c.commitChan <- commitItem{message: message, err: err}:
 			case <-ctx.Done():
 				return
 			}
@@ -221,108 +688,581 @@ func (c *Consumer) messageWorker(ctx context.Context, workerID int) {
 // processMessage обрабатывает одно сообщение
 func (c *Consumer) processMessage(ctx context.Context, message kafka.Message) error {
 	start := time.Now()
+	c.recordMessage(message)
+
+	if lines, ok := c.splitJSONLines(message.Value); ok {
+		return c.processLines(ctx, message, lines, start)
+	}
+	return c.processRecord(ctx, message, start)
+}
+
+// splitJSONLines разбивает тело в формате JSON Lines на отдельные события.
+// ok=false — режим выключен или в сообщении одно событие.
+func (c *Consumer) splitJSONLines(value []byte) (lines [][]byte, ok bool) {
+	if !c.jsonLines {
+		return nil, false
+	}
+
+	value = bytes.TrimSpace(value)
+	if bytes.IndexByte(value, '\n') < 0 {
+		return nil, false
+	}
+
+	for _, line := range bytes.Split(value, []byte{'\n'}) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, true
+}
+
+// processLines обрабатывает каждую строку сообщения как отдельное событие.
+// Неудачные строки уходят в DLQ поштучно; сообщение коммитится, только если
+// обработаны или сохранены в DLQ все строки. При повторном чтении уже
+// обработанные строки будут обработаны снова (at-least-once).
+func (c *Consumer) processLines(ctx context.Context, message kafka.Message, lines [][]byte, start time.Time) error {
+	var firstErr error
+	for i, line := range lines {
+		lineMessage := message
+		lineMessage.Value = line
+
+		if err := c.processRecord(ctx, lineMessage, start); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("line %d of %d: %w", i+1, len(lines), err)
+		}
+	}
+	return firstErr
+}
+
+// processRecord разбирает, проверяет и обрабатывает одно событие
+func (c *Consumer) processRecord(ctx context.Context, message kafka.Message, start time.Time) error {
+	headers := parseEventHeaders(message)
 
-	// Парсим событие из JSON
-	event, err := domain.FromJSON(message.Value)
+	// Парсим событие из JSON и распаковываем data (parse_error — невалидный JSON или сжатие)
+	event, err := domain.DefaultCodec.DecodeMessage(message.Value, headers.DataEncoding)
 	if err != nil {
-		c.metrics.IncFailedEvents("unknown", "parse_error")
+		// Тело повреждено: тип и ID берем из заголовков сообщения
+		eventType := headers.typeOrUnknown()
+
+		c.metrics.IncFailedEvents(string(eventType), "parse_error")
 		c.logger.WithFields(logrus.Fields{
-			"offset":    message.Offset,
-			"partition": message.Partition,
-			"error":     err,
+			"event_id":      headers.ID,
+			"event_type":    eventType,
+			"event_source":  headers.Source,
+			"offset":        message.Offset,
+			"partition":     message.Partition,
+			"payload_bytes": len(message.Value),
+			"error":         err,
 		}).Error("Failed to parse event")
+		c.errorCount.Add(1)
+		c.sendToDLQ(ctx, message, eventType, "parse_error", err)
 		return nil // Не возвращаем ошибку, чтобы не блокировать обработку
 	}
 
-	// Валидируем событие
-	if err := event.Validate(); err != nil {
-		c.metrics.IncFailedEvents(string(event.Type), "validation_error")
+	// Валидируем событие (schema_error — JSON корректен, но не соответствует схеме)
+	if err := c.validateEvent(event); err != nil {
+		c.metrics.IncFailedEvents(string(event.Type), "schema_error")
 		c.logger.WithFields(logrus.Fields{
-			"event_id":   event.ID,
-			"event_type": event.Type,
-			"error":      err,
+			"event_id":      event.ID,
+			"event_type":    event.Type,
+			"offset":        message.Offset,
+			"partition":     message.Partition,
+			"payload_bytes": len(message.Value),
+			"error":         err,
 		}).Error("Event validation failed")
+		c.errorCount.Add(1)
+		c.sendToDLQ(ctx, message, event.Type, "schema_error", err)
 		return nil // Не возвращаем ошибку, чтобы не блокировать обработку
 	}
 
-	// Обрабатываем событие с retry логикой
-	if err := c.processEventWithRetry(ctx, event); err != nil {
-		c.metrics.IncFailedEvents(string(event.Type), "processing_error")
+	// Задержка конвейера от создания события; отрицательные значения (рассинхрон часов) отбрасываем
+	if latency := start.Sub(event.Timestamp); latency >= 0 {
+		c.metrics.ObserveE2ELatency(string(event.Type), latency)
+	}
+
+	// Устаревшие события пропускаем: сообщение будет закоммичено без обработки
+	if age := c.clock.Now().Sub(event.Timestamp); c.eventTTL > 0 && age > c.eventTTL {
+		c.metrics.IncFailedEvents(string(event.Type), "expired")
 		c.logger.WithFields(logrus.Fields{
 			"event_id":   event.ID,
 			"event_type": event.Type,
-			"error":      err,
+			"event_age":  age.String(),
+			"ttl":        c.eventTTL.String(),
+			"offset":     message.Offset,
+			"partition":  message.Partition,
+		}).Warn("Event expired, skipping")
+		return nil
+	}
+
+	// События с истекшим дедлайном (заголовок deadline) тоже пропускаем и коммитим
+	if c.deadlineExpired(message, event, headers) {
+		return nil
+	}
+
+	// Проверяем расхождение схемы (неизвестные поля)
+	if c.unknown != "" && c.unknown != config.UnknownFieldsIgnore {
+		if rejected := c.checkUnknownFields(ctx, message, event); rejected {
+			return nil
+		}
+	}
+
+	// Переносим correlation ID и tenant в контекст обработки
+	if id, ok := event.Metadata[domain.MetadataCorrelationID]; ok {
+		ctx = domain.WithCorrelationID(ctx, id)
+	}
+	if id, ok := event.Metadata[domain.MetadataTenantID]; ok {
+		ctx = domain.WithTenant(ctx, id)
+	}
+
+	// Обрабатываем событие с retry логикой
+	output, err := c.processEventWithRetry(ctx, event)
+	if err != nil {
+		c.metrics.IncFailedEvents(string(event.Type), "processing_error")
+		c.logger.WithFields(logrus.Fields{
+			"event_id":       event.ID,
+			"event_type":     event.Type,
+			"correlation_id": event.Metadata[domain.MetadataCorrelationID],
+			"error":          err,
 		}).Error("Failed to process event")
+		c.errorCount.Add(1)
+		c.metrics.ObserveProcessingDuration(string(event.Type), processingStatus(err), time.Since(start))
+		c.reportResult(ctx, event, err, time.Since(start))
+
+		// Сообщение, сохраненное в DLQ, можно коммитить
+		if c.sendToDLQ(ctx, message, event.Type, "processing_error", err) {
+			return nil
+		}
 		return err
 	}
 
+	// Результат трансформации пишется до коммита входного сообщения. Ошибка
+	// записи возвращается в batch: он повторяет обработку, а затем отправляет
+	// сообщение в DLQ, не коммитя offset'ы до этого (at-least-once)
+	if output != nil {
+		if err := c.output.Publish(ctx, message, output); err != nil {
+			c.metrics.IncFailedEvents(string(event.Type), "output_error")
+			c.logger.WithFields(logrus.Fields{
+				"event_id":        event.ID,
+				"event_type":      event.Type,
+				"output_event_id": output.ID,
+				"error":           err,
+			}).Error("Failed to publish transformed event")
+			c.errorCount.Add(1)
+			return err
+		}
+	}
+
 	// Записываем метрики
 	duration := time.Since(start)
-	c.metrics.IncConsumedEvents(string(event.Type))
-	c.metrics.ObserveProcessingDuration(string(event.Type), duration)
+	c.reportResult(ctx, event, nil, duration)
+	c.metrics.IncConsumedEvents(string(event.Type), c.tenants.label(message), c.headerLabels.values(message)...)
+	c.metrics.ObserveProcessingDuration(string(event.Type), "success", duration)
 
+	c.logger.WithFields(logrus.Fields{
+		"event_id":       event.ID,
+		"event_type":     event.Type,
+		"correlation_id": event.Metadata[domain.MetadataCorrelationID],
+		"duration":       duration,
+		"offset":         message.Offset,
+		"partition":      message.Partition,
+		"event_time":     event.Timestamp,
+		"kafka_time":     message.Time,
+		"payload_bytes":  len(message.Value),
+	}).Debug("Event processed successfully")
+
+	return nil
+}
+
+// validateEvent проверяет событие, если валидация не отключена
+// через CONSUMER_SKIP_VALIDATION для доверенного топика
+func (c *Consumer) validateEvent(event *domain.Event) error {
+	if c.skipValidation {
+		return nil
+	}
+	return event.ValidateAt(c.clock.Now())
+}
+
+// deadlineExpired сообщает, что дедлайн обработки события прошел.
+// Некорректный заголовок deadline не мешает обработке: событие лучше
+// обработать поздно, чем потерять из-за ошибки отправителя.
+func (c *Consumer) deadlineExpired(message kafka.Message, event *domain.Event, headers eventHeaders) bool {
+	deadline, ok, err := headers.deadline()
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"event_id":  event.ID,
+			"offset":    message.Offset,
+			"partition": message.Partition,
+			"error":     err,
+		}).Warn("Ignoring invalid deadline header")
+		return false
+	}
+	if !ok || !c.clock.Now().After(deadline) {
+		return false
+	}
+
+	c.metrics.IncFailedEvents(string(event.Type), "expired_deadline")
 	c.logger.WithFields(logrus.Fields{
 		"event_id":   event.ID,
 		"event_type": event.Type,
-		"duration":   duration,
+		"deadline":   deadline.Format(time.RFC3339Nano),
 		"offset":     message.Offset,
 		"partition":  message.Partition,
-	}).Debug("Event processed successfully")
+	}).Warn("Event deadline passed, skipping")
+	return true
+}
 
-	return nil
+// checkUnknownFields сообщает о неизвестных полях события и возвращает true,
+// если событие отклонено политикой reject
+func (c *Consumer) checkUnknownFields(ctx context.Context, message kafka.Message, event *domain.Event) bool {
+	fields, err := domain.UnknownFields(message.Value)
+	if err != nil || len(fields) == 0 {
+		return false
+	}
+
+	c.metrics.IncUnknownFields(string(event.Type))
+	c.logger.WithFields(logrus.Fields{
+		"event_id":       event.ID,
+		"event_type":     event.Type,
+		"unknown_fields": fields,
+		"policy":         c.unknown,
+	}).Warn("Event contains unknown fields")
+
+	if c.unknown != config.UnknownFieldsReject {
+		return false
+	}
+
+	c.metrics.IncFailedEvents(string(event.Type), "schema_error")
+	c.sendToDLQ(ctx, message, event.Type, "schema_error", fmt.Errorf("unknown fields: %v", fields))
+	return true
+}
+
+// sendToDLQ отправляет сообщение в DLQ и возвращает true при успешной отправке
+func (c *Consumer) sendToDLQ(ctx context.Context, message kafka.Message, eventType domain.EventType, reason string, cause error) bool {
+	if c.dlq == nil {
+		return false
+	}
+
+	if err := c.dlq.Publish(ctx, message, eventType, reason, cause); err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"event_type": eventType,
+			"offset":     message.Offset,
+			"partition":  message.Partition,
+			"error":      err,
+		}).Error("Failed to send message to DLQ")
+		return false
+	}
+
+	return true
+}
+
+// processingStatus возвращает значение метки status для неудачной обработки
+func processingStatus(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "failure"
+}
+
+// reportResult передает результат обработки в sink, а неудачные
+// результаты дополнительно сохраняет в репозиторий
+func (c *Consumer) reportResult(ctx context.Context, event *domain.Event, processErr error, duration time.Duration) {
+	if c.repository == nil && c.sink == nil {
+		return
+	}
+
+	result := &domain.ProcessingResult{
+		EventID:     event.ID,
+		EventType:   event.Type,
+		Success:     processErr == nil,
+		Duration:    duration,
+		ProcessedAt: time.Now().UTC(),
+	}
+	if processErr != nil {
+		result.Error = processErr.Error()
+	}
+
+	if c.sink != nil {
+		c.sink.Send(result)
+	}
+
+	if c.repository == nil || result.Success {
+		return
+	}
+
+	if err := c.repository.SaveResult(ctx, result); err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"event_id": event.ID,
+			"error":    err,
+		}).Warn("Failed to save processing result")
+	}
+}
+
+// ConsumeBatch читает до batchSize событий, ожидая не дольше таймаута чтения.
+// Offset'ы не коммитятся: управление ими остается за вызывающим кодом.
+// Метод не предназначен для одновременного использования со Start.
+func (c *Consumer) ConsumeBatch(ctx context.Context, batchSize int) ([]*domain.Event, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return nil, fmt.Errorf("consumer is closed")
+	}
+	reader := c.reader
+	c.mu.RUnlock()
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.readTimeout)
+	defer cancel()
+
+	events := make([]*domain.Event, 0, batchSize)
+	for len(events) < batchSize {
+		message, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return events, ctx.Err()
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return events, fmt.Errorf("failed to fetch message: %w", err)
+		}
+		c.markReady()
+		c.recordMessage(message)
+
+		event, err := domain.DefaultCodec.DecodeMessage(message.Value, parseEventHeaders(message).DataEncoding)
+		if err != nil {
+			c.errorCount.Add(1)
+			c.metrics.IncFailedEvents("unknown", "parse_error")
+			c.logger.WithFields(logrus.Fields{
+				"offset":        message.Offset,
+				"partition":     message.Partition,
+				"payload_bytes": len(message.Value),
+				"error":         err,
+			}).Error("Failed to parse event")
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// SetReader заменяет reader, созданный NewConsumer; тот закрывается и
+// покидает consumer group, если успел к ней подключиться. Вызывается до Start.
+func (c *Consumer) SetReader(reader MessageReader) {
+	if err := c.reader.Close(); err != nil {
+		c.logger.WithError(err).Warn("Failed to close replaced Kafka reader")
+	}
+
+	c.mu.Lock()
+	c.reader = reader
+	c.mu.Unlock()
+}
+
+// SetClock подменяет источник времени для проверки timestamp и TTL событий
+// и расчета времени нахождения сообщений в брокере.
+// Вызывать до Start.
+func (c *Consumer) SetClock(clock domain.Clock) {
+	c.clock = clock
+}
+
+// Ready сообщает, подтвердил ли consumer связь с брокером: до первого
+// успешного чтения (или метаданных топика при пустом топике) /ready отвечает 503
+func (c *Consumer) Ready() bool {
+	return c.ready.Load()
 }
 
-// batchCommitter коммитит сообщения batch'ами
+// markReady отмечает подтвержденную связь с брокером
+func (c *Consumer) markReady() {
+	if c.ready.CompareAndSwap(false, true) {
+		c.logger.Info("Kafka connectivity confirmed, consumer is ready")
+	}
+}
+
+// confirmIdleConnectivity проверяет метаданные топика, если чтение истекло по
+// таймауту до первого сообщения: пустой топик не должен держать /ready в 503
+func (c *Consumer) confirmIdleConnectivity(ctx context.Context) {
+	if c.ready.Load() {
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, c.readTimeout)
+	defer cancel()
+
+	if err := c.checkTopic(checkCtx); err != nil {
+		c.logger.WithError(err).Debug("Kafka connectivity not confirmed yet")
+		return
+	}
+	c.markReady()
+}
+
+// observeDwell записывает, сколько сообщение пролежало в брокере до чтения.
+// В отличие от e2e латентности (от времени события до обработки) сюда не входит
+// ожидание в очереди worker'ов и сама обработка: рост метрики указывает на lag,
+// а не на медленную обработку. Сообщения без времени и с временем из будущего
+// (рассинхрон часов) не учитываются.
+func (c *Consumer) observeDwell(message kafka.Message) {
+	if message.Time.IsZero() {
+		return
+	}
+	if dwell := c.clock.Now().Sub(message.Time); dwell >= 0 {
+		c.metrics.ObserveMessageDwell(dwell)
+	}
+}
+
+// recordMessage учитывает прочитанное сообщение в статистике и гистограмме размеров
+func (c *Consumer) recordMessage(message kafka.Message) {
+	c.messagesConsumed.Add(1)
+	c.bytesConsumed.Add(int64(len(message.Value)))
+	c.metrics.ObserveMessageSize(message.Topic, len(message.Value))
+	c.lastMessageTime.Store(time.Now().UnixNano())
+}
+
+// Stats возвращает статистику чтения; Lag берется из статистики reader'а
+func (c *Consumer) Stats() domain.ConsumerStats {
+	stats := domain.ConsumerStats{
+		MessagesConsumed: c.messagesConsumed.Load(),
+		BytesConsumed:    c.bytesConsumed.Load(),
+		Errors:           c.errorCount.Load(),
+		Lag:              c.reader.Stats().Lag,
+	}
+
+	if ts := c.lastMessageTime.Load(); ts != 0 {
+		last := time.Unix(0, ts).UTC()
+		stats.LastMessageTime = &last
+	}
+
+	return stats
+}
+
+// batchCommitter коммитит сообщения batch'ами: до batchSize обработанных
+// сообщений, но не реже commitInterval. Он работает до закрытия commitChan
+// (после выхода всех worker'ов), чтобы закоммитить все обработанное;
+// финальный коммит идет с отдельным контекстом, так как ctx к этому
+// моменту обычно уже отменен.
 func (c *Consumer) batchCommitter(ctx context.Context) {
 	defer c.wg.Done()
-	defer close(c.commitChan)
 
-	ticker := time.NewTicker(time.Second) // Коммитим каждую секунду
+	ticker := time.NewTicker(c.commitInterval)
 	defer ticker.Stop()
 
-	var batch []kafka.Message
+	var batch, failed []kafka.Message
+	var failures []error
+	var blocked bool
 	maxBatchSize := c.batchSize
 
-	commitBatch := func() {
-		if len(batch) == 0 {
+	commitBatch := func(ctx context.Context) {
+		// После неразрешенного batch'а ничего больше не коммитится: offset'ы
+		// более поздних сообщений закоммитили бы и его
+		if blocked || len(batch) == 0 {
 			return
 		}
 
+		// Режим batch: сообщения с ошибкой обрабатываются повторно, а оставшиеся
+		// отправляются в DLQ; только после этого коммитится весь batch
+		if len(failed) > 0 {
+			if !c.resolveBatchFailures(ctx, failed, failures) {
+				c.metrics.IncCommitFailures("batch_failed")
+				c.logger.WithFields(logrus.Fields{
+					"batch_size": len(batch),
+					"failed":     len(failed),
+				}).Error("Message batch not resolved before shutdown, offsets not committed")
+				blocked = true
+				return
+			}
+			failed, failures = failed[:0], failures[:0]
+		}
+
 		start := time.Now()
 		if err := c.commitMessages(ctx, batch); err != nil {
+			c.metrics.IncCommitFailures(commitErrorClass(err))
 			c.logger.WithError(err).Error("Failed to commit message batch")
 		} else {
 			c.metrics.ObserveCommitDuration(time.Since(start))
 			c.logger.WithField("batch_size", len(batch)).Debug("Committed message batch")
+			c.endWarmup("first commit")
 		}
 		batch = batch[:0] // Очищаем batch
 	}
 
 	for {
 		select {
-		case <-ctx.Done():
-			c.logger.Info("Batch committer context cancelled, committing final batch")
-			commitBatch()
-			return
 		case <-ticker.C:
-			commitBatch()
-		case message, ok := <-c.commitChan:
+			if ctx.Err() == nil {
+				commitBatch(ctx)
+			}
+		case item, ok := <-c.commitChan:
 			if !ok {
 				c.logger.Info("Commit channel closed, committing final batch")
-				commitBatch()
+				finalCtx, cancel := c.finalCommitContext(ctx)
+				commitBatch(finalCtx)
+				cancel()
 				return
 			}
 
-			batch = append(batch, message)
+			if item.err != nil {
+				failed = append(failed, item.message)
+				failures = append(failures, item.err)
+			}
+			batch = append(batch, item.message)
 			if len(batch) >= maxBatchSize {
-				commitBatch()
+				commitBatch(ctx)
 			}
 		}
 	}
 }
 
-// processEventWithRetry обрабатывает событие с retry логикой
-func (c *Consumer) processEventWithRetry(ctx context.Context, event *domain.Event) error {
+// resolveBatchFailures повторно обрабатывает сообщения batch'а, завершившиеся
+// ошибкой, до MaxRetries раз, а оставшиеся отправляет в DLQ, повторяя запись
+// до успеха. Возвращает false, если ctx отменен раньше, чем все сообщения
+// обработаны или сохранены в DLQ.
+func (c *Consumer) resolveBatchFailures(ctx context.Context, failed []kafka.Message, failures []error) bool {
+	c.logger.WithField("failed", len(failed)).Warn("Message batch has failed messages, retrying them")
+
+	pending, causes := failed, failures
+	for attempt := 1; attempt <= c.config.MaxRetries && len(pending) > 0; attempt++ {
+		if !sleepContext(ctx, time.Duration(attempt)*c.config.RetryBackoff) {
+			return false
+		}
+
+		var stillPending []kafka.Message
+		var stillCauses []error
+		for _, message := range pending {
+			if err := c.processMessage(ctx, message); err != nil {
+				stillPending = append(stillPending, message)
+				stillCauses = append(stillCauses, err)
+			}
+		}
+		pending, causes = stillPending, stillCauses
+	}
+
+	for i, message := range pending {
+		eventType := parseEventHeaders(message).typeOrUnknown()
+		for !c.sendToDLQ(ctx, message, eventType, "batch_failed", causes[i]) {
+			if !sleepContext(ctx, c.config.RetryBackoff) {
+				return false
+			}
+		}
+		c.metrics.IncFailedEvents(string(eventType), "batch_failed")
+	}
+	return true
+}
+
+// finalCommitContext возвращает контекст финального коммита: не зависит от
+// отмены ctx и ограничен KAFKA_CLOSE_TIMEOUT
+func (c *Consumer) finalCommitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if c.config.CloseTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.config.CloseTimeout)
+}
+
+// processEventWithRetry обрабатывает событие с retry логикой. На stream-этапе
+// возвращает событие для выходного топика (nil — публиковать нечего).
+func (c *Consumer) processEventWithRetry(ctx context.Context, event *domain.Event) (*domain.Event, error) {
 	var lastErr error
 
 	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
@@ -337,20 +1277,56 @@ func (c *Consumer) processEventWithRetry(ctx context.Context, event *domain.Even
 
 			select {
 			case <-ctx.Done():
-				return ctx.Err()
+				return nil, ctx.Err()
 			case <-time.After(backoff):
 			}
 		}
 
+		if c.transformer != nil {
+			output, err := c.transformer.TransformEvent(ctx, event)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return output, nil
+		}
+
 		if err := c.processor.ProcessEvent(ctx, event); err != nil {
 			lastErr = err
 			continue
 		}
 
-		return nil
+		return nil, nil
 	}
 
-	return fmt.Errorf("failed to process event after %d attempts: %w", c.config.MaxRetries, lastErr)
+	return nil, fmt.Errorf("failed to process event after %d attempts: %w", c.config.MaxRetries, lastErr)
+}
+
+// commitErrorClass классифицирует ошибку коммита для метрик
+func commitErrorClass(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "other"
+}
+
+// isAuthError определяет ошибки аутентификации SASL и авторизации ACL,
+// которые не проходят при повторе. Ошибки без кода Kafka (например, от
+// SASL механизма при установке соединения) распознаются по тексту.
+func isAuthError(err error) bool {
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		switch kafkaErr {
+		case kafka.SASLAuthenticationFailed, kafka.UnsupportedSASLMechanism, kafka.IllegalSASLState,
+			kafka.TopicAuthorizationFailed, kafka.GroupAuthorizationFailed, kafka.ClusterAuthorizationFailed:
+			return true
+		}
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "sasl") && strings.Contains(msg, "authentication")
 }
 
 // commitMessages коммитит batch сообщений
@@ -359,32 +1335,80 @@ func (c *Consumer) commitMessages(ctx context.Context, messages []kafka.Message)
 	reader := c.reader
 	c.mu.RUnlock()
 
-	if err := reader.CommitMessages(ctx, messages...); err != nil {
-		return fmt.Errorf("failed to commit messages: %w", err)
+	if !c.offsetStoreOnly {
+		if err := reader.CommitMessages(ctx, messages...); err != nil {
+			return fmt.Errorf("failed to commit messages: %w", err)
+		}
 	}
 
+	if c.offsetStore != nil {
+		return c.storeOffsets(ctx, messages)
+	}
 	return nil
 }
 
 // Close закрывает consumer
 func (c *Consumer) Close() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	if c.closed {
+		c.mu.Unlock()
 		return nil
 	}
-
 	c.closed = true
+	reader := c.reader
+	c.mu.Unlock()
+
 	c.logger.Info("Closing Kafka consumer")
 
-	// Ждем завершения горутин
+	// Ждем завершения горутин без блокировки: финальный коммит
+	// batchCommitter'а берет reader под c.mu
 	c.wg.Wait()
 
-	if err := c.reader.Close(); err != nil {
+	if c.dlq != nil {
+		if err := c.dlq.Close(); err != nil {
+			c.logger.WithError(err).Error("Failed to close DLQ publisher")
+		}
+	}
+
+	if c.output != nil {
+		if err := c.output.Close(); err != nil {
+			c.logger.WithError(err).Error("Failed to close output publisher")
+		}
+	}
+
+	if err := closeWithTimeout(reader, c.config.CloseTimeout); err != nil {
+		if errors.Is(err, ErrShutdownTimeout) {
+			c.logger.WithField("timeout", c.config.CloseTimeout).
+				Error("Kafka reader close timed out, leaving it behind")
+			return err
+		}
 		return fmt.Errorf("failed to close kafka reader: %w", err)
 	}
 
 	c.logger.Info("Kafka consumer closed")
 	return nil
 }
+
+// closeWithTimeout закрывает closer, не дожидаясь его дольше timeout.
+// При таймауте горутина закрытия продолжает работу (утечка до завершения процесса),
+// зато остановка сервиса не зависает на недоступном брокере.
+func closeWithTimeout(closer io.Closer, timeout time.Duration) error {
+	if timeout <= 0 {
+		return closer.Close()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- closer.Close()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w after %s", ErrShutdownTimeout, timeout)
+	}
+}
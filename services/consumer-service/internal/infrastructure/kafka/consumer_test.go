@@ -0,0 +1,1090 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+	"consumer-service/internal/infrastructure/metrics"
+	"consumer-service/internal/infrastructure/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeReader отдает заранее заданные сообщения, затем ждет отмены контекста
+type fakeReader struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.messages) > 0 {
+		message := r.messages[0]
+		r.messages = r.messages[1:]
+		r.mu.Unlock()
+		return message, nil
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return r.ReadMessage(ctx)
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Stats() kafka.ReaderStats { return kafka.ReaderStats{} }
+
+func (r *fakeReader) Close() error { return nil }
+
+// failingProcessor завершает обработку каждого события ошибкой
+type failingProcessor struct{}
+
+func (failingProcessor) ProcessEvent(context.Context, *domain.Event) error {
+	return errors.New("downstream unavailable")
+}
+
+func eventMessages(t *testing.T, offsets int) []kafka.Message {
+	t.Helper()
+
+	messages := make([]kafka.Message, 0, offsets)
+	for i := 0; i < offsets; i++ {
+		event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		payload, err := event.ToJSON()
+		if err != nil {
+			t.Fatalf("ToJSON: %v", err)
+		}
+		messages = append(messages, kafka.Message{Topic: "events", Offset: int64(i), Value: payload})
+	}
+	return messages
+}
+
+// flakyWriter отклоняет запись, пока down=true
+type flakyWriter struct {
+	mu       sync.Mutex
+	down     bool
+	attempts int
+	written  []kafka.Message
+}
+
+func (w *flakyWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts++
+	if w.down {
+		return errors.New("dlq unavailable")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *flakyWriter) Close() error { return nil }
+
+func (w *flakyWriter) setDown(down bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.down = down
+}
+
+func (w *flakyWriter) stats() (attempts, written int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.attempts, len(w.written)
+}
+
+// flakyProcessor завершает ошибкой обработку события failID, пока down=true
+type flakyProcessor struct {
+	failID string
+	down   atomic.Bool
+	calls  atomic.Int64 // попытки обработки события failID
+}
+
+func (p *flakyProcessor) ProcessEvent(_ context.Context, event *domain.Event) error {
+	if event.ID != p.failID {
+		return nil
+	}
+	p.calls.Add(1)
+	if p.down.Load() {
+		return errors.New("downstream unavailable")
+	}
+	return nil
+}
+
+func TestConsumerBatchCommitsOnlyResolvedBatch(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	const total = 10
+	tests := []struct {
+		name string
+		// recover восстанавливает обработку или DLQ после того, как batch
+		// дошел до повторов; до этого batch не должен коммититься
+		stalled func(*flakyProcessor, *flakyWriter) bool
+		recover func(*flakyProcessor, *flakyWriter)
+		wantDLQ int
+	}{
+		{
+			name: "processing recovers on batch retry",
+			// Первая обработка: исходная попытка и MaxRetries повторов
+			stalled: func(p *flakyProcessor, _ *flakyWriter) bool { return p.calls.Load() > 4 },
+			recover: func(p *flakyProcessor, _ *flakyWriter) { p.down.Store(false) },
+			wantDLQ: 0,
+		},
+		{
+			name: "failing message sent to DLQ",
+			// Попытки записи в DLQ: при обработке, в каждом повторе batch'а
+			// и первая попытка финальной отправки
+			stalled: func(_ *flakyProcessor, w *flakyWriter) bool {
+				attempts, _ := w.stats()
+				return attempts > 4
+			},
+			recover: func(_ *flakyProcessor, w *flakyWriter) { w.setDown(false) },
+			wantDLQ: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := eventMessages(t, total)
+			failing, err := domain.FromJSON(messages[3].Value)
+			if err != nil {
+				t.Fatalf("FromJSON: %v", err)
+			}
+			processor := &flakyProcessor{failID: failing.ID}
+			processor.down.Store(true)
+
+			// Один batch на все сообщения: коммит по размеру, а не по таймеру
+			consumer, err := NewConsumer(
+				config.KafkaConfig{
+					Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", DLQTopics: "default:dlq",
+					ReadTimeout: time.Minute, CommitInterval: time.Minute, CloseTimeout: time.Second,
+					MaxRetries: 3, RetryBackoff: 10 * time.Millisecond,
+				},
+				config.ConsumerConfig{WorkerCount: 4, BatchSize: total, CommitMode: config.CommitModeBatch},
+				processor, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			writer := &flakyWriter{down: true}
+			consumer.dlq.writer = writer
+			reader := &fakeReader{messages: messages}
+			consumer.SetReader(reader)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			startErr := make(chan error, 1)
+			go func() { startErr <- consumer.Start(ctx) }()
+			defer func() {
+				cancel()
+				if err := <-startErr; err != nil {
+					t.Errorf("Start: %v", err)
+				}
+			}()
+
+			committed := func() int {
+				reader.mu.Lock()
+				defer reader.mu.Unlock()
+				return len(reader.committed)
+			}
+
+			waitForCondition(t, func() bool { return tt.stalled(processor, writer) })
+			if got := committed(); got != 0 {
+				t.Fatalf("committed %d messages while the batch has a failed message", got)
+			}
+
+			tt.recover(processor, writer)
+			waitForCondition(t, func() bool { return committed() == total })
+			if _, written := writer.stats(); written != tt.wantDLQ {
+				t.Fatalf("DLQ received %d messages, want %d", written, tt.wantDLQ)
+			}
+		})
+	}
+}
+
+// saturationRecorder запоминает вызовы SetSaturated и AddEnqueueBlocked
+type saturationRecorder struct {
+	*metrics.ConsumerMetrics
+	mu        sync.Mutex
+	saturated []bool
+	blocked   time.Duration
+}
+
+func (m *saturationRecorder) SetSaturated(saturated bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saturated = append(m.saturated, saturated)
+}
+
+func (m *saturationRecorder) AddEnqueueBlocked(blocked time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocked += blocked
+}
+
+func TestEnqueueReportsSaturation(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, priorities := range []string{"", "user_created:2,default:1"} {
+		t.Run("priorities="+priorities, func(t *testing.T) {
+			recorder := &saturationRecorder{ConsumerMetrics: metrics.NewConsumerMetrics(prometheus.NewRegistry())}
+			consumer, err := NewConsumer(
+				config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test"},
+				config.ConsumerConfig{WorkerCount: 1, BatchSize: 10, TypePriorities: priorities},
+				failingProcessor{}, nil, nil, logger, recorder,
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			defer consumer.reader.Close()
+
+			// Worker'ы не запущены: очередь емкостью 2 заполняется, третье сообщение ждет
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			message := queuedMessage{message: kafka.Message{Headers: []kafka.Header{{Key: headerEventType, Value: []byte("user_created")}}}}
+			for i := 0; i < 2; i++ {
+				if !consumer.enqueue(ctx, message) {
+					t.Fatalf("enqueue %d failed with free queue capacity", i)
+				}
+			}
+			if len(recorder.saturated) != 0 {
+				t.Fatalf("saturation reported with free queue capacity: %v", recorder.saturated)
+			}
+
+			if consumer.enqueue(ctx, message) {
+				t.Fatal("enqueue into a full queue succeeded")
+			}
+			if len(recorder.saturated) != 2 || !recorder.saturated[0] || recorder.saturated[1] {
+				t.Fatalf("SetSaturated calls = %v, want [true false]", recorder.saturated)
+			}
+			if recorder.blocked <= 0 {
+				t.Fatal("blocked enqueue time not recorded")
+			}
+		})
+	}
+}
+
+// runUntilRead запускает consumer, дожидается чтения всех сообщений reader'а
+// и останавливает consumer через Drain
+func runUntilRead(t *testing.T, consumer *Consumer, reader *fakeReader) {
+	t.Helper()
+
+	startErr := make(chan error, 1)
+	go func() { startErr <- consumer.Start(context.Background()) }()
+
+	waitForCondition(t, func() bool {
+		reader.mu.Lock()
+		defer reader.mu.Unlock()
+		return len(reader.messages) == 0
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := consumer.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if err := <-startErr; err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+}
+
+// offsets возвращает offset'ы сообщений
+func offsets(messages []kafka.Message) []int64 {
+	result := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		result = append(result, message.Offset)
+	}
+	return result
+}
+
+func TestConsumerPipelineWithFakeReader(t *testing.T) {
+	tests := []struct {
+		name          string
+		corrupt       int64 // offset сообщения с поврежденным телом; -1 — нет
+		failing       int64 // offset события, обработка которого падает; -1 — нет
+		wantProcessed []int64
+		wantCommitted []int64
+	}{
+		{name: "all processed and committed", corrupt: -1, failing: -1, wantProcessed: []int64{0, 1, 2}, wantCommitted: []int64{0, 1, 2}},
+		// Неразбираемое сообщение не обрабатывается, но коммитится
+		{name: "corrupt message skipped", corrupt: 1, failing: -1, wantProcessed: []int64{0, 2}, wantCommitted: []int64{0, 1, 2}},
+		// Без DLQ offset упавшего события не коммитится
+		{name: "processing failure not committed", corrupt: -1, failing: 1, wantProcessed: []int64{0, 2}, wantCommitted: []int64{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := eventMessages(t, 3)
+			ids := eventIDs(t, messages)
+			if tt.corrupt >= 0 {
+				messages[tt.corrupt].Value = []byte(`{"id": "user_created_1", "type":`)
+			}
+			processor := &pipelineProcessor{}
+			if tt.failing >= 0 {
+				processor.failID = ids[tt.failing]
+			}
+			consumer, reader := newTestConsumer(t, config.ConsumerConfig{CommitMode: config.CommitModeMessage}, processor, metrics.NewConsumerMetrics(prometheus.NewRegistry()), messages...)
+			runUntilRead(t, consumer, reader)
+
+			var wantIDs []string
+			for _, offset := range tt.wantProcessed {
+				wantIDs = append(wantIDs, ids[offset])
+			}
+			if got := processor.processed(); !reflect.DeepEqual(got, wantIDs) {
+				t.Fatalf("processed = %v, want %v", got, wantIDs)
+			}
+			reader.mu.Lock()
+			defer reader.mu.Unlock()
+			if got := offsets(reader.committed); !reflect.DeepEqual(got, tt.wantCommitted) {
+				t.Fatalf("committed offsets = %v, want %v", got, tt.wantCommitted)
+			}
+		})
+	}
+}
+
+// pipelineProcessor запоминает ID обработанных событий; обработка события failID падает
+type pipelineProcessor struct {
+	recordingProcessor
+	failID string
+}
+
+func (p *pipelineProcessor) ProcessEvent(ctx context.Context, event *domain.Event) error {
+	if event.ID == p.failID {
+		return errors.New("downstream unavailable")
+	}
+	return p.recordingProcessor.ProcessEvent(ctx, event)
+}
+
+// recordingProcessor запоминает ID обработанных событий в порядке обработки
+type recordingProcessor struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (p *recordingProcessor) ProcessEvent(_ context.Context, event *domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids = append(p.ids, event.ID)
+	return nil
+}
+
+func (p *recordingProcessor) processed() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.ids...)
+}
+
+// eventIDs возвращает ID событий в сообщениях
+func eventIDs(t *testing.T, messages []kafka.Message) []string {
+	t.Helper()
+
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		event, err := domain.FromJSON(message.Value)
+		if err != nil {
+			t.Fatalf("FromJSON: %v", err)
+		}
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestConsumerBackfillStopsAtEndOffset(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name      string
+		offsets   []int64
+		endOffset int64
+		// Индексы сообщений, которые должны быть обработаны
+		want []int
+	}{
+		{name: "stops at end offset", offsets: []int64{0, 1, 2, 3, 4, 5, 6}, endOffset: 3, want: []int{0, 1, 2, 3}},
+		{name: "single offset", offsets: []int64{0, 1, 2}, endOffset: 0, want: []int{0}},
+		{name: "end offset compacted away", offsets: []int64{0, 2, 4, 6, 8}, endOffset: 5, want: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := eventMessages(t, len(tt.offsets))
+			for i, offset := range tt.offsets {
+				messages[i].Offset = offset
+			}
+
+			processor := &recordingProcessor{}
+			consumer, err := NewConsumer(
+				config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", ReadTimeout: time.Minute},
+				config.ConsumerConfig{
+					WorkerCount: 1, BatchSize: 10, CommitMode: config.CommitModeMessage,
+					Backfill: config.BackfillConfig{Enabled: true, EndOffset: tt.endOffset},
+				},
+				processor, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			reader := &fakeReader{messages: messages}
+			consumer.SetReader(reader)
+
+			// Consumer останавливается сам, дочитав диапазон
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := consumer.Start(ctx); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("backfill did not stop at the end offset")
+			}
+
+			ids := eventIDs(t, messages)
+			want := make([]string, 0, len(tt.want))
+			for _, i := range tt.want {
+				want = append(want, ids[i])
+			}
+			if got := processor.processed(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("processed %v, want %v", got, want)
+			}
+			if len(reader.committed) != 0 {
+				t.Fatalf("backfill committed %d messages, want none", len(reader.committed))
+			}
+		})
+	}
+}
+
+func TestConsumerConsumeBatch(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name      string
+		available int
+		corrupt   bool // первое сообщение не разбирается
+		batchSize int
+		cancel    bool // ctx отменяется во время ожидания
+		want      int
+		wantErr   error
+	}{
+		{name: "limited by batch size", available: 10, batchSize: 4, want: 4},
+		{name: "fewer available than batch size", available: 3, batchSize: 5, want: 3},
+		{name: "corrupt message skipped", available: 3, corrupt: true, batchSize: 5, want: 2},
+		{name: "context cancelled", available: 2, batchSize: 5, cancel: true, want: 2, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// При отмене ctx таймаут чтения не должен истечь раньше
+			readTimeout := 20 * time.Millisecond
+			if tt.cancel {
+				readTimeout = time.Minute
+			}
+			consumer, err := NewConsumer(
+				config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", ReadTimeout: readTimeout},
+				config.ConsumerConfig{WorkerCount: 1, BatchSize: 10, CommitMode: config.CommitModeMessage},
+				&recordingProcessor{}, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			messages := eventMessages(t, tt.available)
+			if tt.corrupt {
+				messages[0].Value = []byte("{not json")
+			}
+			reader := &fakeReader{messages: messages}
+			consumer.SetReader(reader)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			start := time.Now()
+			events, err := consumer.ConsumeBatch(ctx, tt.batchSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConsumeBatch() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(events) != tt.want {
+				t.Fatalf("ConsumeBatch() returned %d events, want %d", len(events), tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("ConsumeBatch() took %v", elapsed)
+			}
+			if len(reader.committed) != 0 {
+				t.Fatalf("ConsumeBatch committed %d messages, want none", len(reader.committed))
+			}
+		})
+	}
+}
+
+func TestConsumerStats(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name       string
+		messages   int
+		processor  EventProcessor
+		wantErrors int64
+	}{
+		{name: "no messages", messages: 0, processor: &recordingProcessor{}},
+		{name: "processed", messages: 5, processor: &recordingProcessor{}},
+		{name: "processing failures", messages: 3, processor: failingProcessor{}, wantErrors: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			consumer, err := NewConsumer(
+				config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", ReadTimeout: time.Minute, CloseTimeout: time.Second},
+				config.ConsumerConfig{WorkerCount: 2, BatchSize: 10, CommitMode: config.CommitModeMessage},
+				tt.processor, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			messages := eventMessages(t, tt.messages)
+			var wantBytes int64
+			for _, message := range messages {
+				wantBytes += int64(len(message.Value))
+			}
+			reader := &lagReader{fakeReader: fakeReader{messages: messages}}
+			reader.lag.Store(7)
+			consumer.SetReader(reader)
+
+			before := time.Now()
+			runUntilRead(t, consumer, &reader.fakeReader)
+
+			stats := consumer.Stats()
+			if stats.MessagesConsumed != int64(tt.messages) || stats.BytesConsumed != wantBytes || stats.Errors != tt.wantErrors || stats.Lag != 7 {
+				t.Fatalf("Stats() = %+v, want %d messages, %d bytes, %d errors, lag 7", stats, tt.messages, wantBytes, tt.wantErrors)
+			}
+			switch {
+			case tt.messages == 0 && stats.LastMessageTime != nil:
+				t.Fatalf("LastMessageTime = %v without messages, want nil", stats.LastMessageTime)
+			case tt.messages > 0 && (stats.LastMessageTime == nil || stats.LastMessageTime.Before(before)):
+				t.Fatalf("LastMessageTime = %v, want a time after %v", stats.LastMessageTime, before)
+			}
+		})
+	}
+}
+
+// newTestConsumer создает consumer поверх fakeReader с сообщениями messages
+func newTestConsumer(t *testing.T, consumerCfg config.ConsumerConfig, processor EventProcessor, consumerMetrics ConsumerMetrics, messages ...kafka.Message) (*Consumer, *fakeReader) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	if consumerCfg.WorkerCount == 0 && !consumerCfg.StrictOrder {
+		consumerCfg.WorkerCount = 1
+	}
+	if consumerCfg.BatchSize == 0 {
+		consumerCfg.BatchSize = 10
+	}
+	consumer, err := NewConsumer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", ReadTimeout: time.Minute, CloseTimeout: time.Second},
+		consumerCfg, processor, nil, nil, logger, consumerMetrics,
+	)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	reader := &fakeReader{messages: messages}
+	consumer.SetReader(reader)
+	return consumer, reader
+}
+
+// metricValue возвращает значение счетчика или число наблюдений гистограммы
+// в серии name с метками labels; 0, если серии нет
+func metricValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			pairs := metric.GetLabel()
+			if len(pairs) != len(labels) {
+				continue
+			}
+			for _, pair := range pairs {
+				if value, ok := labels[pair.GetName()]; !ok || value != pair.GetValue() {
+					continue metrics
+				}
+			}
+			if histogram := metric.GetHistogram(); histogram != nil {
+				return float64(histogram.GetSampleCount())
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// timeoutProcessor завершает обработку по истечении дедлайна
+type timeoutProcessor struct{}
+
+func (timeoutProcessor) ProcessEvent(context.Context, *domain.Event) error {
+	return fmt.Errorf("downstream call: %w", context.DeadlineExceeded)
+}
+
+func TestProcessingDurationStatusLabel(t *testing.T) {
+	tests := []struct {
+		name       string
+		processor  EventProcessor
+		wantStatus string
+	}{
+		{name: "success", processor: &recordingProcessor{}, wantStatus: "success"},
+		{name: "failure", processor: failingProcessor{}, wantStatus: "failure"},
+		{name: "timeout", processor: timeoutProcessor{}, wantStatus: "timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{}, tt.processor, metrics.NewConsumerMetrics(reg))
+
+			_ = consumer.processMessage(context.Background(), eventMessages(t, 1)[0])
+
+			for _, status := range []string{"success", "failure", "timeout"} {
+				want := 0.0
+				if status == tt.wantStatus {
+					want = 1
+				}
+				labels := map[string]string{"event_type": string(domain.UserCreatedEvent), "status": status}
+				if got := metricValue(t, reg, "consumer_processing_duration_seconds", labels); got != want {
+					t.Fatalf("observations with status %q = %v, want %v", status, got, want)
+				}
+			}
+		})
+	}
+}
+
+// eventMessageAt создает сообщение с событием, созданным в момент at
+func eventMessageAt(t *testing.T, at time.Time) kafka.Message {
+	t.Helper()
+
+	event, err := domain.NewEventFactory(nil).WithClock(domain.NewFakeClock(at)).NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	payload, err := event.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	return kafka.Message{Topic: "events", Value: payload}
+}
+
+func TestConsumerSkipsExpiredEvents(t *testing.T) {
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		age           time.Duration
+		wantProcessed bool
+	}{
+		{name: "fresh event processed", ttl: time.Hour, age: 10 * time.Minute, wantProcessed: true},
+		{name: "aged event skipped", ttl: time.Hour, age: 2 * time.Hour},
+		{name: "TTL disabled", ttl: 0, age: 48 * time.Hour, wantProcessed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			processor := &recordingProcessor{}
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{EventTTL: tt.ttl}, processor, metrics.NewConsumerMetrics(reg))
+
+			// Пропущенное событие коммитится: processMessage не возвращает ошибку
+			if err := consumer.processMessage(context.Background(), eventMessageAt(t, time.Now().Add(-tt.age))); err != nil {
+				t.Fatalf("processMessage: %v", err)
+			}
+
+			if processed := len(processor.processed()) == 1; processed != tt.wantProcessed {
+				t.Fatalf("event processed = %v, want %v", processed, tt.wantProcessed)
+			}
+			wantExpired := 1.0
+			if tt.wantProcessed {
+				wantExpired = 0
+			}
+			labels := map[string]string{"event_type": string(domain.UserCreatedEvent), "reason": "expired"}
+			if got := metricValue(t, reg, "consumer_events_failed_total", labels); got != wantExpired {
+				t.Fatalf("expired events = %v, want %v", got, wantExpired)
+			}
+		})
+	}
+}
+
+func TestConsumerJSONLines(t *testing.T) {
+	messages := eventMessages(t, 3)
+	ids := eventIDs(t, messages)
+	line := func(i int) string { return string(messages[i].Value) }
+
+	tests := []struct {
+		name         string
+		jsonLines    bool
+		value        string
+		want         []string
+		wantParseErr float64
+	}{
+		{name: "each line processed", jsonLines: true, value: line(0) + "\n" + line(1) + "\n" + line(2), want: ids},
+		{name: "blank lines ignored", jsonLines: true, value: "\n" + line(0) + "\n\n" + line(1) + "\r\n", want: ids[:2]},
+		{name: "single event", jsonLines: true, value: line(2), want: ids[2:]},
+		{name: "corrupt line skipped", jsonLines: true, value: line(0) + "\n{not json\n" + line(2), want: []string{ids[0], ids[2]}, wantParseErr: 1},
+		{name: "mode disabled", jsonLines: false, value: line(0) + "\n" + line(1), want: nil, wantParseErr: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			processor := &recordingProcessor{}
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{JSONLines: tt.jsonLines}, processor, metrics.NewConsumerMetrics(reg))
+
+			message := kafka.Message{Topic: "events", Value: []byte(tt.value)}
+			if err := consumer.processMessage(context.Background(), message); err != nil {
+				t.Fatalf("processMessage: %v", err)
+			}
+
+			if got := processor.processed(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("processed %v, want %v", got, tt.want)
+			}
+			labels := map[string]string{"event_type": "unknown", "reason": "parse_error"}
+			if got := metricValue(t, reg, "consumer_events_failed_total", labels); got != tt.wantParseErr {
+				t.Fatalf("parse errors = %v, want %v", got, tt.wantParseErr)
+			}
+		})
+	}
+}
+
+// errReader завершает каждое чтение ошибкой err
+type errReader struct {
+	fakeReader
+	err error
+}
+
+func (r *errReader) FetchMessage(context.Context) (kafka.Message, error) {
+	return kafka.Message{}, r.err
+}
+
+func TestConsumerStopsOnAuthError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantStop bool
+	}{
+		{name: "SASL authentication failed", err: kafka.SASLAuthenticationFailed, wantStop: true},
+		{name: "topic authorization failed", err: fmt.Errorf("fetch: %w", kafka.TopicAuthorizationFailed), wantStop: true},
+		{name: "SASL handshake error text", err: errors.New("SASL handshake failed: authentication rejected"), wantStop: true},
+		{name: "transient error retried", err: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{}, &recordingProcessor{}, metrics.NewConsumerMetrics(prometheus.NewRegistry()))
+			consumer.config.RetryBackoff = time.Millisecond
+			consumer.SetReader(&errReader{err: tt.err})
+
+			// Повторяемая ошибка не останавливает consumer до отмены контекста
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			time.AfterFunc(200*time.Millisecond, cancel)
+			err := consumer.Start(ctx)
+
+			if !tt.wantStop {
+				if err != nil {
+					t.Fatalf("Start() = %v, want nil after context cancellation", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) || !strings.Contains(err.Error(), "kafka authentication failed") {
+				t.Fatalf("Start() = %v, want authentication error wrapping %v", err, tt.err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("Start returned only after the context was cancelled")
+			}
+		})
+	}
+}
+
+func TestConsumerClockBoundaries(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	const ttl = time.Hour
+
+	tests := []struct {
+		name          string
+		created       time.Time
+		wantProcessed bool
+		wantReason    string // причина в consumer_events_failed_total
+	}{
+		{name: "age equals TTL", created: now.Add(-ttl), wantProcessed: true},
+		{name: "age just over TTL", created: now.Add(-ttl - time.Nanosecond), wantReason: "expired"},
+		{name: "timestamp at the allowed skew", created: now.Add(time.Minute), wantProcessed: true},
+		{name: "timestamp beyond the allowed skew", created: now.Add(time.Minute + time.Nanosecond), wantReason: "schema_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			processor := &recordingProcessor{}
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{EventTTL: ttl}, processor, metrics.NewConsumerMetrics(reg))
+			consumer.SetClock(domain.NewFakeClock(now))
+
+			if err := consumer.processMessage(context.Background(), eventMessageAt(t, tt.created)); err != nil {
+				t.Fatalf("processMessage: %v", err)
+			}
+
+			if processed := len(processor.processed()) == 1; processed != tt.wantProcessed {
+				t.Fatalf("event processed = %v, want %v", processed, tt.wantProcessed)
+			}
+			for _, reason := range []string{"expired", "schema_error"} {
+				want := 0.0
+				if reason == tt.wantReason {
+					want = 1
+				}
+				labels := map[string]string{"event_type": string(domain.UserCreatedEvent), "reason": reason}
+				if got := metricValue(t, reg, "consumer_events_failed_total", labels); got != want {
+					t.Fatalf("failed events with reason %q = %v, want %v", reason, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumerDeadlineHeader(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		deadline      string
+		wantProcessed bool
+	}{
+		{name: "past deadline skipped", deadline: now.Add(-time.Second).Format(time.RFC3339Nano)},
+		{name: "future deadline processed", deadline: now.Add(time.Second).Format(time.RFC3339Nano), wantProcessed: true},
+		{name: "deadline now processed", deadline: now.Format(time.RFC3339Nano), wantProcessed: true},
+		{name: "no deadline processed", wantProcessed: true},
+		{name: "invalid deadline ignored", deadline: "tomorrow", wantProcessed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			processor := &recordingProcessor{}
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{}, processor, metrics.NewConsumerMetrics(reg))
+			consumer.SetClock(domain.NewFakeClock(now))
+
+			// Пропущенное событие коммитится: processMessage не возвращает ошибку
+			message := withHeader(eventMessageAt(t, now.Add(-time.Minute)), headerDeadline, tt.deadline)
+			if err := consumer.processMessage(context.Background(), message); err != nil {
+				t.Fatalf("processMessage: %v", err)
+			}
+
+			if processed := len(processor.processed()) == 1; processed != tt.wantProcessed {
+				t.Fatalf("event processed = %v, want %v", processed, tt.wantProcessed)
+			}
+			wantExpired := 1.0
+			if tt.wantProcessed {
+				wantExpired = 0
+			}
+			labels := map[string]string{"event_type": string(domain.UserCreatedEvent), "reason": "expired_deadline"}
+			if got := metricValue(t, reg, "consumer_events_failed_total", labels); got != wantExpired {
+				t.Fatalf("expired_deadline events = %v, want %v", got, wantExpired)
+			}
+		})
+	}
+}
+
+// slowFirstProcessor обрабатывает первое событие дольше остальных: при
+// параллельных worker'ах следующие события обгоняют его
+type slowFirstProcessor struct {
+	recordingProcessor
+	calls atomic.Int64
+}
+
+func (p *slowFirstProcessor) ProcessEvent(ctx context.Context, event *domain.Event) error {
+	if p.calls.Add(1) == 1 {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return p.recordingProcessor.ProcessEvent(ctx, event)
+}
+
+func TestConsumerStrictOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		workerCount int
+	}{
+		{name: "worker count overridden", workerCount: 8},
+		{name: "worker count not set", workerCount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := eventMessages(t, 20)
+			processor := &slowFirstProcessor{}
+			consumer, reader := newTestConsumer(t,
+				config.ConsumerConfig{WorkerCount: tt.workerCount, StrictOrder: true, BatchSize: 5},
+				processor, metrics.NewConsumerMetrics(prometheus.NewRegistry()), messages...,
+			)
+			if consumer.workerCount != 1 {
+				t.Fatalf("strict order runs %d workers, want 1", consumer.workerCount)
+			}
+
+			runUntilRead(t, consumer, reader)
+
+			if got, want := processor.processed(), eventIDs(t, messages); !reflect.DeepEqual(got, want) {
+				t.Fatalf("processed out of offset order:\n got %v\nwant %v", got, want)
+			}
+			if len(reader.committed) != len(messages) {
+				t.Fatalf("committed %d messages, want %d", len(reader.committed), len(messages))
+			}
+			for i := 1; i < len(reader.committed); i++ {
+				if reader.committed[i].Offset <= reader.committed[i-1].Offset {
+					t.Fatalf("commit order broken at %d: offset %d after %d", i, reader.committed[i].Offset, reader.committed[i-1].Offset)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumerCloseAfterCancelCommitsPendingBatch(t *testing.T) {
+	const total = 5
+	consumer, reader := newTestConsumer(t, config.ConsumerConfig{WorkerCount: 2, BatchSize: 100}, &recordingProcessor{},
+		metrics.NewConsumerMetrics(prometheus.NewRegistry()), eventMessages(t, total)...)
+	// Batch не коммитится по таймеру: он остается до финального коммита
+	consumer.commitInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErr := make(chan error, 1)
+	go func() { startErr <- consumer.Start(ctx) }()
+	waitForCondition(t, func() bool { return consumer.processedCount.Load() == total })
+
+	// Порядок остановки как в main: отмена контекста, затем Close
+	cancel()
+	closed := make(chan error, 1)
+	go func() { closed <- consumer.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return with a pending batch")
+	}
+	if err := <-startErr; err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	if len(reader.committed) != total {
+		t.Fatalf("committed %d messages on close, want %d", len(reader.committed), total)
+	}
+}
+
+func TestConsumerPersistsFailedResults(t *testing.T) {
+	messages := eventMessages(t, 3)
+	ids := eventIDs(t, messages)
+	processor := &flakyProcessor{failID: ids[1]}
+	processor.down.Store(true)
+
+	consumer, reader := newTestConsumer(t, config.ConsumerConfig{}, processor,
+		metrics.NewConsumerMetrics(prometheus.NewRegistry()), messages...)
+	results := repository.NewMemoryRepository(10)
+	consumer.repository = results
+	runUntilRead(t, consumer, reader)
+
+	success, failure := true, false
+	tests := []struct {
+		name    string
+		filter  domain.ResultFilter
+		wantIDs []string
+	}{
+		{name: "failures", filter: domain.ResultFilter{Success: &failure}, wantIDs: []string{ids[1]}},
+		{name: "successes are not persisted", filter: domain.ResultFilter{Success: &success}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := results.GetResults(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("GetResults: %v", err)
+			}
+			var gotIDs []string
+			for _, result := range got {
+				gotIDs = append(gotIDs, result.EventID)
+				if result.Error == "" || result.EventType != domain.UserCreatedEvent {
+					t.Fatalf("failure result = %+v, want error and event type", result)
+				}
+			}
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Fatalf("results for %s = %v, want %v", tt.name, gotIDs, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// commitFailingReader отклоняет каждый коммит ошибкой err
+type commitFailingReader struct {
+	fakeReader
+	err error
+}
+
+func (r *commitFailingReader) CommitMessages(context.Context, ...kafka.Message) error {
+	return r.err
+}
+
+func TestConsumerCountsCommitFailures(t *testing.T) {
+	const total = 3
+
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+	}{
+		{name: "timeout", err: fmt.Errorf("commit: %w", context.DeadlineExceeded), wantReason: "timeout"},
+		{name: "other", err: kafka.RebalanceInProgress, wantReason: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			// Batch из total сообщений коммитится одним вызовом CommitMessages
+			consumer, _ := newTestConsumer(t, config.ConsumerConfig{BatchSize: total}, &recordingProcessor{}, metrics.NewConsumerMetrics(reg))
+			reader := &commitFailingReader{fakeReader: fakeReader{messages: eventMessages(t, total)}, err: tt.err}
+			consumer.SetReader(reader)
+
+			runUntilRead(t, consumer, &reader.fakeReader)
+
+			for _, reason := range []string{"timeout", "other"} {
+				want := 0.0
+				if reason == tt.wantReason {
+					want = 1
+				}
+				if got := metricValue(t, reg, "consumer_commit_failures_total", map[string]string{"reason": reason}); got != want {
+					t.Fatalf("consumer_commit_failures_total{reason=%q} = %v, want %v", reason, got, want)
+				}
+			}
+			if got := metricValue(t, reg, "consumer_commit_duration_seconds", nil); got != 0 {
+				t.Fatalf("consumer_commit_duration_seconds observations = %v, want none for failed commits", got)
+			}
+		})
+	}
+}
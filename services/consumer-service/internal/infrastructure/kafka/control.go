@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"consumer-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// ControlCommandShutdown команда остановки consumer'а
+const ControlCommandShutdown = "shutdown"
+
+// controlSignatureHeader заголовок с hex HMAC-SHA256 подписью value сообщения
+const controlSignatureHeader = "signature"
+
+// ControlCommand команда управляющего топика
+type ControlCommand struct {
+	Command  string    `json:"command"`
+	IssuedAt time.Time `json:"issued_at"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// ControlListener читает управляющий топик и ждет подписанную команду shutdown,
+// по которой сервис вызывает Drain consumer'ов.
+// Топик читается без consumer group, чтобы команду получил каждый экземпляр
+// сервиса; чтение начинается с конца, старые команды не применяются.
+type ControlListener struct {
+	reader MessageReader
+	secret []byte
+	maxAge time.Duration
+	logger *logrus.Logger
+}
+
+// NewControlListener создает listener управляющего топика
+func NewControlListener(brokers []string, cfg config.ControlConfig, logger *logrus.Logger) (*ControlListener, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("control topic is empty")
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("control secret is empty")
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		Topic:       cfg.Topic,
+		Partition:   cfg.Partition,
+		MaxWait:     time.Second,
+		ErrorLogger: kafka.LoggerFunc(logger.Errorf),
+	})
+	if err := reader.SetOffset(kafka.LastOffset); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to set control topic offset: %w", err)
+	}
+
+	return &ControlListener{
+		reader: reader,
+		secret: []byte(cfg.Secret),
+		maxAge: cfg.MaxAge,
+		logger: logger,
+	}, nil
+}
+
+// Listen блокируется до получения действительной команды shutdown.
+// Сообщения с неверной подписью, устаревшие и неизвестные команды пропускаются.
+func (l *ControlListener) Listen(ctx context.Context) (ControlCommand, error) {
+	for {
+		message, err := l.reader.ReadMessage(ctx)
+		if err != nil {
+			return ControlCommand{}, err
+		}
+
+		command, err := l.verify(message)
+		if err != nil {
+			l.logger.WithFields(logrus.Fields{
+				"topic":  message.Topic,
+				"offset": message.Offset,
+				"error":  err,
+			}).Warn("Rejected control message")
+			continue
+		}
+
+		if command.Command != ControlCommandShutdown {
+			l.logger.WithField("command", command.Command).Warn("Unknown control command, ignoring")
+			continue
+		}
+
+		return command, nil
+	}
+}
+
+// verify проверяет подпись и свежесть команды
+func (l *ControlListener) verify(message kafka.Message) (ControlCommand, error) {
+	var signature []byte
+	for _, header := range message.Headers {
+		if header.Key == controlSignatureHeader {
+			signature, _ = hex.DecodeString(string(header.Value))
+			break
+		}
+	}
+
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write(message.Value)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ControlCommand{}, errors.New("invalid signature")
+	}
+
+	var command ControlCommand
+	if err := json.Unmarshal(message.Value, &command); err != nil {
+		return ControlCommand{}, fmt.Errorf("invalid command: %w", err)
+	}
+
+	if age := time.Since(command.IssuedAt); age > l.maxAge || age < -l.maxAge {
+		return ControlCommand{}, fmt.Errorf("command issued at %s is outside of %s window", command.IssuedAt.Format(time.RFC3339), l.maxAge)
+	}
+
+	return command, nil
+}
+
+// Close закрывает reader управляющего топика
+func (l *ControlListener) Close() error {
+	return l.reader.Close()
+}
@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+	"consumer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const testControlSecret = "control-secret"
+
+// controlMessage создает сообщение управляющего топика, подписанное secret
+func controlMessage(t *testing.T, command ControlCommand, secret string) kafka.Message {
+	t.Helper()
+
+	value, err := json.Marshal(command)
+	if err != nil {
+		t.Fatalf("marshal command: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(value)
+
+	return kafka.Message{
+		Topic:   "control",
+		Value:   value,
+		Headers: []kafka.Header{{Key: controlSignatureHeader, Value: []byte(hex.EncodeToString(mac.Sum(nil)))}},
+	}
+}
+
+func TestControlListenerVerify(t *testing.T) {
+	listener := &ControlListener{secret: []byte(testControlSecret), maxAge: time.Minute}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		message kafka.Message
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			message: controlMessage(t, ControlCommand{Command: ControlCommandShutdown, IssuedAt: now}, testControlSecret),
+		},
+		{
+			name:    "wrong secret",
+			message: controlMessage(t, ControlCommand{Command: ControlCommandShutdown, IssuedAt: now}, "other-secret"),
+			wantErr: true,
+		},
+		{
+			name:    "unsigned",
+			message: kafka.Message{Value: []byte(`{"command":"shutdown"}`)},
+			wantErr: true,
+		},
+		{
+			name:    "stale",
+			message: controlMessage(t, ControlCommand{Command: ControlCommandShutdown, IssuedAt: now.Add(-time.Hour)}, testControlSecret),
+			wantErr: true,
+		},
+		{
+			name:    "from the future",
+			message: controlMessage(t, ControlCommand{Command: ControlCommandShutdown, IssuedAt: now.Add(time.Hour)}, testControlSecret),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := listener.verify(tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// slowProcessor обрабатывает событие за delay и считает обработанные
+type slowProcessor struct {
+	delay     time.Duration
+	processed atomic.Int64
+}
+
+func (p *slowProcessor) ProcessEvent(ctx context.Context, _ *domain.Event) error {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.processed.Add(1)
+	return nil
+}
+
+func TestControlShutdownDrainsConsumer(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	processor := &slowProcessor{delay: 20 * time.Millisecond}
+	consumer, err := NewConsumer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", ReadTimeout: time.Minute, CloseTimeout: time.Second},
+		config.ConsumerConfig{WorkerCount: 2, BatchSize: 100, CommitMode: config.CommitModeMessage},
+		processor, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+	)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	const total = 10
+	reader := &fakeReader{messages: eventMessages(t, total)}
+	consumer.SetReader(reader)
+
+	// Перед командой в управляющем топике лежит сообщение с чужой подписью
+	listener := &ControlListener{
+		reader: &fakeReader{messages: []kafka.Message{
+			controlMessage(t, ControlCommand{Command: ControlCommandShutdown, IssuedAt: time.Now()}, "forged"),
+			controlMessage(t, ControlCommand{Command: ControlCommandShutdown, IssuedAt: time.Now(), Reason: "fleet stop"}, testControlSecret),
+		}},
+		secret: []byte(testControlSecret),
+		maxAge: time.Minute,
+		logger: logger,
+	}
+
+	// Контекст consumer'а не отменяется: остановить его может только Drain
+	startErr := make(chan error, 1)
+	go func() { startErr <- consumer.Start(context.Background()) }()
+
+	// Команда приходит, когда все сообщения прочитаны, но еще обрабатываются
+	waitForCondition(t, func() bool {
+		reader.mu.Lock()
+		defer reader.mu.Unlock()
+		return len(reader.messages) == 0
+	})
+	if processor.processed.Load() == total {
+		t.Fatal("all messages processed before the drain started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	command, err := listener.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if command.Reason != "fleet stop" {
+		t.Fatalf("Listen returned %+v, want the signed command", command)
+	}
+	if err := consumer.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	if err := <-startErr; err != nil {
+		t.Fatalf("Start returned %v after drain, want nil", err)
+	}
+	if got := processor.processed.Load(); got != total {
+		t.Fatalf("processed %d messages, want %d", got, total)
+	}
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	if len(reader.committed) != total {
+		t.Fatalf("committed %d messages after drain, want %d", len(reader.committed), total)
+	}
+}
+
+// waitForCondition ждет выполнения cond не дольше 5 секунд
+func waitForCondition(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
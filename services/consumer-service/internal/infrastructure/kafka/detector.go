@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slowConsumerDetector сравнивает скорость поступления сообщений в топик со
+// скоростью обработки. Скорость чтения для этого не подходит: reader ждет места
+// в ограниченной очереди worker'ов и читает ровно с той скоростью, с которой
+// они обрабатывают. Поступление оценивается по брокеру: обработанные за интервал
+// сообщения плюс прирост лага reader'а (high watermark минус позиция чтения).
+// Если лаг растет несколько интервалов подряд, consumer помечается как отстающий.
+func (c *Consumer) slowConsumerDetector(ctx context.Context) {
+	ticker := time.NewTicker(c.lagCheckInterval)
+	defer ticker.Stop()
+
+	lastLag := c.readerLag()
+	lastProcessed := c.processedCount.Load()
+	behindIntervals := 0
+	fallingBehind := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lag := c.readerLag()
+			processed := c.processedCount.Load()
+
+			handled := processed - lastProcessed
+			incoming := handled + (lag - lastLag)
+			lastLag, lastProcessed = lag, processed
+
+			if handled < incoming {
+				behindIntervals++
+			} else {
+				behindIntervals = 0
+			}
+
+			behind := behindIntervals >= c.lagCheckThreshold
+			if behind == fallingBehind {
+				continue
+			}
+
+			fallingBehind = behind
+			c.metrics.SetFallingBehind(behind)
+
+			fields := logrus.Fields{
+				"incoming_rate":  float64(incoming) / c.lagCheckInterval.Seconds(),
+				"processed_rate": float64(handled) / c.lagCheckInterval.Seconds(),
+				"lag":            lag,
+				"intervals":      behindIntervals,
+			}
+			if behind {
+				c.logger.WithFields(fields).Warn("consumer_falling_behind: processing rate is below incoming rate")
+			} else {
+				c.logger.WithFields(fields).Info("Consumer caught up with incoming rate")
+			}
+		}
+	}
+}
+
+// readerLag возвращает лаг reader'а по последнему fetch; до первого fetch
+// и при недоступной статистике (отрицательное значение) — 0
+func (c *Consumer) readerLag() int64 {
+	c.mu.RLock()
+	reader := c.reader
+	c.mu.RUnlock()
+
+	if lag := reader.Stats().Lag; lag > 0 {
+		return lag
+	}
+	return 0
+}
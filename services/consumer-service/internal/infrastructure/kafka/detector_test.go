@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"consumer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// lagReader увеличивает лаг на lagStep при каждом вызове Stats, имитируя
+// поступление сообщений в топик
+type lagReader struct {
+	fakeReader
+	lag     atomic.Int64
+	lagStep int64
+}
+
+func (r *lagReader) Stats() kafka.ReaderStats {
+	return kafka.ReaderStats{Lag: r.lag.Add(r.lagStep)}
+}
+
+// fallingBehindRecorder запоминает вызовы SetFallingBehind
+type fallingBehindRecorder struct {
+	*metrics.ConsumerMetrics
+	mu    sync.Mutex
+	calls []bool
+}
+
+func (m *fallingBehindRecorder) SetFallingBehind(behind bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, behind)
+}
+
+func (m *fallingBehindRecorder) snapshot() []bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bool(nil), m.calls...)
+}
+
+func TestSlowConsumerDetector(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name string
+		// Прирост лага и число обработанных сообщений за интервал
+		lagStep   int64
+		processed int64
+		want      []bool
+	}{
+		{name: "incoming above processing", lagStep: 10, processed: 5, want: []bool{true}},
+		{name: "incoming above idle workers", lagStep: 1, processed: 0, want: []bool{true}},
+		{name: "processing keeps up", lagStep: 0, processed: 5, want: nil},
+		{name: "backlog shrinking", lagStep: -5, processed: 5, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &fallingBehindRecorder{ConsumerMetrics: metrics.NewConsumerMetrics(prometheus.NewRegistry())}
+			reader := &lagReader{lagStep: tt.lagStep}
+			reader.lag.Store(1000)
+			consumer := &Consumer{
+				reader:            reader,
+				logger:            logger,
+				metrics:           recorder,
+				lagCheckInterval:  5 * time.Millisecond,
+				lagCheckThreshold: 3,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				consumer.slowConsumerDetector(ctx)
+			}()
+
+			// Worker'ы обрабатывают processed сообщений за интервал детектора
+			ticker := time.NewTicker(consumer.lagCheckInterval)
+			for i := 0; i < 20; i++ {
+				<-ticker.C
+				consumer.processedCount.Add(tt.processed)
+			}
+			ticker.Stop()
+			cancel()
+			<-done
+
+			got := recorder.snapshot()
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("SetFallingBehind calls = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
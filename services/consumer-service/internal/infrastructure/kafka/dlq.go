@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"consumer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDLQKey ключ топика по умолчанию в конфигурации DLQ
+const defaultDLQKey = "default"
+
+// DLQTopicResolver определяет DLQ топик для типа события
+type DLQTopicResolver func(eventType domain.EventType) string
+
+// NewDLQTopicResolver создает resolver из строки вида "payment_processed:dlq-payments,default:dlq"
+func NewDLQTopicResolver(spec string) (DLQTopicResolver, error) {
+	topics := make(map[domain.EventType]string)
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		eventType, topic, ok := strings.Cut(pair, ":")
+		eventType = strings.TrimSpace(eventType)
+		topic = strings.TrimSpace(topic)
+		if !ok || eventType == "" || topic == "" {
+			return nil, fmt.Errorf("invalid DLQ topic mapping %q", pair)
+		}
+
+		topics[domain.EventType(eventType)] = topic
+	}
+
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("DLQ topic mapping is empty")
+	}
+
+	// Без топика по умолчанию сообщения неперечисленных типов с ошибкой разбора
+	// или схемы коммитились бы без записи в DLQ, то есть молча терялись
+	defaultTopic, ok := topics[defaultDLQKey]
+	if !ok {
+		return nil, fmt.Errorf("DLQ topic mapping has no %q entry", defaultDLQKey)
+	}
+
+	return func(eventType domain.EventType) string {
+		if topic, ok := topics[eventType]; ok {
+			return topic
+		}
+		return defaultTopic
+	}, nil
+}
+
+// messageWriter записывает сообщения в Kafka; реализуется *kafka.Writer
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+var _ messageWriter = (*kafka.Writer)(nil)
+
+// DLQPublisher отправляет необработанные сообщения в dead-letter топики
+type DLQPublisher struct {
+	writer   messageWriter
+	resolver DLQTopicResolver
+	logger   *logrus.Logger
+}
+
+// NewDLQPublisher создает новый DLQ publisher
+func NewDLQPublisher(brokers []string, resolver DLQTopicResolver, logger *logrus.Logger) *DLQPublisher {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: syncWriteBatchTimeout,
+		ErrorLogger:  kafka.LoggerFunc(logger.Errorf),
+	}
+
+	return &DLQPublisher{
+		writer:   writer,
+		resolver: resolver,
+		logger:   logger,
+	}
+}
+
+// Publish отправляет исходное сообщение в DLQ топик, соответствующий типу события
+func (p *DLQPublisher) Publish(ctx context.Context, message kafka.Message, eventType domain.EventType, reason string, cause error) error {
+	topic := p.resolver(eventType)
+	if topic == "" {
+		return fmt.Errorf("no DLQ topic configured for event type %q", eventType)
+	}
+
+	headers := make([]kafka.Header, 0, len(message.Headers)+5)
+	headers = append(headers, message.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "dlq-reason", Value: []byte(reason)},
+		kafka.Header{Key: "dlq-error", Value: []byte(errorString(cause))},
+		kafka.Header{Key: "dlq-original-topic", Value: []byte(message.Topic)},
+		kafka.Header{Key: "dlq-original-partition", Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: "dlq-original-offset", Value: []byte(strconv.FormatInt(message.Offset, 10))},
+	)
+
+	dlqMessage := kafka.Message{
+		Topic:   topic,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	}
+
+	if err := p.writer.WriteMessages(ctx, dlqMessage); err != nil {
+		return fmt.Errorf("failed to publish to DLQ topic %s: %w", topic, err)
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"dlq_topic":  topic,
+		"event_type": eventType,
+		"reason":     reason,
+		"offset":     message.Offset,
+		"partition":  message.Partition,
+	}).Warn("Message sent to DLQ")
+
+	return nil
+}
+
+// Close закрывает DLQ publisher
+func (p *DLQPublisher) Close() error {
+	return p.writer.Close()
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
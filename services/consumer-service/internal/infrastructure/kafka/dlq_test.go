@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"testing"
+
+	"consumer-service/internal/domain"
+)
+
+func TestNewDLQTopicResolver(t *testing.T) {
+	resolve, err := NewDLQTopicResolver(" payment_processed : dlq-payments , default:dlq ,")
+	if err != nil {
+		t.Fatalf("NewDLQTopicResolver: %v", err)
+	}
+
+	tests := []struct {
+		eventType domain.EventType
+		want      string
+	}{
+		{eventType: "payment_processed", want: "dlq-payments"},
+		{eventType: domain.UserCreatedEvent, want: "dlq"},
+		{eventType: "unknown", want: "dlq"},
+	}
+	for _, tt := range tests {
+		if got := resolve(tt.eventType); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestNewDLQTopicResolverInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "empty", spec: " , "},
+		{name: "missing default", spec: "payment_processed:dlq-payments"},
+		{name: "missing topic", spec: "payment_processed:,default:dlq"},
+		{name: "missing separator", spec: "payment_processed,default:dlq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDLQTopicResolver(tt.spec); err == nil {
+				t.Errorf("NewDLQTopicResolver(%q) succeeded, want error", tt.spec)
+			}
+		})
+	}
+}
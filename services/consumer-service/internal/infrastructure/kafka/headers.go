@@ -0,0 +1,212 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"consumer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которые producer-service добавляет к каждому сообщению
+const (
+	headerEventType    = "event-type"
+	headerEventID      = "event-id"
+	headerEventVersion = "event-version"
+	headerEventSource  = "event-source"
+
+	// headerDataEncoding способ кодирования data; отсутствует, если data не сжат
+	headerDataEncoding = "data-encoding"
+
+	// headerDeadline момент (RFC3339), после которого обработка события бесполезна
+	headerDeadline = "deadline"
+)
+
+// eventHeaders атрибуты события из заголовков сообщения.
+// Позволяют определить тип события, даже если тело повреждено.
+type eventHeaders struct {
+	Type    domain.EventType
+	ID      string
+	Version string
+	Source  string
+
+	DataEncoding string
+	Deadline     string
+}
+
+// parseEventHeaders извлекает атрибуты события из заголовков сообщения
+func parseEventHeaders(message kafka.Message) eventHeaders {
+	var h eventHeaders
+	for _, header := range message.Headers {
+		switch header.Key {
+		case headerEventType:
+			h.Type = domain.EventType(header.Value)
+		case headerEventID:
+			h.ID = string(header.Value)
+		case headerEventVersion:
+			h.Version = string(header.Value)
+		case headerEventSource:
+			h.Source = string(header.Value)
+		case headerDataEncoding:
+			h.DataEncoding = string(header.Value)
+		case headerDeadline:
+			h.Deadline = string(header.Value)
+		}
+	}
+	return h
+}
+
+// typeOrUnknown возвращает тип события из заголовков или "unknown".
+// Значение заголовка задает клиент, а тип идет в метки метрик и маршрутизацию
+// DLQ, поэтому неизвестные типы не пропускаются (ограничение кардинальности).
+func (h eventHeaders) typeOrUnknown() domain.EventType {
+	if !h.Type.IsValid() {
+		return "unknown"
+	}
+	return h.Type
+}
+
+// deadline возвращает дедлайн обработки из заголовка deadline;
+// ok=false — заголовка нет.
+func (h eventHeaders) deadline() (deadline time.Time, ok bool, err error) {
+	if h.Deadline == "" {
+		return time.Time{}, false, nil
+	}
+	deadline, err = time.Parse(time.RFC3339Nano, h.Deadline)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s header %q: %w", headerDeadline, h.Deadline, err)
+	}
+	return deadline, true, nil
+}
+
+// tenantOther метка для tenant'ов вне allowlist
+const tenantOther = "other"
+
+// tenantLabeler вычисляет метку tenant с ограниченной кардинальностью
+type tenantLabeler struct {
+	header  string
+	allowed map[string]struct{}
+}
+
+// newTenantLabeler создает labeler; пустой allowlist отключает метку
+func newTenantLabeler(header string, allowlist []string) *tenantLabeler {
+	allowed := make(map[string]struct{}, len(allowlist))
+	for _, tenant := range allowlist {
+		if tenant = strings.TrimSpace(tenant); tenant != "" {
+			allowed[tenant] = struct{}{}
+		}
+	}
+	return &tenantLabeler{header: header, allowed: allowed}
+}
+
+// label возвращает tenant из allowlist, "other" для остальных
+// и пустую строку, если метка отключена
+func (l *tenantLabeler) label(message kafka.Message) string {
+	if len(l.allowed) == 0 {
+		return ""
+	}
+
+	for _, header := range message.Headers {
+		if header.Key != l.header {
+			continue
+		}
+		if _, ok := l.allowed[string(header.Value)]; ok {
+			return string(header.Value)
+		}
+		break
+	}
+	return tenantOther
+}
+
+// headerLabelOther метка для значений заголовка сверх лимита
+const headerLabelOther = "other"
+
+// reservedLabels метки, уже занятые метриками consumer'а
+var reservedLabels = map[string]struct{}{"event_type": {}, "tenant": {}, "group": {}}
+
+// HeaderLabelNames преобразует имена заголовков в имена меток Prometheus:
+// символы вне [a-zA-Z0-9_] заменяются на "_". Пустые, совпадающие и занятые
+// имена, а также имена, начинающиеся с цифры, считаются ошибкой конфигурации.
+func HeaderLabelNames(headers []string) ([]string, error) {
+	names := make([]string, len(headers))
+	seen := make(map[string]struct{}, len(headers))
+	for i, header := range headers {
+		name := strings.Map(func(r rune) rune {
+			if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, strings.TrimSpace(header))
+
+		if name == "" || name[0] >= '0' && name[0] <= '9' || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("header %q cannot be used as a metric label", header)
+		}
+		if _, ok := reservedLabels[name]; ok {
+			return nil, fmt.Errorf("header %q conflicts with metric label %q", header, name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate metric label %q for header %q", name, header)
+		}
+		seen[name] = struct{}{}
+		names[i] = name
+	}
+	return names, nil
+}
+
+// headerLabeler переносит значения заголовков в метки. Для каждого заголовка
+// допускается не больше maxValues различных значений (первые увиденные),
+// остальные попадают в "other"; отсутствующий заголовок дает пустую метку.
+type headerLabeler struct {
+	headers   []string
+	maxValues int
+
+	mu   sync.Mutex
+	seen []map[string]struct{}
+}
+
+// newHeaderLabeler создает labeler; без заголовков метки не добавляются.
+// Имена заголовков должны быть предварительно проверены HeaderLabelNames.
+func newHeaderLabeler(headers []string, maxValues int) *headerLabeler {
+	l := &headerLabeler{maxValues: maxValues}
+	for _, header := range headers {
+		l.headers = append(l.headers, strings.TrimSpace(header))
+		l.seen = append(l.seen, make(map[string]struct{}))
+	}
+	return l
+}
+
+// values возвращает значения меток в порядке заголовков конфигурации
+func (l *headerLabeler) values(message kafka.Message) []string {
+	if len(l.headers) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(l.headers))
+	for _, header := range message.Headers {
+		for i, name := range l.headers {
+			if header.Key == name && values[i] == "" {
+				values[i] = string(header.Value)
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		if _, ok := l.seen[i][value]; ok {
+			continue
+		}
+		if len(l.seen[i]) >= l.maxValues {
+			values[i] = headerLabelOther
+			continue
+		}
+		l.seen[i][value] = struct{}{}
+	}
+	return values
+}
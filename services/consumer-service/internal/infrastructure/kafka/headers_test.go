@@ -0,0 +1,264 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+	"consumer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// failureRecorder запоминает вызовы IncFailedEvents как "тип/причина"
+type failureRecorder struct {
+	*metrics.ConsumerMetrics
+	mu       sync.Mutex
+	failures []string
+}
+
+func (m *failureRecorder) IncFailedEvents(eventType, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append(m.failures, eventType+"/"+reason)
+}
+
+func TestCorruptBodyTypeFromHeaders(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		want    string
+	}{
+		{
+			name: "valid headers",
+			headers: []kafka.Header{
+				{Key: headerEventType, Value: []byte(domain.UserCreatedEvent)},
+				{Key: headerEventID, Value: []byte("user_created_1")},
+			},
+			want: "user_created/parse_error",
+		},
+		{
+			name:    "unknown header type",
+			headers: []kafka.Header{{Key: headerEventType, Value: []byte("payment_processed_v99")}},
+			want:    "unknown/parse_error",
+		},
+		{
+			name:    "empty header type",
+			headers: []kafka.Header{{Key: headerEventType}},
+			want:    "unknown/parse_error",
+		},
+		{name: "no headers", want: "unknown/parse_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &failureRecorder{ConsumerMetrics: metrics.NewConsumerMetrics(prometheus.NewRegistry())}
+			consumer, err := NewConsumer(
+				config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test"},
+				config.ConsumerConfig{WorkerCount: 1, BatchSize: 10},
+				failingProcessor{}, nil, nil, logger, recorder,
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			defer consumer.reader.Close()
+
+			message := kafka.Message{Topic: "events", Value: []byte(`{"id": "user_created_1", "type":`), Headers: tt.headers}
+			if err := consumer.processMessage(context.Background(), message); err != nil {
+				t.Fatalf("processMessage: %v", err)
+			}
+			if len(recorder.failures) != 1 || recorder.failures[0] != tt.want {
+				t.Fatalf("IncFailedEvents calls = %v, want [%s]", recorder.failures, tt.want)
+			}
+		})
+	}
+}
+
+// withHeader добавляет сообщению заголовок key, если value не пустое
+func withHeader(message kafka.Message, key, value string) kafka.Message {
+	if value != "" {
+		message.Headers = append(message.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return message
+}
+
+func TestTenantLabelAllowlist(t *testing.T) {
+	// Значения заголовка tenant у потребленных сообщений; "" — без заголовка
+	tenants := []string{"acme", "acme", "globex", "", "initech"}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		want      map[string]float64
+	}{
+		{
+			name:      "allowlisted tenants labeled, others bucketed",
+			allowlist: []string{"acme", " initech "},
+			want:      map[string]float64{"acme": 2, "initech": 1, tenantOther: 2, "globex": 0},
+		},
+		{name: "empty allowlist disables the label", want: map[string]float64{"": 5, tenantOther: 0, "acme": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			consumer, _ := newTestConsumer(t,
+				config.ConsumerConfig{TenantHeader: "tenant", TenantAllowlist: tt.allowlist},
+				&recordingProcessor{}, metrics.NewConsumerMetrics(reg),
+			)
+
+			for i, message := range eventMessages(t, len(tenants)) {
+				if err := consumer.processMessage(context.Background(), withHeader(message, "tenant", tenants[i])); err != nil {
+					t.Fatalf("processMessage: %v", err)
+				}
+			}
+
+			for tenant, want := range tt.want {
+				labels := map[string]string{"event_type": string(domain.UserCreatedEvent), "tenant": tenant}
+				if got := metricValue(t, reg, "consumer_events_consumed_total", labels); got != want {
+					t.Fatalf("consumed events with tenant %q = %v, want %v", tenant, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHeaderLabelNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "valid", headers: []string{"region", " x-channel "}, want: []string{"region", "x_channel"}},
+		{name: "reserved label", headers: []string{"tenant"}, wantErr: true},
+		{name: "duplicate after mapping", headers: []string{"x-channel", "x_channel"}, wantErr: true},
+		{name: "leading digit", headers: []string{"1region"}, wantErr: true},
+		{name: "empty", headers: []string{" "}, wantErr: true},
+		{name: "prometheus reserved prefix", headers: []string{"__name"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HeaderLabelNames(tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HeaderLabelNames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("HeaderLabelNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderLabelsCapValues(t *testing.T) {
+	headers := []string{"region", "x-channel"}
+	names, err := HeaderLabelNames(headers)
+	if err != nil {
+		t.Fatalf("HeaderLabelNames: %v", err)
+	}
+	reg := prometheus.NewRegistry()
+	consumer, _ := newTestConsumer(t,
+		config.ConsumerConfig{LabelHeaders: headers, LabelHeaderMaxValues: 2},
+		&recordingProcessor{}, metrics.NewConsumerMetrics(reg, names...),
+	)
+
+	// Заголовки region и x-channel потребленных сообщений; "" — без заголовка
+	consumed := []struct{ region, channel string }{
+		{"eu", "web"}, {"us", "web"}, {"eu", "web"}, {"apac", "web"}, {"", "mobile"},
+	}
+	for i, message := range eventMessages(t, len(consumed)) {
+		message = withHeader(withHeader(message, "region", consumed[i].region), "x-channel", consumed[i].channel)
+		if err := consumer.processMessage(context.Background(), message); err != nil {
+			t.Fatalf("processMessage: %v", err)
+		}
+	}
+
+	// Третье значение region сверх лимита попадает в "other"
+	tests := []struct {
+		region, channel string
+		want            float64
+	}{
+		{region: "eu", channel: "web", want: 2},
+		{region: "us", channel: "web", want: 1},
+		{region: headerLabelOther, channel: "web", want: 1},
+		{region: "", channel: "mobile", want: 1},
+		{region: "apac", channel: "web", want: 0},
+	}
+	for _, tt := range tests {
+		labels := map[string]string{
+			"event_type": string(domain.UserCreatedEvent), "tenant": "",
+			"region": tt.region, "x_channel": tt.channel,
+		}
+		if got := metricValue(t, reg, "consumer_events_consumed_total", labels); got != tt.want {
+			t.Errorf("consumed events with region=%q x_channel=%q = %v, want %v", tt.region, tt.channel, got, tt.want)
+		}
+	}
+}
+
+// dataProcessor запоминает data обработанных событий
+type dataProcessor struct {
+	mu   sync.Mutex
+	data []string
+}
+
+func (p *dataProcessor) ProcessEvent(_ context.Context, event *domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = append(p.data, event.Data)
+	return nil
+}
+
+func TestConsumerDecompressesByDataEncodingHeader(t *testing.T) {
+	data := `{"items":[` + strings.Repeat(`{"sku":"SKU-1","qty":1},`, 200) + `{"sku":"SKU-2","qty":2}]}`
+	event, err := domain.NewEvent(domain.UserCreatedEvent, data)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	payload, dataEncoding, err := domain.EventCodec{Compression: domain.DataEncodingGzip}.EncodeMessage(event)
+	if err != nil {
+		t.Fatalf("EncodeMessage: %v", err)
+	}
+	if dataEncoding != domain.DataEncodingGzip {
+		t.Fatalf("data-encoding = %q, want gzip", dataEncoding)
+	}
+	wire, err := domain.DefaultCodec.Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		wantData []string
+		wantFail []string
+	}{
+		{name: "gzip header", header: domain.DataEncodingGzip, wantData: []string{data}},
+		// Без заголовка обработчик получает сжатый data как есть
+		{name: "missing header", wantData: []string{wire.Data}},
+		{name: "unknown encoding", header: "br", wantFail: []string{"unknown/parse_error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &dataProcessor{}
+			recorder := &failureRecorder{ConsumerMetrics: metrics.NewConsumerMetrics(prometheus.NewRegistry())}
+			message := withHeader(kafka.Message{Topic: "events", Value: payload}, headerDataEncoding, tt.header)
+			consumer, reader := newTestConsumer(t, config.ConsumerConfig{}, processor, recorder, message)
+			runUntilRead(t, consumer, reader)
+
+			if !reflect.DeepEqual(processor.data, tt.wantData) {
+				t.Fatalf("processed data = %.40q, want %.40q", processor.data, tt.wantData)
+			}
+			if !reflect.DeepEqual(recorder.failures, tt.wantFail) {
+				t.Fatalf("failures = %v, want %v", recorder.failures, tt.wantFail)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"consumer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConsumerManager запускает несколько consumer group на одном топике
+// (fan-out в разные downstream'ы) с независимыми worker'ами и метриками
+type ConsumerManager struct {
+	groups    []string
+	consumers []*Consumer
+	logger    *logrus.Logger
+}
+
+// NewConsumerManager создает пустой менеджер consumer'ов
+func NewConsumerManager(logger *logrus.Logger) *ConsumerManager {
+	return &ConsumerManager{logger: logger}
+}
+
+// Add регистрирует consumer группы group; вызывать до Start
+func (m *ConsumerManager) Add(group string, consumer *Consumer) {
+	m.groups = append(m.groups, group)
+	m.consumers = append(m.consumers, consumer)
+}
+
+// WaitForTopic ожидает топик; группы читают один топик, достаточно одной проверки
+func (m *ConsumerManager) WaitForTopic(ctx context.Context) error {
+	if len(m.consumers) == 0 {
+		return fmt.Errorf("no consumers registered")
+	}
+	return m.consumers[0].WaitForTopic(ctx)
+}
+
+// Start запускает все consumer'ы и ждет их завершения. Фатальная ошибка
+// одной группы останавливает остальные и возвращается с именем группы.
+func (m *ConsumerManager) Start(ctx context.Context) error {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	var wg sync.WaitGroup
+	for i, consumer := range m.consumers {
+		wg.Add(1)
+		go func(group string, consumer *Consumer) {
+			defer wg.Done()
+			if err := consumer.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				stop(fmt.Errorf("consumer group %s: %w", group, err))
+			}
+		}(m.groups[i], consumer)
+	}
+	wg.Wait()
+
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return nil
+}
+
+// Drain останавливает чтение во всех группах и ждет, пока каждая обработает
+// и закоммитит уже прочитанные сообщения
+func (m *ConsumerManager) Drain(ctx context.Context) error {
+	errs := make([]error, len(m.consumers))
+	var wg sync.WaitGroup
+	for i, consumer := range m.consumers {
+		wg.Add(1)
+		go func(i int, consumer *Consumer) {
+			defer wg.Done()
+			if err := consumer.Drain(ctx); err != nil {
+				errs[i] = fmt.Errorf("consumer group %s: %w", m.groups[i], err)
+			}
+		}(i, consumer)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Close закрывает все consumer'ы и объединяет их ошибки
+func (m *ConsumerManager) Close() error {
+	var errs []error
+	for i, consumer := range m.consumers {
+		if err := consumer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("consumer group %s: %w", m.groups[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ready сообщает, что все группы подтвердили связь с брокером
+func (m *ConsumerManager) Ready() bool {
+	for _, consumer := range m.consumers {
+		if !consumer.Ready() {
+			return false
+		}
+	}
+	return len(m.consumers) > 0
+}
+
+// Stats возвращает суммарную статистику всех групп
+func (m *ConsumerManager) Stats() domain.ConsumerStats {
+	var total domain.ConsumerStats
+	for _, consumer := range m.consumers {
+		stats := consumer.Stats()
+		total.MessagesConsumed += stats.MessagesConsumed
+		total.BytesConsumed += stats.BytesConsumed
+		total.Errors += stats.Errors
+		total.Lag += stats.Lag
+		if stats.LastMessageTime != nil && (total.LastMessageTime == nil || stats.LastMessageTime.After(*total.LastMessageTime)) {
+			total.LastMessageTime = stats.LastMessageTime
+		}
+	}
+	return total
+}
+
+// GroupStats возвращает статистику по каждой группе
+func (m *ConsumerManager) GroupStats() map[string]domain.ConsumerStats {
+	stats := make(map[string]domain.ConsumerStats, len(m.consumers))
+	for i, consumer := range m.consumers {
+		stats[m.groups[i]] = consumer.Stats()
+	}
+	return stats
+}
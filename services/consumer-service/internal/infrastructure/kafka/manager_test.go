@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+	"consumer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+func TestConsumerManagerGroups(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	// Группы пишут метрики в общий реестр с меткой group, как в main
+	reg := prometheus.NewRegistry()
+	groups := []struct {
+		name     string
+		messages int
+	}{
+		{name: "billing", messages: 3},
+		{name: "audit", messages: 5},
+	}
+
+	manager := NewConsumerManager(logger)
+	readers := make(map[string]*fakeReader)
+	for _, group := range groups {
+		groupMetrics := metrics.NewConsumerMetrics(prometheus.WrapRegistererWith(prometheus.Labels{"group": group.name}, reg))
+		consumer, reader := newTestConsumer(t, config.ConsumerConfig{WorkerCount: 2}, &recordingProcessor{}, groupMetrics, eventMessages(t, group.messages)...)
+		manager.Add(group.name, consumer)
+		readers[group.name] = reader
+	}
+
+	startErr := make(chan error, 1)
+	go func() { startErr <- manager.Start(context.Background()) }()
+
+	waitForCondition(t, func() bool {
+		for _, reader := range readers {
+			reader.mu.Lock()
+			pending := len(reader.messages)
+			reader.mu.Unlock()
+			if pending > 0 {
+				return false
+			}
+		}
+		return true
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if err := <-startErr; err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	stats := manager.GroupStats()
+	var total int64
+	for _, group := range groups {
+		want := int64(group.messages)
+		total += want
+		if got := stats[group.name].MessagesConsumed; got != want {
+			t.Fatalf("group %s consumed %d messages, want %d", group.name, got, want)
+		}
+		if got := len(readers[group.name].committed); got != group.messages {
+			t.Fatalf("group %s committed %d messages, want %d", group.name, got, group.messages)
+		}
+		labels := map[string]string{"group": group.name, "event_type": string(domain.UserCreatedEvent), "tenant": ""}
+		if got := metricValue(t, reg, "consumer_events_consumed_total", labels); got != float64(want) {
+			t.Fatalf("consumer_events_consumed_total{group=%s} = %v, want %d", group.name, got, want)
+		}
+	}
+	if got := manager.Stats().MessagesConsumed; got != total {
+		t.Fatalf("total consumed %d messages, want %d", got, total)
+	}
+}
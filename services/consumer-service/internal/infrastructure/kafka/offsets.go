@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// OffsetStore внешнее хранилище offset'ов consumer group, например таблица
+// в БД, куда offset пишется в одной транзакции с результатом обработки.
+// Offset — следующий к чтению offset партиции (последний обработанный + 1).
+type OffsetStore interface {
+	SaveOffsets(ctx context.Context, groupID, topic string, offsets map[int]int64) error
+	LoadOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error)
+}
+
+// groupOffsetCommitter коммитит offset'ы consumer group вне reader'а;
+// реализуется *kafka.Client
+type groupOffsetCommitter interface {
+	OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error)
+}
+
+var _ groupOffsetCommitter = (*kafka.Client)(nil)
+
+// offsetSeedTimeout предел времени на перенос offset'ов из хранилища в Kafka при старте
+const offsetSeedTimeout = 10 * time.Second
+
+// SetOffsetStore подключает внешнее хранилище offset'ов. Вызывается до Start;
+// сервис подключает файловое хранилище при заданном KAFKA_OFFSET_STORE_PATH.
+// По умолчанию offset'ы хранит только Kafka. С хранилищем закоммиченные
+// offset'ы дополнительно сохраняются в него, а при exclusive=true — только
+// в него. При старте сохраненные offset'ы переносятся в consumer group,
+// и чтение продолжается с них.
+//
+// В exclusive режиме Kafka знает только offset'ы на момент старта: после
+// ребалансировки партиция перечитывается с них (at-least-once).
+func (c *Consumer) SetOffsetStore(store OffsetStore, exclusive bool) {
+	c.offsetStore = store
+	c.offsetStoreOnly = exclusive
+}
+
+// seedOffsets переносит offset'ы из внешнего хранилища в consumer group
+// до подключения reader'а к группе. Коммит с generation -1 принимается
+// брокером только пока в группе нет активных участников; иначе группа
+// продолжает с offset'ов Kafka, а отказ логируется.
+func (c *Consumer) seedOffsets(ctx context.Context) error {
+	if c.offsetStore == nil || c.backfill.Enabled {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, offsetSeedTimeout)
+	defer cancel()
+
+	offsets, err := c.offsetStore.LoadOffsets(ctx, c.config.GroupID, c.config.Topic)
+	if err != nil {
+		return fmt.Errorf("failed to load offsets from store: %w", err)
+	}
+	if len(offsets) == 0 {
+		c.logger.Info("Offset store is empty, starting from Kafka group offsets")
+		return nil
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(offsets))
+	for partition, offset := range offsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: partition, Offset: offset})
+	}
+
+	resp, err := c.offsetCommitter.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      c.config.GroupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{c.config.Topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to seed group offsets: %w", err)
+	}
+
+	var errs []error
+	for _, partition := range resp.Topics[c.config.Topic] {
+		if partition.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", partition.Partition, partition.Error))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		c.logger.WithError(err).Warn("Group rejected stored offsets, resuming from Kafka group offsets")
+		return nil
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"group_id":   c.config.GroupID,
+		"partitions": len(offsets),
+	}).Info("Resuming from offsets in external store")
+
+	return nil
+}
+
+// storeOffsets сохраняет во внешнее хранилище offset'ы закоммиченных сообщений
+func (c *Consumer) storeOffsets(ctx context.Context, messages []kafka.Message) error {
+	offsets := make(map[int]int64)
+	for _, message := range messages {
+		if next := message.Offset + 1; next > offsets[message.Partition] {
+			offsets[message.Partition] = next
+		}
+	}
+
+	if err := c.offsetStore.SaveOffsets(ctx, c.config.GroupID, c.config.Topic, offsets); err != nil {
+		return fmt.Errorf("failed to save offsets to store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/infrastructure/metrics"
+	"consumer-service/internal/infrastructure/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// memoryOffsetStore хранит offset'ы в памяти по группе и топику
+type memoryOffsetStore struct {
+	mu      sync.Mutex
+	offsets map[string]map[int]int64
+}
+
+func (s *memoryOffsetStore) SaveOffsets(_ context.Context, groupID, topic string, offsets map[int]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := groupID + "/" + topic
+	if s.offsets[key] == nil {
+		s.offsets[key] = map[int]int64{}
+	}
+	for partition, offset := range offsets {
+		s.offsets[key][partition] = offset
+	}
+	return nil
+}
+
+func (s *memoryOffsetStore) LoadOffsets(_ context.Context, groupID, topic string) (map[int]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offsets := map[int]int64{}
+	for partition, offset := range s.offsets[groupID+"/"+topic] {
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
+
+// recordingCommitter запоминает запросы коммита offset'ов группы
+type recordingCommitter struct {
+	mu       sync.Mutex
+	requests []*kafka.OffsetCommitRequest
+}
+
+func (c *recordingCommitter) OffsetCommit(_ context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	return &kafka.OffsetCommitResponse{}, nil
+}
+
+// runWithOffsetStore обрабатывает messages consumer'ом с хранилищем store
+// и останавливает его через Drain
+func runWithOffsetStore(t *testing.T, store OffsetStore, exclusive bool, committer groupOffsetCommitter, messages []kafka.Message) *fakeReader {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	consumer, err := NewConsumer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", ReadTimeout: time.Minute, CloseTimeout: time.Second},
+		config.ConsumerConfig{WorkerCount: 2, BatchSize: 100, CommitMode: config.CommitModeMessage},
+		&slowProcessor{}, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+	)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	reader := &fakeReader{messages: messages}
+	consumer.SetReader(reader)
+	consumer.SetOffsetStore(store, exclusive)
+	consumer.offsetCommitter = committer
+
+	runUntilRead(t, consumer, reader)
+	return reader
+}
+
+func TestConsumerOffsetStoreResume(t *testing.T) {
+	const total = 5
+
+	memoryStore := func(*testing.T) OffsetStore {
+		return &memoryOffsetStore{offsets: map[string]map[int]int64{}}
+	}
+	fileStore := func(t *testing.T) OffsetStore {
+		store, err := repository.NewFileOffsetStore(filepath.Join(t.TempDir(), "offsets.json"))
+		if err != nil {
+			t.Fatalf("NewFileOffsetStore: %v", err)
+		}
+		return store
+	}
+
+	tests := []struct {
+		name             string
+		newStore         func(*testing.T) OffsetStore
+		exclusive        bool
+		wantKafkaCommits int
+	}{
+		{name: "in addition to Kafka", newStore: memoryStore, exclusive: false, wantKafkaCommits: total},
+		{name: "exclusive", newStore: memoryStore, exclusive: true, wantKafkaCommits: 0},
+		{name: "file store", newStore: fileStore, exclusive: false, wantKafkaCommits: total},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.newStore(t)
+
+			// Первый запуск: хранилище пустое, offset'ы обработанных сообщений сохраняются
+			committer := &recordingCommitter{}
+			reader := runWithOffsetStore(t, store, tt.exclusive, committer, eventMessages(t, total))
+			if len(committer.requests) != 0 {
+				t.Fatalf("group seeded %d times from an empty store", len(committer.requests))
+			}
+			if len(reader.committed) != tt.wantKafkaCommits {
+				t.Fatalf("committed %d messages to Kafka, want %d", len(reader.committed), tt.wantKafkaCommits)
+			}
+			stored, _ := store.LoadOffsets(context.Background(), "test", "events")
+			if want := map[int]int64{0: total}; !reflect.DeepEqual(stored, want) {
+				t.Fatalf("stored offsets = %v, want %v", stored, want)
+			}
+
+			// Второй запуск переносит сохраненные offset'ы в группу до чтения
+			committer = &recordingCommitter{}
+			runWithOffsetStore(t, store, tt.exclusive, committer, nil)
+			if len(committer.requests) != 1 {
+				t.Fatalf("group seeded %d times, want 1", len(committer.requests))
+			}
+			req := committer.requests[0]
+			want := map[string][]kafka.OffsetCommit{"events": {{Partition: 0, Offset: total}}}
+			if req.GroupID != "test" || req.GenerationID != -1 || !reflect.DeepEqual(req.Topics, want) {
+				t.Fatalf("seed request = %+v, want group test, generation -1, topics %v", req, want)
+			}
+		})
+	}
+}
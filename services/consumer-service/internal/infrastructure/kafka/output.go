@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"consumer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// syncWriteBatchTimeout ожидание добора batch'а для синхронных записей по одному
+// сообщению. Значение kafka-go по умолчанию (1s) задерживало бы каждую запись
+// на секунду и ограничивало consumer примерно WorkerCount сообщениями в секунду.
+const syncWriteBatchTimeout = 10 * time.Millisecond
+
+// EventTransformer обработчик stream-этапа: обрабатывает событие и возвращает
+// событие для выходного топика (nil — публиковать нечего)
+type EventTransformer interface {
+	TransformEvent(ctx context.Context, event *domain.Event) (*domain.Event, error)
+}
+
+// OutputPublisher публикует результат трансформации в выходной топик.
+// Запись синхронная: входное сообщение коммитится только после нее.
+type OutputPublisher struct {
+	writer messageWriter
+	topic  string
+}
+
+// NewOutputPublisher создает publisher выходного топика
+func NewOutputPublisher(brokers []string, topic string, logger *logrus.Logger) *OutputPublisher {
+	return &OutputPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: syncWriteBatchTimeout,
+			ErrorLogger:  kafka.LoggerFunc(logger.Errorf),
+		},
+		topic: topic,
+	}
+}
+
+// Publish записывает событие в выходной топик. Ключ входного сообщения
+// сохраняется, чтобы события одного ключа оставались в одной партиции.
+func (p *OutputPublisher) Publish(ctx context.Context, input kafka.Message, event *domain.Event) error {
+	payload, err := event.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode output event: %w", err)
+	}
+
+	headers := []kafka.Header{
+		{Key: headerEventType, Value: []byte(event.Type)},
+		{Key: headerEventID, Value: []byte(event.ID)},
+		{Key: headerEventVersion, Value: []byte(event.Version)},
+		{Key: headerEventSource, Value: []byte(event.Source)},
+	}
+	if id, ok := event.Metadata[domain.MetadataCorrelationID]; ok {
+		headers = append(headers, kafka.Header{Key: "correlation-id", Value: []byte(id)})
+	}
+
+	key := input.Key
+	if len(key) == 0 {
+		key = []byte(event.ID)
+	}
+
+	if err := p.writer.WriteMessages(ctx, kafka.Message{Key: key, Value: payload, Headers: headers}); err != nil {
+		return fmt.Errorf("failed to publish to output topic %s: %w", p.topic, err)
+	}
+	return nil
+}
+
+// Close закрывает publisher выходного топика
+func (p *OutputPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+	"consumer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// orderLog общий журнал записей в выходной топик и коммитов
+type orderLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *orderLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *orderLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+// logWriter записывает "output:<ключ>" в журнал; первые failures записей отклоняет
+type logWriter struct {
+	log      *orderLog
+	mu       sync.Mutex
+	failures int
+}
+
+func (w *logWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("output topic unavailable")
+	}
+	for _, msg := range msgs {
+		w.log.add("output:" + string(msg.Key))
+	}
+	return nil
+}
+
+func (w *logWriter) Close() error { return nil }
+
+// logReader записывает "commit:<ключ>" в журнал при коммите
+type logReader struct {
+	fakeReader
+	log *orderLog
+}
+
+func (r *logReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		r.log.add("commit:" + string(msg.Key))
+	}
+	return r.fakeReader.CommitMessages(ctx, msgs...)
+}
+
+// upperTransformer публикует для каждого события событие с тем же типом и данными
+type upperTransformer struct{}
+
+func (upperTransformer) ProcessEvent(context.Context, *domain.Event) error { return nil }
+
+func (upperTransformer) TransformEvent(_ context.Context, event *domain.Event) (*domain.Event, error) {
+	return domain.NewEvent(event.Type, event.Data)
+}
+
+func TestConsumerOutputBeforeCommit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	const total = 6
+	tests := []struct {
+		name           string
+		outputFailures int
+	}{
+		{name: "output written before commit"},
+		{name: "failed output retried before commit", outputFailures: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer, err := NewConsumer(
+				config.KafkaConfig{
+					Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test",
+					DLQTopics: "default:dlq", OutputTopic: "events-out",
+					ReadTimeout: time.Minute, CommitInterval: time.Minute, CloseTimeout: time.Second,
+					MaxRetries: 3, RetryBackoff: time.Millisecond,
+				},
+				config.ConsumerConfig{WorkerCount: 2, BatchSize: total, CommitMode: config.CommitModeBatch},
+				upperTransformer{}, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+			)
+			if err != nil {
+				t.Fatalf("NewConsumer: %v", err)
+			}
+			log := &orderLog{}
+			consumer.output.writer = &logWriter{log: log, failures: tt.outputFailures}
+			dlq := &flakyWriter{}
+			consumer.dlq.writer = dlq
+
+			messages := eventMessages(t, total)
+			for i := range messages {
+				messages[i].Key = []byte(strconv.Itoa(i))
+			}
+			reader := &logReader{fakeReader: fakeReader{messages: messages}, log: log}
+			consumer.SetReader(reader)
+
+			runUntilRead(t, consumer, &reader.fakeReader)
+
+			// Каждое входное сообщение коммитится ровно раз и после записи своего результата
+			entries := log.snapshot()
+			position := make(map[string]int, len(entries))
+			for i, entry := range entries {
+				if _, seen := position[entry]; seen && strings.HasPrefix(entry, "commit:") {
+					t.Fatalf("%s committed twice: %v", entry, entries)
+				}
+				position[entry] = i
+			}
+			for i := range messages {
+				key := strconv.Itoa(i)
+				output, written := position["output:"+key]
+				commit, committed := position["commit:"+key]
+				if !written || !committed || output > commit {
+					t.Fatalf("message %s: output written=%v committed=%v, want output before commit: %v", key, written, committed, entries)
+				}
+			}
+			if _, written := dlq.stats(); written != 0 {
+				t.Fatalf("DLQ received %d messages, want none", written)
+			}
+		})
+	}
+}
+
+func TestNewConsumerOutputRequiresBatchMode(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	_, err := NewConsumer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", GroupID: "test", DLQTopics: "default:dlq", OutputTopic: "events-out"},
+		config.ConsumerConfig{WorkerCount: 1, BatchSize: 10, CommitMode: config.CommitModeMessage},
+		upperTransformer{}, nil, nil, logger, metrics.NewConsumerMetrics(prometheus.NewRegistry()),
+	)
+	if err == nil {
+		t.Fatal("NewConsumer accepted an output topic in message commit mode")
+	}
+}
@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"consumer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// defaultPriorityKey вес для типов, не перечисленных в CONSUMER_TYPE_PRIORITIES
+const defaultPriorityKey = "default"
+
+// priorityQueue очередь сообщений одного типа с весом
+type priorityQueue struct {
+	weight  int
+	current int
+	ch      chan queuedMessage
+}
+
+// priorityDispatcher раскладывает сообщения по очередям типов и передает их
+// worker'ам по smooth weighted round-robin: тип с весом 5 получает в пять раз
+// больше слотов, чем тип с весом 1, но ни одна непустая очередь не голодает.
+type priorityDispatcher struct {
+	queues   []*priorityQueue
+	byType   map[domain.EventType]*priorityQueue
+	fallback *priorityQueue
+	notify   chan struct{}
+	done     chan struct{}
+}
+
+// newPriorityDispatcher создает диспетчер из строки вида "payment_processed:5,user_created:1,default:1"
+func newPriorityDispatcher(spec string, capacity int) (*priorityDispatcher, error) {
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		eventType, value, ok := strings.Cut(pair, ":")
+		eventType = strings.TrimSpace(eventType)
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || eventType == "" || err != nil || weight <= 0 {
+			return nil, fmt.Errorf("invalid type priority %q", pair)
+		}
+
+		weights[eventType] = weight
+	}
+
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("type priorities are empty")
+	}
+
+	d := &priorityDispatcher{
+		byType: make(map[domain.EventType]*priorityQueue, len(weights)),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	for eventType, weight := range weights {
+		queue := &priorityQueue{weight: weight, ch: make(chan queuedMessage, capacity)}
+		d.queues = append(d.queues, queue)
+		if eventType == defaultPriorityKey {
+			d.fallback = queue
+			continue
+		}
+		d.byType[domain.EventType(eventType)] = queue
+	}
+	if d.fallback == nil {
+		d.fallback = &priorityQueue{weight: 1, ch: make(chan queuedMessage, capacity)}
+		d.queues = append(d.queues, d.fallback)
+	}
+
+	return d, nil
+}
+
+// queueFor возвращает очередь типа сообщения (по заголовку event-type)
+func (d *priorityDispatcher) queueFor(message kafka.Message) chan queuedMessage {
+	if queue, ok := d.byType[parseEventHeaders(message).Type]; ok {
+		return queue.ch
+	}
+	return d.fallback.ch
+}
+
+// signal будит run после постановки сообщения в очередь
+func (d *priorityDispatcher) signal() {
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+// close сообщает, что новых сообщений не будет; run дочитает очереди и завершится
+func (d *priorityDispatcher) close() {
+	close(d.done)
+}
+
+// run передает сообщения в out в порядке весов, пока не закроются очереди или ctx
+func (d *priorityDispatcher) run(ctx context.Context, out chan<- queuedMessage) {
+	defer close(out)
+
+	for {
+		queue := d.next()
+		if queue == nil {
+			// Все очереди пусты: ждем новое сообщение или завершение чтения
+			select {
+			case <-d.notify:
+				continue
+			case <-d.done:
+				if d.empty() {
+					return
+				}
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case out <- <-queue.ch:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// next выбирает непустую очередь по smooth weighted round-robin
+func (d *priorityDispatcher) next() *priorityQueue {
+	var best *priorityQueue
+	total := 0
+	for _, queue := range d.queues {
+		if len(queue.ch) == 0 {
+			continue
+		}
+
+		queue.current += queue.weight
+		total += queue.weight
+		if best == nil || queue.current > best.current {
+			best = queue
+		}
+	}
+
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// empty сообщает, что во всех очередях нет сообщений
+func (d *priorityDispatcher) empty() bool {
+	for _, queue := range d.queues {
+		if len(queue.ch) > 0 {
+			return false
+		}
+	}
+	return true
+}
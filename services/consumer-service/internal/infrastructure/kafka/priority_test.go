@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func typedMessage(eventType string) queuedMessage {
+	return queuedMessage{message: kafka.Message{Headers: []kafka.Header{{Key: headerEventType, Value: []byte(eventType)}}}}
+}
+
+// dispatchOrder заполняет очереди и считает, сколько слотов next выдал каждому типу
+func dispatchOrder(t *testing.T, d *priorityDispatcher, counts map[string]int, picks int) map[string]int {
+	t.Helper()
+
+	for eventType, n := range counts {
+		for i := 0; i < n; i++ {
+			d.queueFor(typedMessage(eventType).message) <- typedMessage(eventType)
+		}
+	}
+
+	picked := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		queue := d.next()
+		if queue == nil {
+			t.Fatalf("next returned nil with %d messages left", picks-i)
+		}
+		queued := <-queue.ch
+		picked[parseEventHeaders(queued.message).Type.String()]++
+	}
+	return picked
+}
+
+func TestPriorityDispatcherNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   string
+		counts map[string]int
+		picks  int
+		want   map[string]int
+	}{
+		{
+			name:   "slots follow weights",
+			spec:   "payment_processed:5,user_created:1",
+			counts: map[string]int{"payment_processed": 50, "user_created": 50},
+			picks:  12,
+			want:   map[string]int{"payment_processed": 10, "user_created": 2},
+		},
+		{
+			name:   "empty queue gives its slots away",
+			spec:   "payment_processed:5,user_created:1",
+			counts: map[string]int{"user_created": 3},
+			picks:  3,
+			want:   map[string]int{"user_created": 3},
+		},
+		{
+			name:   "unlisted types share the default queue",
+			spec:   "payment_processed:1,default:1",
+			counts: map[string]int{"payment_processed": 10, "user_created": 5, "order_placed": 5},
+			picks:  20,
+			want:   map[string]int{"payment_processed": 10, "user_created": 5, "order_placed": 5},
+		},
+		{
+			name:   "low weight queue is not starved",
+			spec:   "payment_processed:100,user_created:1",
+			counts: map[string]int{"payment_processed": 200, "user_created": 1},
+			picks:  101,
+			want:   map[string]int{"payment_processed": 100, "user_created": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newPriorityDispatcher(tt.spec, 256)
+			if err != nil {
+				t.Fatalf("newPriorityDispatcher: %v", err)
+			}
+
+			got := dispatchOrder(t, d, tt.counts, tt.picks)
+			for eventType, want := range tt.want {
+				if got[eventType] != want {
+					t.Errorf("%s got %d slots, want %d (all: %v)", eventType, got[eventType], want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPriorityDispatcherNextEmpty(t *testing.T) {
+	d, err := newPriorityDispatcher("payment_processed:5", 4)
+	if err != nil {
+		t.Fatalf("newPriorityDispatcher: %v", err)
+	}
+	if queue := d.next(); queue != nil {
+		t.Fatal("next picked a queue while all queues are empty")
+	}
+}
+
+func TestNewPriorityDispatcherInvalid(t *testing.T) {
+	for _, spec := range []string{"", " , ", "payment_processed", "payment_processed:0", "payment_processed:-1", ":3", "payment_processed:x"} {
+		if _, err := newPriorityDispatcher(spec, 4); err == nil {
+			t.Errorf("newPriorityDispatcher(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// minTopicWaitBackoff минимальная пауза между проверками топика
+const minTopicWaitBackoff = 100 * time.Millisecond
+
+// WaitForTopic ожидает появления метаданных топика перед началом чтения
+func (c *Consumer) WaitForTopic(ctx context.Context) error {
+	waitCtx, cancel := context.WithTimeout(ctx, c.config.TopicWaitTimeout)
+	defer cancel()
+
+	// Нижняя граница паузы: при KAFKA_RETRY_BACKOFF=0 цикл опрашивал бы брокер без пауз
+	backoff := max(c.config.RetryBackoff, minTopicWaitBackoff)
+	maxBackoff := 5 * time.Second
+
+	for attempt := 1; ; attempt++ {
+		err := c.checkTopic(waitCtx)
+		if err == nil {
+			c.logger.WithField("topic", c.config.Topic).Info("Kafka topic is available")
+			return nil
+		}
+
+		if c.config.AutoCreateTopic {
+			if createErr := c.createTopic(waitCtx); createErr != nil {
+				err = fmt.Errorf("%v; auto-create failed: %w", err, createErr)
+			}
+		}
+
+		c.logger.WithFields(logrus.Fields{
+			"topic":   c.config.Topic,
+			"attempt": attempt,
+			"backoff": backoff,
+			"error":   err,
+		}).Warn("Kafka topic is not available yet, waiting")
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("topic %q not available after %s: %w", c.config.Topic, c.config.TopicWaitTimeout, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// checkTopic проверяет наличие партиций топика в метаданных кластера
+func (c *Consumer) checkTopic(ctx context.Context) error {
+	conn, err := c.dialAny(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(c.config.Topic)
+	if err != nil {
+		return fmt.Errorf("failed to read partitions: %w", err)
+	}
+
+	if len(partitions) == 0 {
+		return fmt.Errorf("topic %q has no partitions", c.config.Topic)
+	}
+
+	return nil
+}
+
+// createTopic создает топик через controller брокер
+func (c *Consumer) createTopic(ctx context.Context) error {
+	conn, err := c.dialAny(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("failed to get controller: %w", err)
+	}
+
+	controllerConn, err := dialWithDeadline(ctx, net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to dial controller: %w", err)
+	}
+	defer controllerConn.Close()
+
+	err = controllerConn.CreateTopics(kafka.TopicConfig{
+		Topic:             c.config.Topic,
+		NumPartitions:     c.config.TopicPartitions,
+		ReplicationFactor: c.config.TopicReplicationFactor,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create topic: %w", err)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"topic":              c.config.Topic,
+		"partitions":         c.config.TopicPartitions,
+		"replication_factor": c.config.TopicReplicationFactor,
+	}).Info("Kafka topic created")
+
+	return nil
+}
+
+// dialAny подключается к первому доступному брокеру
+func (c *Consumer) dialAny(ctx context.Context) (*kafka.Conn, error) {
+	var lastErr error
+	for _, broker := range c.config.Brokers {
+		conn, err := dialWithDeadline(ctx, broker)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("failed to dial any broker: %w", lastErr)
+}
+
+// dialWithDeadline подключается к брокеру и переносит deadline контекста на соединение:
+// DialContext учитывает ctx только при подключении, и брокер, принявший соединение
+// и переставший отвечать, заблокировал бы ReadPartitions и CreateTopics навсегда
+func dialWithDeadline(ctx context.Context, address string) (*kafka.Conn, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set connection deadline: %w", err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,237 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// hangingBroker принимает соединения и никогда не отвечает на запросы
+func hangingBroker(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestWaitForTopicHangingBroker(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	consumer := &Consumer{
+		logger: logger,
+		config: config.KafkaConfig{
+			Brokers:          []string{hangingBroker(t)},
+			Topic:            "events",
+			TopicWaitTimeout: 300 * time.Millisecond,
+			AutoCreateTopic:  true,
+		},
+	}
+
+	start := time.Now()
+	err := consumer.WaitForTopic(context.Background())
+	if err == nil {
+		t.Fatal("WaitForTopic succeeded against a broker that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("WaitForTopic took %s, want it bounded by TOPIC_WAIT_TIMEOUT", elapsed)
+	}
+}
+
+// metadataBroker минимальный брокер Kafka: отвечает на ApiVersions и Metadata v1
+// и отдает партицию топика только начиная с appearsAfter-го запроса метаданных
+type metadataBroker struct {
+	addr         string
+	topic        string
+	appearsAfter int32
+	probes       atomic.Int32
+}
+
+func newMetadataBroker(t *testing.T, topic string, appearsAfter int32) *metadataBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	broker := &metadataBroker{addr: listener.Addr().String(), topic: topic, appearsAfter: appearsAfter}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(conn)
+		}
+	}()
+	return broker
+}
+
+func (b *metadataBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var header struct {
+			Size          int32
+			APIKey        int16
+			APIVersion    int16
+			CorrelationID int32
+		}
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			return
+		}
+		// Остаток запроса (client id и тело) не нужен
+		if _, err := r.Discard(int(header.Size) - 8); err != nil {
+			return
+		}
+
+		var body []byte
+		switch header.APIKey {
+		case 18: // ApiVersions: поддерживается только Metadata v1
+			body = binary.BigEndian.AppendUint16(nil, 0)
+			body = binary.BigEndian.AppendUint32(body, 1)
+			for _, v := range []uint16{3, 1, 1} {
+				body = binary.BigEndian.AppendUint16(body, v)
+			}
+		case 3: // Metadata
+			body = b.metadata(b.probes.Add(1) >= b.appearsAfter)
+		default:
+			return
+		}
+
+		response := binary.BigEndian.AppendUint32(nil, uint32(4+len(body)))
+		response = binary.BigEndian.AppendUint32(response, uint32(header.CorrelationID))
+		if _, err := conn.Write(append(response, body...)); err != nil {
+			return
+		}
+	}
+}
+
+// metadata кодирует ответ Metadata v1 с одним брокером и, если exists, топиком из одной партиции
+func (b *metadataBroker) metadata(exists bool) []byte {
+	appendString := func(buf []byte, s string) []byte {
+		return append(binary.BigEndian.AppendUint16(buf, uint16(len(s))), s...)
+	}
+	host, port, _ := net.SplitHostPort(b.addr)
+	portNumber, _ := net.LookupPort("tcp", port)
+
+	body := binary.BigEndian.AppendUint32(nil, 1) // brokers
+	body = binary.BigEndian.AppendUint32(body, 0) // node id
+	body = appendString(body, host)
+	body = binary.BigEndian.AppendUint32(body, uint32(portNumber))
+	body = appendString(body, "")                 // rack
+	body = binary.BigEndian.AppendUint32(body, 0) // controller id
+	if !exists {
+		return binary.BigEndian.AppendUint32(body, 0)
+	}
+	body = binary.BigEndian.AppendUint32(body, 1) // topics
+	body = binary.BigEndian.AppendUint16(body, 0) // error code
+	body = appendString(body, b.topic)
+	body = append(body, 0)                        // internal
+	body = binary.BigEndian.AppendUint32(body, 1) // partitions
+	body = binary.BigEndian.AppendUint16(body, 0) // error code
+	body = binary.BigEndian.AppendUint32(body, 0) // partition id
+	body = binary.BigEndian.AppendUint32(body, 0) // leader
+	body = binary.BigEndian.AppendUint32(body, 1) // replicas
+	body = binary.BigEndian.AppendUint32(body, 0)
+	body = binary.BigEndian.AppendUint32(body, 1) // isr
+	return binary.BigEndian.AppendUint32(body, 0)
+}
+
+func TestWaitForTopicRetriesUntilTopicAppears(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name         string
+		appearsAfter int32
+		timeout      time.Duration
+		wantErr      bool
+		wantProbes   int32
+	}{
+		{name: "topic exists", appearsAfter: 1, timeout: 5 * time.Second, wantProbes: 1},
+		{name: "topic appears on third probe", appearsAfter: 3, timeout: 5 * time.Second, wantProbes: 3},
+		{name: "topic never appears", appearsAfter: 1000, timeout: 250 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newMetadataBroker(t, "events", tt.appearsAfter)
+			consumer := &Consumer{
+				logger: logger,
+				config: config.KafkaConfig{
+					Brokers:          []string{broker.addr},
+					Topic:            "events",
+					TopicWaitTimeout: tt.timeout,
+					RetryBackoff:     time.Millisecond,
+				},
+			}
+
+			err := consumer.WaitForTopic(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WaitForTopic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			probes := broker.probes.Load()
+			if tt.wantErr {
+				if probes < 2 {
+					t.Fatalf("WaitForTopic probed %d times before the timeout, want retries", probes)
+				}
+				return
+			}
+			if probes != tt.wantProbes {
+				t.Fatalf("WaitForTopic probed %d times, want %d", probes, tt.wantProbes)
+			}
+		})
+	}
+}
+
+func TestConfirmIdleConnectivityHangingBroker(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	consumer := &Consumer{
+		logger:      logger,
+		readTimeout: 200 * time.Millisecond,
+		config:      config.KafkaConfig{Brokers: []string{hangingBroker(t)}, Topic: "events"},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		consumer.confirmIdleConnectivity(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("confirmIdleConnectivity blocked the read loop on a broker that never answers")
+	}
+	if consumer.ready.Load() {
+		t.Fatal("consumer marked ready without broker metadata")
+	}
+}
@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// utilizationTracker считает долю времени, которую worker'ы тратят на обработку
+// сообщений, а не на ожидание канала. Доля считается по окнам между выборками:
+// 1 — все worker'ы были заняты все окно (пул насыщен), 0 — простаивали.
+type utilizationTracker struct {
+	mu          sync.Mutex
+	busySince   []time.Time // начало текущей обработки; нулевое время — worker свободен
+	busy        time.Duration
+	windowStart time.Time
+	now         func() time.Time
+}
+
+// newUtilizationTracker создает tracker для пула из workers worker'ов
+func newUtilizationTracker(workers int) *utilizationTracker {
+	return &utilizationTracker{
+		busySince:   make([]time.Time, workers),
+		windowStart: time.Now(),
+		now:         time.Now,
+	}
+}
+
+// begin отмечает начало обработки сообщения worker'ом
+func (t *utilizationTracker) begin(worker int) {
+	t.mu.Lock()
+	t.busySince[worker] = t.now()
+	t.mu.Unlock()
+}
+
+// end отмечает завершение обработки; в текущее окно попадает только
+// часть обработки после его начала
+func (t *utilizationTracker) end(worker int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := t.busySince[worker]
+	if since.Before(t.windowStart) {
+		since = t.windowStart
+	}
+	t.busy += t.now().Sub(since)
+	t.busySince[worker] = time.Time{}
+}
+
+// sample возвращает утилизацию за окно с прошлой выборки и начинает новое окно.
+// Обработка, которая еще идет, учитывается до момента выборки.
+func (t *utilizationTracker) sample() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	busy := t.busy
+	for _, since := range t.busySince {
+		if since.IsZero() {
+			continue
+		}
+		if since.Before(t.windowStart) {
+			since = t.windowStart
+		}
+		busy += now.Sub(since)
+	}
+
+	window := now.Sub(t.windowStart) * time.Duration(len(t.busySince))
+	t.busy = 0
+	t.windowStart = now
+
+	if window <= 0 {
+		return 0
+	}
+	return min(float64(busy)/float64(window), 1)
+}
+
+// utilizationSampler периодически выгружает утилизацию worker'ов в метрики
+func (c *Consumer) utilizationSampler(ctx context.Context) {
+	ticker := time.NewTicker(c.utilizationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.metrics.SetWorkerUtilization(c.utilization.sample())
+		}
+	}
+}
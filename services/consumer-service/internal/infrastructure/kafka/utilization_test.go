@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock ручные часы для utilizationTracker
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestUtilizationTrackerSample(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		run     func(tracker *utilizationTracker, clock *fakeClock) float64
+		want    float64
+	}{
+		{
+			name:    "idle pool",
+			workers: 2,
+			run: func(tracker *utilizationTracker, clock *fakeClock) float64 {
+				clock.advance(time.Second)
+				return tracker.sample()
+			},
+			want: 0,
+		},
+		{
+			name:    "one of two workers busy all window",
+			workers: 2,
+			run: func(tracker *utilizationTracker, clock *fakeClock) float64 {
+				tracker.begin(0)
+				clock.advance(time.Second)
+				tracker.end(0)
+				return tracker.sample()
+			},
+			want: 0.5,
+		},
+		{
+			name:    "processing still running is counted up to the sample",
+			workers: 2,
+			run: func(tracker *utilizationTracker, clock *fakeClock) float64 {
+				tracker.begin(0)
+				tracker.begin(1)
+				clock.advance(time.Second)
+				return tracker.sample()
+			},
+			want: 1,
+		},
+		{
+			name:    "processing from the previous window counts from window start",
+			workers: 2,
+			run: func(tracker *utilizationTracker, clock *fakeClock) float64 {
+				tracker.begin(0)
+				clock.advance(time.Second)
+				tracker.sample()
+				clock.advance(time.Second)
+				tracker.end(0)
+				clock.advance(time.Second)
+				return tracker.sample()
+			},
+			want: 0.25,
+		},
+		{
+			name:    "empty window",
+			workers: 1,
+			run: func(tracker *utilizationTracker, clock *fakeClock) float64 {
+				return tracker.sample()
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+			tracker := newUtilizationTracker(tt.workers)
+			tracker.now = func() time.Time { return clock.now }
+			tracker.windowStart = clock.now
+
+			if got := tt.run(tracker, clock); got != tt.want {
+				t.Fatalf("sample() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SuppressedField поле с числом строк, отброшенных с момента предыдущей выведенной
+const SuppressedField = "sampled_suppressed"
+
+// SamplingFormatter выводит только каждую N-ю строку уровней debug и info.
+// Warn и более серьезные уровни не семплируются. Число отброшенных строк
+// добавляется к следующей выведенной строке debug/info.
+type SamplingFormatter struct {
+	inner      logrus.Formatter
+	rate       uint64
+	seen       atomic.Uint64
+	suppressed atomic.Uint64
+}
+
+var _ logrus.Formatter = (*SamplingFormatter)(nil)
+
+// NewSamplingFormatter оборачивает formatter семплированием 1-из-rate
+func NewSamplingFormatter(inner logrus.Formatter, rate int) *SamplingFormatter {
+	if rate < 1 {
+		rate = 1
+	}
+
+	return &SamplingFormatter{inner: inner, rate: uint64(rate)}
+}
+
+// Format форматирует запись или возвращает пустой вывод для отброшенной строки
+func (f *SamplingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if f.rate == 1 || entry.Level < logrus.InfoLevel {
+		return f.inner.Format(entry)
+	}
+
+	if (f.seen.Add(1)-1)%f.rate != 0 {
+		f.suppressed.Add(1)
+		return nil, nil
+	}
+
+	suppressed := f.suppressed.Swap(0)
+	if suppressed == 0 {
+		return f.inner.Format(entry)
+	}
+
+	// Data записи не меняем: она может разделяться с WithFields
+	sampled := entry.Dup()
+	sampled.Level = entry.Level
+	sampled.Message = entry.Message
+	sampled.Caller = entry.Caller
+	sampled.Data[SuppressedField] = suppressed
+	return f.inner.Format(sampled)
+}
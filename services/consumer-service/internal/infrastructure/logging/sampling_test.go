@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSamplingFormatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      int
+		level     logrus.Level
+		lines     int
+		wantLines int
+		// Значение SuppressedField во второй выведенной строке; 0 — поля нет
+		wantSuppressed float64
+	}{
+		{name: "debug sampled", rate: 10, level: logrus.DebugLevel, lines: 100, wantLines: 10, wantSuppressed: 9},
+		{name: "info sampled", rate: 4, level: logrus.InfoLevel, lines: 10, wantLines: 3, wantSuppressed: 3},
+		{name: "warn never sampled", rate: 10, level: logrus.WarnLevel, lines: 20, wantLines: 20},
+		{name: "rate 1 disables sampling", rate: 1, level: logrus.DebugLevel, lines: 20, wantLines: 20},
+		{name: "invalid rate disables sampling", rate: 0, level: logrus.DebugLevel, lines: 5, wantLines: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := logrus.New()
+			logger.SetOutput(&out)
+			logger.SetLevel(logrus.DebugLevel)
+			logger.SetFormatter(NewSamplingFormatter(&logrus.JSONFormatter{}, tt.rate))
+
+			for i := 0; i < tt.lines; i++ {
+				logger.WithField("n", i).Log(tt.level, "event processed")
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != tt.wantLines {
+				t.Fatalf("emitted %d lines, want %d", len(lines), tt.wantLines)
+			}
+
+			var second map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+				t.Fatalf("unmarshal line: %v", err)
+			}
+			suppressed, _ := second[SuppressedField].(float64)
+			if suppressed != tt.wantSuppressed {
+				t.Fatalf("%s = %v, want %v", SuppressedField, suppressed, tt.wantSuppressed)
+			}
+		})
+	}
+}
@@ -12,55 +12,155 @@ type ConsumerMetrics struct {
 	consumedEvents     *prometheus.CounterVec
 	failedEvents       *prometheus.CounterVec
 	processingDuration *prometheus.HistogramVec
+	e2eLatency         *prometheus.HistogramVec
 	lagGauge           *prometheus.GaugeVec
 	commitDuration     prometheus.Histogram
+	queueWait          prometheus.Histogram
+	messageDwell       prometheus.Histogram
+	messageSize        *prometheus.HistogramVec
+	commitFailures     *prometheus.CounterVec
+	fallingBehind      prometheus.Gauge
+	saturated          prometheus.Gauge
+	enqueueBlocked     prometheus.Counter
+	starting           prometheus.Gauge
+	workerUtilization  prometheus.Gauge
+	unknownFields      *prometheus.CounterVec
+	sinkDropped        *prometheus.CounterVec
 }
 
-// NewConsumerMetrics создает новые метрики для consumer
-func NewConsumerMetrics() *ConsumerMetrics {
+// NewConsumerMetrics создает новые метрики для consumer.
+// Если reg равен nil, метрики регистрируются в глобальном реестре.
+// headerLabels — дополнительные метки счетчика потребленных событий из заголовков сообщений.
+func NewConsumerMetrics(reg prometheus.Registerer, headerLabels ...string) *ConsumerMetrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	factory := promauto.With(reg)
+
 	return &ConsumerMetrics{
-		consumedEvents: promauto.NewCounterVec(
+		consumedEvents: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "consumer_events_consumed_total",
 				Help: "Total number of events consumed",
 			},
-			[]string{"event_type"},
+			append([]string{"event_type", "tenant"}, headerLabels...),
 		),
-		failedEvents: promauto.NewCounterVec(
+		failedEvents: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "consumer_events_failed_total",
 				Help: "Total number of failed events",
 			},
 			[]string{"event_type", "reason"},
 		),
-		processingDuration: promauto.NewHistogramVec(
+		processingDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "consumer_processing_duration_seconds",
 				Help:    "Duration of event processing",
 				Buckets: prometheus.DefBuckets,
 			},
+			[]string{"event_type", "status"},
+		),
+		e2eLatency: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "consumer_e2e_latency_seconds",
+				Help:    "Time from event creation to consumption",
+				Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+			},
 			[]string{"event_type"},
 		),
-		lagGauge: promauto.NewGaugeVec(
+		lagGauge: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "consumer_lag",
 				Help: "Consumer lag in messages",
 			},
 			[]string{"topic", "partition"},
 		),
-		commitDuration: promauto.NewHistogram(
+		commitDuration: factory.NewHistogram(
 			prometheus.HistogramOpts{
 				Name:    "consumer_commit_duration_seconds",
 				Help:    "Duration of offset commits",
 				Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0},
 			},
 		),
+		queueWait: factory.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "consumer_worker_queue_wait_seconds",
+				Help:    "Time messages spend in the worker channel before processing",
+				Buckets: []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+			},
+		),
+		messageDwell: factory.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "consumer_message_dwell_seconds",
+				Help:    "Time messages spend in the broker before being read (now minus Kafka message time)",
+				Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600},
+			},
+		),
+		messageSize: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "consumer_message_size_bytes",
+				Help:    "Size of consumed message payloads",
+				Buckets: prometheus.ExponentialBuckets(64, 4, 9), // 64B .. 4MB
+			},
+			[]string{"topic"},
+		),
+		commitFailures: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "consumer_commit_failures_total",
+				Help: "Total number of failed offset commits",
+			},
+			[]string{"reason"},
+		),
+		fallingBehind: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "consumer_falling_behind",
+				Help: "Whether processing rate has been below incoming rate (1) or not (0)",
+			},
+		),
+		saturated: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "consumer_saturated",
+				Help: "Whether the reader is blocked on a full worker channel (1) or not (0)",
+			},
+		),
+		enqueueBlocked: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "consumer_enqueue_blocked_seconds_total",
+				Help: "Total time the reader spent blocked on a full worker channel",
+			},
+		),
+		starting: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "consumer_starting",
+				Help: "Whether the consumer is warming up after start (1) or not (0)",
+			},
+		),
+		workerUtilization: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "consumer_worker_utilization_ratio",
+				Help: "Fraction of time workers spent processing messages over the last sampling interval (1 means the pool is saturated)",
+			},
+		),
+		unknownFields: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "consumer_unknown_fields_total",
+				Help: "Total number of events containing fields unknown to the consumer schema",
+			},
+			[]string{"event_type"},
+		),
+		sinkDropped: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "consumer_result_sink_dropped_total",
+				Help: "Total number of processing results not delivered to the result sink",
+			},
+			[]string{"reason"},
+		),
 	}
 }
 
 // IncConsumedEvents увеличивает счетчик потребленных событий
-func (m *ConsumerMetrics) IncConsumedEvents(eventType string) {
-	m.consumedEvents.WithLabelValues(eventType).Inc()
+func (m *ConsumerMetrics) IncConsumedEvents(eventType, tenant string, headerValues ...string) {
+	m.consumedEvents.WithLabelValues(append([]string{eventType, tenant}, headerValues...)...).Inc()
 }
 
 // IncFailedEvents увеличивает счетчик неудачных событий
@@ -68,12 +168,84 @@ func (m *ConsumerMetrics) IncFailedEvents(eventType string, reason string) {
 	m.failedEvents.WithLabelValues(eventType, reason).Inc()
 }
 
-// ObserveProcessingDuration записывает время обработки события
-func (m *ConsumerMetrics) ObserveProcessingDuration(eventType string, duration time.Duration) {
-	m.processingDuration.WithLabelValues(eventType).Observe(duration.Seconds())
+// ObserveProcessingDuration записывает время обработки события с итогом (success, failure, timeout)
+func (m *ConsumerMetrics) ObserveProcessingDuration(eventType, status string, duration time.Duration) {
+	m.processingDuration.WithLabelValues(eventType, status).Observe(duration.Seconds())
+}
+
+// ObserveE2ELatency записывает задержку от создания события до его чтения
+func (m *ConsumerMetrics) ObserveE2ELatency(eventType string, latency time.Duration) {
+	m.e2eLatency.WithLabelValues(eventType).Observe(latency.Seconds())
 }
 
 // ObserveCommitDuration записывает время коммита offset
 func (m *ConsumerMetrics) ObserveCommitDuration(duration time.Duration) {
 	m.commitDuration.Observe(duration.Seconds())
 }
+
+// ObserveQueueWait записывает время ожидания сообщения в очереди worker'ов
+func (m *ConsumerMetrics) ObserveQueueWait(wait time.Duration) {
+	m.queueWait.Observe(wait.Seconds())
+}
+
+// ObserveMessageDwell записывает время нахождения сообщения в брокере до чтения
+func (m *ConsumerMetrics) ObserveMessageDwell(dwell time.Duration) {
+	m.messageDwell.Observe(dwell.Seconds())
+}
+
+// ObserveMessageSize записывает размер payload прочитанного сообщения
+func (m *ConsumerMetrics) ObserveMessageSize(topic string, size int) {
+	m.messageSize.WithLabelValues(topic).Observe(float64(size))
+}
+
+// IncCommitFailures увеличивает счетчик неудачных коммитов offset
+func (m *ConsumerMetrics) IncCommitFailures(reason string) {
+	m.commitFailures.WithLabelValues(reason).Inc()
+}
+
+// SetFallingBehind устанавливает признак отставания обработки от поступления сообщений
+func (m *ConsumerMetrics) SetFallingBehind(behind bool) {
+	if behind {
+		m.fallingBehind.Set(1)
+		return
+	}
+	m.fallingBehind.Set(0)
+}
+
+// SetSaturated устанавливает признак насыщения пула worker'ов
+func (m *ConsumerMetrics) SetSaturated(saturated bool) {
+	if saturated {
+		m.saturated.Set(1)
+		return
+	}
+	m.saturated.Set(0)
+}
+
+// AddEnqueueBlocked добавляет время, которое reader простоял на полном канале worker'ов
+func (m *ConsumerMetrics) AddEnqueueBlocked(blocked time.Duration) {
+	m.enqueueBlocked.Add(blocked.Seconds())
+}
+
+// SetStarting устанавливает признак прогрева после старта
+func (m *ConsumerMetrics) SetStarting(starting bool) {
+	if starting {
+		m.starting.Set(1)
+		return
+	}
+	m.starting.Set(0)
+}
+
+// SetWorkerUtilization устанавливает долю времени, занятую обработкой в пуле worker'ов
+func (m *ConsumerMetrics) SetWorkerUtilization(utilization float64) {
+	m.workerUtilization.Set(utilization)
+}
+
+// IncUnknownFields увеличивает счетчик событий с неизвестными полями
+func (m *ConsumerMetrics) IncUnknownFields(eventType string) {
+	m.unknownFields.WithLabelValues(eventType).Inc()
+}
+
+// IncResultSinkDropped увеличивает счетчик неотправленных результатов обработки
+func (m *ConsumerMetrics) IncResultSinkDropped(reason string) {
+	m.sinkDropped.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterValue возвращает значение счетчика name с метками labels
+func counterValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			pairs := metric.GetLabel()
+			if len(pairs) != len(labels) {
+				continue
+			}
+			for _, pair := range pairs {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	t.Fatalf("series %s%v not found", name, labels)
+	return 0
+}
+
+func TestConsumerMetricsOnSeparateRegistries(t *testing.T) {
+	tests := []struct {
+		name         string
+		headerLabels []string
+		labels       map[string]string
+	}{
+		{name: "default labels", labels: map[string]string{"event_type": "user_created", "tenant": "acme"}},
+		{
+			name:         "header labels",
+			headerLabels: []string{"region"},
+			labels:       map[string]string{"event_type": "user_created", "tenant": "acme", "region": "eu"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Второй набор метрик с теми же именами не паникует на duplicate registration
+			first, second := prometheus.NewRegistry(), prometheus.NewRegistry()
+			firstMetrics := NewConsumerMetrics(first, tt.headerLabels...)
+			secondMetrics := NewConsumerMetrics(second, tt.headerLabels...)
+
+			headerValues := make([]string, 0, len(tt.headerLabels))
+			for _, label := range tt.headerLabels {
+				headerValues = append(headerValues, tt.labels[label])
+			}
+			firstMetrics.IncConsumedEvents("user_created", "acme", headerValues...)
+			secondMetrics.IncConsumedEvents("user_created", "acme", headerValues...)
+			secondMetrics.IncConsumedEvents("user_created", "acme", headerValues...)
+
+			if got := counterValue(t, first, "consumer_events_consumed_total", tt.labels); got != 1 {
+				t.Fatalf("consumed events in first registry = %v, want 1", got)
+			}
+			if got := counterValue(t, second, "consumer_events_consumed_total", tt.labels); got != 2 {
+				t.Fatalf("consumed events in second registry = %v, want 2", got)
+			}
+		})
+	}
+}
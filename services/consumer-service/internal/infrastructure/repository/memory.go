@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"consumer-service/internal/domain"
+)
+
+// MemoryRepository хранит последние результаты обработки в памяти
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	results  []*domain.ProcessingResult
+	capacity int
+	next     int
+	full     bool
+}
+
+// NewMemoryRepository создает новый in-memory репозиторий ограниченного размера
+func NewMemoryRepository(capacity int) *MemoryRepository {
+	if capacity <= 0 {
+		capacity = 1000 // default capacity
+	}
+
+	return &MemoryRepository{
+		results:  make([]*domain.ProcessingResult, capacity),
+		capacity: capacity,
+	}
+}
+
+// SaveResult сохраняет результат, вытесняя самый старый при переполнении
+func (r *MemoryRepository) SaveResult(_ context.Context, result *domain.ProcessingResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results[r.next] = result
+	r.next = (r.next + 1) % r.capacity
+	if r.next == 0 {
+		r.full = true
+	}
+
+	return nil
+}
+
+// GetResults возвращает результаты, подходящие под фильтр, от новых к старым
+func (r *MemoryRepository) GetResults(_ context.Context, filter domain.ResultFilter) ([]*domain.ProcessingResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	size := r.next
+	if r.full {
+		size = r.capacity
+	}
+
+	results := make([]*domain.ProcessingResult, 0)
+	for i := 0; i < size; i++ {
+		idx := (r.next - 1 - i + r.capacity) % r.capacity
+		result := r.results[idx]
+		if !filter.Matches(result) {
+			continue
+		}
+
+		results = append(results, result)
+		if filter.Limit > 0 && len(results) >= filter.Limit {
+			break
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"consumer-service/internal/domain"
+)
+
+func TestMemoryRepositoryGetResults(t *testing.T) {
+	success, failure := true, false
+
+	tests := []struct {
+		name     string
+		capacity int
+		saved    int // результаты evt-0..evt-(saved-1); нечетные — неудачные
+		filter   domain.ResultFilter
+		want     []string
+	}{
+		{name: "newest first", capacity: 5, saved: 3, want: []string{"evt-2", "evt-1", "evt-0"}},
+		{name: "failures only", capacity: 5, saved: 4, filter: domain.ResultFilter{Success: &failure}, want: []string{"evt-3", "evt-1"}},
+		{name: "successes only", capacity: 5, saved: 4, filter: domain.ResultFilter{Success: &success}, want: []string{"evt-2", "evt-0"}},
+		{name: "event type", capacity: 5, saved: 3, filter: domain.ResultFilter{EventType: "payment_processed"}, want: nil},
+		{name: "limit", capacity: 5, saved: 4, filter: domain.ResultFilter{Limit: 2}, want: []string{"evt-3", "evt-2"}},
+		{name: "exactly full", capacity: 3, saved: 3, want: []string{"evt-2", "evt-1", "evt-0"}},
+		{name: "wrap-around evicts oldest", capacity: 3, saved: 7, want: []string{"evt-6", "evt-5", "evt-4"}},
+		{name: "wrap-around failures", capacity: 3, saved: 7, filter: domain.ResultFilter{Success: &failure}, want: []string{"evt-5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryRepository(tt.capacity)
+			for i := 0; i < tt.saved; i++ {
+				result := &domain.ProcessingResult{
+					EventID:   fmt.Sprintf("evt-%d", i),
+					EventType: domain.UserCreatedEvent,
+					Success:   i%2 == 0,
+				}
+				if err := repo.SaveResult(context.Background(), result); err != nil {
+					t.Fatalf("SaveResult: %v", err)
+				}
+			}
+
+			results, err := repo.GetResults(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("GetResults: %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.EventID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("GetResults = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
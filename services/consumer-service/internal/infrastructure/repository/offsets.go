@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileOffsetStore хранит offset'ы consumer group'ов в JSON-файле.
+// Файл перезаписывается целиком через временный файл и rename, поэтому
+// после сбоя в нем остается либо старая, либо новая версия offset'ов.
+type FileOffsetStore struct {
+	mu      sync.Mutex
+	path    string
+	offsets map[string]map[int]int64 // "group/topic" -> партиция -> offset
+}
+
+// NewFileOffsetStore открывает хранилище offset'ов в файле path;
+// отсутствующий файл означает пустое хранилище
+func NewFileOffsetStore(path string) (*FileOffsetStore, error) {
+	store := &FileOffsetStore{
+		path:    path,
+		offsets: make(map[string]map[int]int64),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offset store: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.offsets); err != nil {
+		return nil, fmt.Errorf("failed to parse offset store %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// SaveOffsets обновляет offset'ы партиций группы и сохраняет файл
+func (s *FileOffsetStore) SaveOffsets(_ context.Context, groupID, topic string, offsets map[int]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := groupID + "/" + topic
+	if s.offsets[key] == nil {
+		s.offsets[key] = make(map[int]int64, len(offsets))
+	}
+	for partition, offset := range offsets {
+		s.offsets[key][partition] = offset
+	}
+
+	return s.persist()
+}
+
+// LoadOffsets возвращает сохраненные offset'ы партиций группы
+func (s *FileOffsetStore) LoadOffsets(_ context.Context, groupID, topic string) (map[int]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offsets := make(map[int]int64)
+	for partition, offset := range s.offsets[groupID+"/"+topic] {
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
+
+// persist атомарно записывает offset'ы в файл; вызывается под s.mu
+func (s *FileOffsetStore) persist() error {
+	data, err := json.Marshal(s.offsets)
+	if err != nil {
+		return fmt.Errorf("failed to encode offsets: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create offset store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write offset store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync offset store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close offset store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace offset store: %w", err)
+	}
+	return nil
+}
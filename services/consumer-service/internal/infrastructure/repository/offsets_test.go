@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileOffsetStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "offsets.json")
+
+	store, err := NewFileOffsetStore(path)
+	if err != nil {
+		t.Fatalf("NewFileOffsetStore: %v", err)
+	}
+	saves := []struct {
+		group   string
+		offsets map[int]int64
+	}{
+		{group: "billing", offsets: map[int]int64{0: 10, 1: 4}},
+		{group: "billing", offsets: map[int]int64{1: 7}},
+		{group: "analytics", offsets: map[int]int64{0: 3}},
+	}
+	for _, save := range saves {
+		if err := store.SaveOffsets(ctx, save.group, "events", save.offsets); err != nil {
+			t.Fatalf("SaveOffsets(%s): %v", save.group, err)
+		}
+	}
+
+	// Новый экземпляр читает offset'ы, сохраненные предыдущим
+	reopened, err := NewFileOffsetStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	tests := []struct {
+		group, topic string
+		want         map[int]int64
+	}{
+		{group: "billing", topic: "events", want: map[int]int64{0: 10, 1: 7}},
+		{group: "analytics", topic: "events", want: map[int]int64{0: 3}},
+		{group: "billing", topic: "orders", want: map[int]int64{}},
+	}
+	for _, tt := range tests {
+		got, err := reopened.LoadOffsets(ctx, tt.group, tt.topic)
+		if err != nil {
+			t.Fatalf("LoadOffsets(%s, %s): %v", tt.group, tt.topic, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("LoadOffsets(%s, %s) = %v, want %v", tt.group, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestNewFileOffsetStore(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "missing file is empty", path: filepath.Join(dir, "missing.json")},
+		{name: "corrupt file", path: corrupt, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewFileOffsetStore(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFileOffsetStore error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			offsets, _ := store.LoadOffsets(context.Background(), "test", "events")
+			if len(offsets) != 0 {
+				t.Fatalf("offsets = %v, want empty", offsets)
+			}
+		})
+	}
+}
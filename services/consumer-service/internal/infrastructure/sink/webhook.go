@@ -0,0 +1,182 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SinkMetrics интерфейс для метрик отправки результатов
+type SinkMetrics interface {
+	IncResultSinkDropped(reason string)
+}
+
+// WebhookResultSink отправляет результаты обработки POST запросом на внешний webhook.
+// Отправка асинхронная: результаты ставятся в ограниченную очередь и при ее
+// переполнении отбрасываются, чтобы не блокировать обработку событий.
+type WebhookResultSink struct {
+	url          string
+	authHeader   string
+	client       *http.Client
+	queue        chan *domain.ProcessingResult
+	stop         chan struct{}
+	maxRetries   int
+	retryBackoff time.Duration
+	logger       *logrus.Logger
+	metrics      SinkMetrics
+	wg           sync.WaitGroup
+	mu           sync.RWMutex
+	closed       bool
+}
+
+var _ domain.ResultSink = (*WebhookResultSink)(nil)
+
+// NewWebhookResultSink создает sink и запускает worker отправки
+func NewWebhookResultSink(cfg config.WebhookConfig, logger *logrus.Logger, metrics SinkMetrics) (*WebhookResultSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is empty")
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1000 // default queue size
+	}
+
+	s := &WebhookResultSink{
+		url:          cfg.URL,
+		authHeader:   cfg.AuthHeader,
+		client:       &http.Client{Timeout: cfg.Timeout},
+		queue:        make(chan *domain.ProcessingResult, queueSize),
+		stop:         make(chan struct{}),
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		logger:       logger,
+		metrics:      metrics,
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	logger.WithFields(logrus.Fields{
+		"url":        cfg.URL,
+		"queue_size": queueSize,
+	}).Info("Webhook result sink started")
+
+	return s, nil
+}
+
+// Send ставит результат в очередь отправки, не блокируясь
+func (s *WebhookResultSink) Send(result *domain.ProcessingResult) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.metrics.IncResultSinkDropped("closed")
+		return
+	}
+
+	select {
+	case s.queue <- result:
+	default:
+		s.metrics.IncResultSinkDropped("queue_full")
+	}
+}
+
+// Close прекращает прием результатов и дожидается отправки очереди.
+// Повторы после Close не выполняются: каждый оставшийся результат
+// отправляется одной попыткой, чтобы backoff не задерживал остановку.
+func (s *WebhookResultSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	close(s.stop)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// run отправляет результаты из очереди
+func (s *WebhookResultSink) run() {
+	defer s.wg.Done()
+
+	for result := range s.queue {
+		if err := s.deliver(result); err != nil {
+			s.metrics.IncResultSinkDropped("delivery_failed")
+			s.logger.WithFields(logrus.Fields{
+				"event_id": result.EventID,
+				"error":    err,
+			}).Warn("Failed to deliver processing result to webhook")
+		}
+	}
+}
+
+// deliver отправляет результат с повторами
+func (s *WebhookResultSink) deliver(result *domain.ProcessingResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 && !s.wait(s.retryBackoff*time.Duration(attempt)) {
+			return fmt.Errorf("sink closed after %d attempts: %w", attempt, lastErr)
+		}
+
+		if lastErr = s.post(body); lastErr == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", s.maxRetries+1, lastErr)
+}
+
+// wait ждет d и возвращает false, если sink закрыт раньше
+func (s *WebhookResultSink) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-s.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// post выполняет один POST запрос
+func (s *WebhookResultSink) post(body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.authHeader != "" {
+		req.Header.Set("Authorization", s.authHeader)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
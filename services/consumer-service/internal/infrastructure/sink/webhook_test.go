@@ -0,0 +1,159 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"consumer-service/internal/config"
+	"consumer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// droppedCounter считает отброшенные результаты по причинам
+type droppedCounter struct {
+	mu      sync.Mutex
+	dropped map[string]int
+}
+
+func (m *droppedCounter) IncResultSinkDropped(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped[reason]++
+}
+
+func TestWebhookCloseInterruptsRetryBackoff(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	counter := &droppedCounter{dropped: map[string]int{}}
+	sink, err := NewWebhookResultSink(
+		config.WebhookConfig{URL: server.URL, QueueSize: 10, Timeout: time.Second, MaxRetries: 3, RetryBackoff: time.Minute},
+		logger, counter,
+	)
+	if err != nil {
+		t.Fatalf("NewWebhookResultSink: %v", err)
+	}
+
+	// Первая попытка отклонена, worker ждет повтора
+	sink.Send(&domain.ProcessingResult{EventID: "first"})
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("webhook not called")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Результат в очереди на момент Close отправляется одной попыткой
+	sink.Send(&domain.ProcessingResult{EventID: "queued"})
+
+	start := time.Now()
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Close took %v, want it to skip the retry backoff", elapsed)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("webhook called %d times, want 2", got)
+	}
+	if got := counter.dropped["delivery_failed"]; got != 2 {
+		t.Fatalf("delivery_failed counted %d times, want 2", got)
+	}
+}
+
+func TestWebhookDeliversResults(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	processedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	results := []*domain.ProcessingResult{
+		{EventID: "user_created_1", EventType: domain.UserCreatedEvent, Success: true, Duration: 15 * time.Millisecond, ProcessedAt: processedAt},
+		{EventID: "user_created_2", EventType: domain.UserCreatedEvent, Error: "downstream unavailable", Duration: time.Second, ProcessedAt: processedAt},
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		// failures первых запросов отклоняются с 503
+		failures int64
+	}{
+		{name: "delivered with auth header", authHeader: "Bearer s3cret"},
+		{name: "delivered without auth header"},
+		{name: "delivered after retry", authHeader: "Bearer s3cret", failures: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				received []*domain.ProcessingResult
+				requests atomic.Int64
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != tt.authHeader {
+					t.Errorf("request %s with Content-Type %q, Authorization %q", r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"))
+				}
+				var result domain.ProcessingResult
+				if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+					t.Errorf("decode webhook body: %v", err)
+				}
+				mu.Lock()
+				received = append(received, &result)
+				mu.Unlock()
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			counter := &droppedCounter{dropped: map[string]int{}}
+			sink, err := NewWebhookResultSink(
+				config.WebhookConfig{URL: server.URL, AuthHeader: tt.authHeader, QueueSize: 10, Timeout: time.Second, MaxRetries: 2, RetryBackoff: time.Millisecond},
+				logger, counter,
+			)
+			if err != nil {
+				t.Fatalf("NewWebhookResultSink: %v", err)
+			}
+
+			for _, result := range results {
+				sink.Send(result)
+			}
+			// Повтор выполняется до Close: после него результат отправляется одной попыткой
+			deadline := time.Now().Add(5 * time.Second)
+			for requests.Load() < int64(len(results))+tt.failures {
+				if time.Now().After(deadline) {
+					t.Fatalf("webhook called %d times, want %d", requests.Load(), int64(len(results))+tt.failures)
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(received, results) {
+				t.Fatalf("webhook received %+v, want %+v", received, results)
+			}
+			if len(counter.dropped) != 0 {
+				t.Fatalf("dropped results = %v, want none", counter.dropped)
+			}
+		})
+	}
+}
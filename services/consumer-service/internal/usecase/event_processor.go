@@ -10,23 +10,30 @@ import (
 
 // EventProcessor реализует обработку событий
 type EventProcessor struct {
-	logger *logrus.Logger
+	logger   *logrus.Logger
+	redactor *domain.Redactor
 }
 
-// NewEventProcessor создает новый обработчик событий
-func NewEventProcessor(logger *logrus.Logger) *EventProcessor {
+// NewEventProcessor создает новый обработчик событий. redactor скрывает
+// поля data в логах; nil — data логируется как есть.
+func NewEventProcessor(logger *logrus.Logger, redactor *domain.Redactor) *EventProcessor {
 	return &EventProcessor{
-		logger: logger,
+		logger:   logger,
+		redactor: redactor,
 	}
 }
 
 // ProcessEvent обрабатывает событие
 func (p *EventProcessor) ProcessEvent(ctx context.Context, event *domain.Event) error {
+	correlationID, _ := domain.CorrelationIDFromContext(ctx)
+	tenant, _ := domain.TenantFromContext(ctx)
 	p.logger.WithFields(logrus.Fields{
-		"event_id":   event.ID,
-		"event_type": event.Type,
-		"source":     event.Source,
-		"timestamp":  event.Timestamp,
+		"event_id":       event.ID,
+		"event_type":     event.Type,
+		"source":         event.Source,
+		"timestamp":      event.Timestamp,
+		"correlation_id": correlationID,
+		"tenant_id":      tenant,
 	}).Debug("Processing event")
 
 	// Проверяем контекст
@@ -45,12 +52,22 @@ func (p *EventProcessor) ProcessEvent(ctx context.Context, event *domain.Event)
 	}
 }
 
+// TransformEvent обрабатывает событие и передает его дальше без изменений:
+// при KAFKA_OUTPUT_TOPIC сервис работает как ретранслятор обработанных событий
+func (p *EventProcessor) TransformEvent(ctx context.Context, event *domain.Event) (*domain.Event, error) {
+	if err := p.ProcessEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
 // processUserCreated обрабатывает событие создания пользователя
 func (p *EventProcessor) processUserCreated(ctx context.Context, event *domain.Event) error {
+	data := p.redactor.Redact(event.Data)
 	p.logger.WithFields(logrus.Fields{
 		"user_id":  event.ID,
-		"username": event.Data,
-		"email":    event.Data,
+		"username": data,
+		"email":    data,
 	}).Debug("User created event processed")
 
 	// Проверяем контекст перед обработкой
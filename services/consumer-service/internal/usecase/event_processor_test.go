@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"consumer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestProcessEventRedactsLoggedData(t *testing.T) {
+	const data = `{"email":"ann@example.com","ssn":"123-45-6789","plan":"pro"}`
+
+	tests := []struct {
+		name     string
+		redactor *domain.Redactor
+		wantData string
+	}{
+		{name: "configured fields masked", redactor: domain.NewRedactor([]string{"email", "ssn"}), wantData: `{"email":"[REDACTED]","plan":"pro","ssn":"[REDACTED]"}`},
+		{name: "nil redactor logs data as is", wantData: data},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			logger.SetLevel(logrus.DebugLevel)
+
+			event, err := domain.NewEvent(domain.UserCreatedEvent, data)
+			if err != nil {
+				t.Fatalf("NewEvent: %v", err)
+			}
+			if err := NewEventProcessor(logger, tt.redactor).ProcessEvent(context.Background(), event); err != nil {
+				t.Fatalf("ProcessEvent: %v", err)
+			}
+
+			var logged int
+			for _, entry := range hook.AllEntries() {
+				for _, field := range []string{"username", "email"} {
+					if value, ok := entry.Data[field]; ok {
+						logged++
+						if value != tt.wantData {
+							t.Fatalf("%q logged as %v, want %s", field, value, tt.wantData)
+						}
+					}
+				}
+			}
+			if logged == 0 {
+				t.Fatal("event data was not logged")
+			}
+		})
+	}
+}
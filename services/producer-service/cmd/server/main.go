@@ -2,20 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
-	"syscall"
 	"time"
 
 	"producer-service/internal/config"
 	"producer-service/internal/delivery/http/handlers"
 	"producer-service/internal/delivery/http/middleware"
+	"producer-service/internal/domain"
+	"producer-service/internal/infrastructure/audit"
 	"producer-service/internal/infrastructure/kafka"
 	"producer-service/internal/infrastructure/metrics"
 	"producer-service/internal/usecase"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
@@ -30,6 +34,9 @@ func main() {
 		logger.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	// Лимит размера данных события действует для всей доменной валидации
+	domain.SetMaxDataLength(cfg.Event.MaxDataBytes)
+
 	logger.WithFields(logrus.Fields{
 		"app_name":    cfg.App.Name,
 		"version":     cfg.App.Version,
@@ -41,14 +48,55 @@ func main() {
 	defer cancel()
 
 	// Инициализируем метрики
-	producerMetrics := metrics.NewProducerMetrics()
-	httpMetrics := metrics.NewHTTPMetrics()
+	var metricsOpts []metrics.ProducerMetricsOption
+	if cfg.Metrics.PublishSummary {
+		metricsOpts = append(metricsOpts, metrics.WithPublishSummary(cfg.Metrics.PublishSummaryQuantiles))
+	}
+	producerMetrics := metrics.NewProducerMetrics(prometheus.DefaultRegisterer, metricsOpts...)
+	httpMetrics := metrics.NewHTTPMetrics(prometheus.DefaultRegisterer)
+
+	// Журнал аудита закрывается после producer'а, чтобы попали события финального batch'а
+	var auditLogger *audit.AuditLogger
+	if cfg.Logging.AuditPath != "" {
+		auditLogger, err = audit.NewAuditLogger(cfg.Logging.AuditPath, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open audit log")
+		}
+		defer func() {
+			if err := auditLogger.Close(); err != nil {
+				logger.WithError(err).Error("Failed to close audit log")
+			}
+		}()
+	}
+
+	// Спул недоставленных событий открывается до producer'а, чтобы закрыться после него
+	var spool *kafka.Spool
+	if cfg.Kafka.SpoolPath != "" {
+		spool, err = kafka.NewSpool(cfg.Kafka.SpoolPath, cfg.Kafka.SpoolMaxBytes, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open Kafka spool")
+		}
+		defer func() {
+			if err := spool.Close(); err != nil {
+				logger.WithError(err).Error("Failed to close Kafka spool")
+			}
+		}()
+	}
 
 	// Инициализируем Kafka producer
-	kafkaProducer, err := kafka.NewProducer(cfg.Kafka, logger, producerMetrics)
+	eventCodec := domain.NewEventCodec(cfg.Event.JSONOmitEmpty)
+	eventCodec.Compression = cfg.Event.DataCompression
+	eventCodec.CompressionMinBytes = cfg.Event.DataCompressionMinBytes
+	kafkaProducer, err := kafka.NewProducer(cfg.Kafka, eventCodec, logger, producerMetrics)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to create Kafka producer")
 	}
+	if auditLogger != nil {
+		kafkaProducer.SetAuditor(auditLogger)
+	}
+	if spool != nil {
+		kafkaProducer.SetSpool(spool, cfg.Kafka.SpoolReplayInterval)
+	}
 	defer func() {
 		if err := kafkaProducer.Close(); err != nil {
 			logger.WithError(err).Error("Failed to close Kafka producer")
@@ -60,33 +108,125 @@ func main() {
 		logger.WithError(err).Fatal("Failed to start Kafka producer workers")
 	}
 
+	// Статистика kafka.Writer (ретраи, время batch'а, размеры) в метриках
+	if cfg.Metrics.WriterStatsInterval > 0 {
+		go kafkaProducer.RunStatsCollector(ctx, cfg.Metrics.WriterStatsInterval)
+	}
+
+	// Fan-out: каждое событие дополнительно пишется в KAFKA_FANOUT_TOPICS
+	// отдельными producer'ами со своими batch'ами
+	var publisher domain.EventPublisher = kafkaProducer
+	fanoutProducers := make(map[string]*kafka.Producer, len(cfg.Kafka.FanoutTopics))
+	if len(cfg.Kafka.FanoutTopics) > 0 {
+		topics := []kafka.TopicPublisher{{Topic: cfg.Kafka.Topic, Publisher: kafkaProducer}}
+		for _, topic := range cfg.Kafka.FanoutTopics {
+			topicCfg := cfg.Kafka
+			topicCfg.Topic = topic
+			topicMetrics := metrics.NewFanoutProducerMetrics(prometheus.DefaultRegisterer, topic, metricsOpts...)
+			topicProducer, err := kafka.NewProducer(topicCfg, eventCodec, logger, topicMetrics)
+			if err != nil {
+				logger.WithError(err).WithField("topic", topic).Fatal("Failed to create fan-out Kafka producer")
+			}
+			defer func() {
+				if err := topicProducer.Close(); err != nil {
+					logger.WithError(err).WithField("topic", topic).Error("Failed to close fan-out Kafka producer")
+				}
+			}()
+			if err := topicProducer.Start(ctx); err != nil {
+				logger.WithError(err).WithField("topic", topic).Fatal("Failed to start fan-out Kafka producer workers")
+			}
+
+			fanoutProducers[topic] = topicProducer
+			topics = append(topics, kafka.TopicPublisher{Topic: topic, Publisher: topicProducer})
+		}
+
+		fanout, err := kafka.NewMultiTopicPublisher(topics, producerMetrics)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to create fan-out publisher")
+		}
+		publisher = fanout
+	}
+
 	// Инициализируем сервисы
-	eventService := usecase.NewEventService(kafkaProducer, logger)
+	eventService := usecase.NewEventService(publisher, domain.NewEventFactory(domain.DefaultIDGenerator), logger,
+		usecase.SourceEnricher(cfg.App.Name),
+		usecase.ServiceInfoEnricher(cfg.App.Version, cfg.App.Environment),
+		usecase.CorrelationIDEnricher(),
+		usecase.TenantEnricher(),
+	)
 
 	// Инициализируем handlers
-	eventHandler := handlers.NewEventHandler(eventService, logger, httpMetrics)
-	healthHandler := handlers.NewHealthHandler()
+	eventHandler := handlers.NewEventHandler(eventService, logger, httpMetrics, domain.NewRedactor(cfg.Logging.RedactFields))
+
+	// Реестр проверок готовности: Kafka критична, очередь async — advisory
+	healthRegistry := usecase.NewHealthRegistry(cfg.Server.HealthTimeout)
+	healthRegistry.Register("kafka", kafkaProducer, true)
+	for topic, topicProducer := range fanoutProducers {
+		healthRegistry.Register("kafka_fanout_"+topic, topicProducer, true)
+	}
+
+	// Асинхронная публикация: обогащение в пуле worker'ов, ответ 202 Accepted
+	var asyncHandler *handlers.AsyncEventHandler
+	if cfg.Async.Enabled {
+		asyncService := usecase.NewAsyncEventService(eventService, cfg.Async.Workers, cfg.Async.QueueSize, cfg.Async.StatusCapacity, logger)
+		asyncService.Start()
+		defer asyncService.Close()
+		healthRegistry.Register("async_queue", asyncService, false)
+
+		asyncHandler = handlers.NewAsyncEventHandler(asyncService, eventHandler)
+	}
+	healthHandler := handlers.NewHealthHandler(cfg.App.Name, cfg.App.Version, cfg.Server.HealthVerbose, healthRegistry)
 
 	// Настраиваем роутер
 	router := mux.NewRouter()
 
 	// Применяем middleware
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.TenantMiddleware())
 	router.Use(middleware.LoggingMiddleware(logger))
 	router.Use(middleware.RecoveryMiddleware(logger))
 	router.Use(middleware.CORSMiddleware())
+	if cfg.Server.CompressionEnabled {
+		router.Use(middleware.CompressionMiddleware(cfg.Server.CompressionMinSize, cfg.Metrics.Path))
+	}
 
 	// Регистрируем маршруты
 	api := router.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/events/user", eventHandler.CreateUserEvent).Methods("POST")
+	if cfg.Server.MaxInFlight > 0 {
+		api.Use(middleware.InFlightLimitMiddleware(cfg.Server.MaxInFlight, httpMetrics))
+	}
+	if cfg.Server.IdempotencyCapacity > 0 {
+		store := middleware.NewIdempotencyStore(cfg.Server.IdempotencyTTL, cfg.Server.IdempotencyCapacity)
+		// Data — JSON строка: экранирование \u00XX раздувает ее до 6 раз,
+		// еще 64 KiB оставлено на остальные поля и метаданные
+		maxBody := int64(cfg.Event.MaxDataBytes)*6 + 64<<10
+		api.Use(middleware.IdempotencyMiddleware(store, maxBody))
+	}
+	if asyncHandler != nil {
+		api.HandleFunc("/events/user", asyncHandler.CreateUserEvent).Methods("POST")
+		api.HandleFunc("/events/{id}/status", asyncHandler.GetEventStatus).Methods("GET")
+	} else {
+		api.HandleFunc("/events/user", eventHandler.CreateUserEvent).Methods("POST")
+	}
 	api.HandleFunc("/events/stats", eventHandler.GetEventStats).Methods("GET")
 
+	// Административные маршруты для тестовых окружений (только с APP_ADMIN_TOKEN)
+	if cfg.App.AdminToken != "" {
+		production := cfg.App.Environment == config.EnvironmentProduction
+		adminHandler := handlers.NewAdminHandler(eventHandler, cfg.App.AdminToken, production)
+		router.HandleFunc("/admin/stats/reset", adminHandler.ResetStats).Methods("POST")
+		if production {
+			logger.Warn("APP_ADMIN_TOKEN is set in production, admin endpoints will reject requests")
+		}
+	}
+
 	// Системные маршруты
 	router.HandleFunc("/health", healthHandler.Health).Methods("GET")
 	router.HandleFunc("/ready", healthHandler.Ready).Methods("GET")
 
 	// Запускаем метрики сервер если включен
 	if cfg.Metrics.Enabled {
-		go startMetricsServer(cfg.Metrics, logger)
+		go startMetricsServer(cfg.Metrics, cfg.Debug, kafkaProducer, logger)
 	}
 
 	// Настраиваем HTTP сервер
@@ -108,8 +248,11 @@ func main() {
 	}()
 
 	// Graceful shutdown
+	// SIGHUP (перезагрузка) игнорируется, чтобы не завершать процесс по умолчанию
+	shutdownSignals, _ := config.ParseSignals(cfg.App.ShutdownSignals) // проверено в config.Load
+	signal.Ignore(config.ReloadSignal)
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(quit, shutdownSignals...)
 	<-quit
 
 	logger.Info("Shutting down server...")
@@ -162,13 +305,10 @@ func setupLogger() *logrus.Logger {
 }
 
 // startMetricsServer запускает отдельный сервер для метрик
-func startMetricsServer(cfg config.MetricsConfig, logger *logrus.Logger) {
-	mux := http.NewServeMux()
-	mux.Handle(cfg.Path, promhttp.Handler())
-
+func startMetricsServer(cfg config.MetricsConfig, debugCfg config.DebugConfig, producer *kafka.Producer, logger *logrus.Logger) {
 	srv := &http.Server{
 		Addr:         cfg.Port,
-		Handler:      mux,
+		Handler:      newMetricsMux(cfg, debugCfg, producer, logger),
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 		IdleTimeout:  cfg.IdleTimeout,
@@ -183,3 +323,46 @@ func startMetricsServer(cfg config.MetricsConfig, logger *logrus.Logger) {
 		logger.WithError(err).Error("Metrics server failed")
 	}
 }
+
+// newMetricsMux регистрирует метрики и отладочные эндпоинты; отладочные
+// доступны только с адресов PPROF_ALLOWED_IPS
+func newMetricsMux(cfg config.MetricsConfig, debugCfg config.DebugConfig, producer debugSnapshotter, logger *logrus.Logger) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, promhttp.Handler())
+
+	if debugCfg.PprofEnabled {
+		registerPprof(mux, middleware.AllowlistMiddleware(debugCfg.PprofAllowedIPs))
+		logger.WithField("allowed_ips", debugCfg.PprofAllowedIPs).Warn("pprof endpoints enabled")
+	}
+
+	if debugCfg.ProducerStateEnabled {
+		guard := middleware.AllowlistMiddleware(debugCfg.PprofAllowedIPs)
+		mux.Handle("/debug/producer", guard(producerStateHandler(producer)))
+		logger.WithField("allowed_ips", debugCfg.PprofAllowedIPs).Warn("Producer state endpoint enabled")
+	}
+
+	return mux
+}
+
+// debugSnapshotter источник снимка состояния для /debug/producer; реализуется *kafka.Producer
+type debugSnapshotter interface {
+	DebugSnapshot() kafka.BatchSnapshot
+}
+
+// producerStateHandler отдает снимок batch'а и очередей producer'а в JSON
+func producerStateHandler(producer debugSnapshotter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(producer.DebugSnapshot())
+	})
+}
+
+// registerPprof регистрирует обработчики net/http/pprof под /debug/pprof/
+func registerPprof(mux *http.ServeMux, guard func(http.Handler) http.Handler) {
+	mux.Handle("/debug/pprof/", guard(http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", guard(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", guard(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", guard(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", guard(http.HandlerFunc(pprof.Trace)))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"producer-service/internal/config"
+	"producer-service/internal/infrastructure/kafka"
+
+	"github.com/sirupsen/logrus"
+)
+
+// staticSnapshot отдает заранее заданный снимок состояния producer'а
+type staticSnapshot kafka.BatchSnapshot
+
+func (s staticSnapshot) DebugSnapshot() kafka.BatchSnapshot { return kafka.BatchSnapshot(s) }
+
+func TestDebugProducerEndpoint(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	snapshot := staticSnapshot{Async: true, CurrentBatchSize: 3, BatchThreshold: 100, EventQueueCap: 200, BatchQueueCap: 10}
+	tests := []struct {
+		name       string
+		enabled    bool
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "allowed address", enabled: true, remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "address outside allowlist", enabled: true, remoteAddr: "192.0.2.1:5000", wantStatus: http.StatusForbidden},
+		{name: "disabled", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debugCfg := config.DebugConfig{PprofAllowedIPs: []string{"127.0.0.1"}, ProducerStateEnabled: tt.enabled}
+			mux := newMetricsMux(config.MetricsConfig{Path: "/metrics"}, debugCfg, snapshot, logger)
+
+			req := httptest.NewRequest(http.MethodGet, "/debug/producer", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got kafka.BatchSnapshot
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode snapshot: %v", err)
+			}
+			if got != kafka.BatchSnapshot(snapshot) {
+				t.Fatalf("snapshot = %+v, want %+v", got, snapshot)
+			}
+		})
+	}
+}
+
+func TestMetricsMuxPprof(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		path       string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "index enabled", enabled: true, path: "/debug/pprof/", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "named profile enabled", enabled: true, path: "/debug/pprof/goroutine", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "cmdline enabled", enabled: true, path: "/debug/pprof/cmdline", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "address outside allowlist", enabled: true, path: "/debug/pprof/", remoteAddr: "192.0.2.1:5000", wantStatus: http.StatusForbidden},
+		{name: "index disabled", path: "/debug/pprof/", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusNotFound},
+		{name: "cmdline disabled", path: "/debug/pprof/cmdline", remoteAddr: "127.0.0.1:5000", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debugCfg := config.DebugConfig{PprofEnabled: tt.enabled, PprofAllowedIPs: []string{"127.0.0.1"}}
+			mux := newMetricsMux(config.MetricsConfig{Path: "/metrics"}, debugCfg, staticSnapshot{}, logger)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLoadNormalizesBrokers(t *testing.T) {
+	tests := []struct {
+		name    string
+		brokers string
+		want    []string
+		wantErr error
+	}{
+		{name: "single", brokers: "kafka:9092", want: []string{"kafka:9092"}},
+		{name: "spaces and empty entries", brokers: " kafka-1:9092, ,kafka-2:9092,", want: []string{"kafka-1:9092", "kafka-2:9092"}},
+		// Пустые элементы дают ошибку конфигурации, а не ошибку подключения
+		{name: "only separators", brokers: ",, ,", wantErr: errNoBrokers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KAFKA_BROKER_LIST", tt.brokers)
+
+			cfg, err := Load()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(cfg.Kafka.Brokers, tt.want) {
+				t.Fatalf("Kafka.Brokers = %q, want %q", cfg.Kafka.Brokers, tt.want)
+			}
+		})
+	}
+}
@@ -13,6 +13,9 @@ type Config struct {
 	Kafka   KafkaConfig
 	Logging LoggingConfig
 	Metrics MetricsConfig
+	Debug   DebugConfig
+	Event   EventConfig
+	Async   AsyncConfig
 	App     AppConfig
 }
 
@@ -24,6 +27,23 @@ type ServerConfig struct {
 	IdleTimeout     time.Duration `env:"SERVER_IDLE_TIMEOUT" env-default:"60s"`
 	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" env-default:"30s"`
 	MaxHeaderBytes  int           `env:"SERVER_MAX_HEADER_BYTES" env-default:"1048576"`
+	HealthVerbose   bool          `env:"HEALTH_VERBOSE" env-default:"false"`
+
+	// Общий бюджет всех проверок /ready; должен быть меньше таймаута probe.
+	// HEALTH_CHECK_TIMEOUT — прежнее имя, читается, если READINESS_TIMEOUT не задан.
+	HealthTimeout time.Duration `env:"READINESS_TIMEOUT,HEALTH_CHECK_TIMEOUT" env-default:"2s"`
+
+	// gzip сжатие ответов (для клиентов с Accept-Encoding: gzip)
+	CompressionEnabled bool `env:"SERVER_COMPRESSION_ENABLED" env-default:"false"`
+	CompressionMinSize int  `env:"SERVER_COMPRESSION_MIN_SIZE" env-default:"1024"`
+
+	// Дедупликация POST запросов по Idempotency-Key; емкость 0 отключает дедупликацию
+	IdempotencyTTL      time.Duration `env:"SERVER_IDEMPOTENCY_TTL" env-default:"24h"`
+	IdempotencyCapacity int           `env:"SERVER_IDEMPOTENCY_CAPACITY" env-default:"10000"`
+
+	// Предел одновременно обрабатываемых запросов API; сверх него 503 с Retry-After.
+	// 0 — без ограничения.
+	MaxInFlight int `env:"SERVER_MAX_IN_FLIGHT" env-default:"0"`
 }
 
 // KafkaConfig содержит конфигурацию Kafka
@@ -32,17 +52,53 @@ type KafkaConfig struct {
 	Topic           string        `env:"KAFKA_TOPIC" env-default:"events"`
 	ClientID        string        `env:"KAFKA_CLIENT_ID" env-default:"producer-service"`
 	BatchSize       int           `env:"KAFKA_BATCH_SIZE" env-default:"100"`
+	BatchMaxBytes   int           `env:"KAFKA_BATCH_MAX_BYTES" env-default:"1048576"`
 	BatchTimeout    time.Duration `env:"KAFKA_BATCH_TIMEOUT" env-default:"10ms"`
 	MaxRetries      int           `env:"KAFKA_MAX_RETRIES" env-default:"3"`
 	RetryBackoff    time.Duration `env:"KAFKA_RETRY_BACKOFF" env-default:"100ms"`
 	CompressionType string        `env:"KAFKA_COMPRESSION" env-default:"snappy"`
+	Balancer        string        `env:"KAFKA_BALANCER" env-default:"least-bytes"`
 	RequiredAcks    int           `env:"KAFKA_REQUIRED_ACKS" env-default:"1"`
+	UseEventTime    bool          `env:"KAFKA_USE_EVENT_TIME" env-default:"true"`
+
+	// Таймаут одной попытки записи независимо от контекста вызывающего; 0 — только контекст
+	WriteTimeout time.Duration `env:"KAFKA_WRITE_TIMEOUT" env-default:"10s"`
+
+	// false — синхронная запись без горутин батчинга (для сервисов с малым потоком)
+	AsyncBatch bool `env:"KAFKA_ASYNC_BATCH" env-default:"true"`
+
+	// Адаптивный размер batch'а
+	AdaptiveBatch      bool          `env:"KAFKA_ADAPTIVE_BATCH" env-default:"false"`
+	BatchMinSize       int           `env:"KAFKA_BATCH_MIN_SIZE" env-default:"10"`
+	BatchMaxSize       int           `env:"KAFKA_BATCH_MAX_SIZE" env-default:"1000"`
+	BatchTargetLatency time.Duration `env:"KAFKA_BATCH_TARGET_LATENCY" env-default:"50ms"`
+
+	// Fan-out: дополнительные топики, куда публикуется каждое событие помимо KAFKA_TOPIC
+	FanoutTopics []string `env:"KAFKA_FANOUT_TOPICS" env-default:""`
+
+	// Локальный спул событий, не записанных после всех повторов (edge-узлы без
+	// стабильной связи); пустой путь — спул отключен. Спул переигрывается в Kafka
+	// раз в KAFKA_SPOOL_REPLAY_INTERVAL, при заполнении новые события теряются.
+	SpoolPath           string        `env:"KAFKA_SPOOL_PATH" env-default:""`
+	SpoolMaxBytes       int64         `env:"KAFKA_SPOOL_MAX_BYTES" env-default:"104857600"`
+	SpoolReplayInterval time.Duration `env:"KAFKA_SPOOL_REPLAY_INTERVAL" env-default:"30s"`
+
+	// Пересоздание топика, удаленного во время работы; иначе запись падает без повторов
+	AutoCreateTopic        bool `env:"KAFKA_AUTO_CREATE_TOPIC" env-default:"false"`
+	TopicPartitions        int  `env:"KAFKA_TOPIC_PARTITIONS" env-default:"3"`
+	TopicReplicationFactor int  `env:"KAFKA_TOPIC_REPLICATION_FACTOR" env-default:"1"`
 }
 
 // LoggingConfig содержит конфигурацию логирования
 type LoggingConfig struct {
 	Level  string `env:"LOG_LEVEL" env-default:"info"`
 	Format string `env:"LOG_FORMAT" env-default:"json"`
+
+	// Поля JSON в data событий, значения которых скрываются в логах (например, "email,ssn")
+	RedactFields []string `env:"LOG_REDACT_FIELDS" env-default:""`
+
+	// Журнал аудита опубликованных событий: путь к файлу или "stdout"; пусто — отключен
+	AuditPath string `env:"AUDIT_LOG_PATH" env-default:""`
 }
 
 // MetricsConfig содержит конфигурацию метрик
@@ -54,6 +110,42 @@ type MetricsConfig struct {
 	WriteTimeout    time.Duration `env:"METRICS_WRITE_TIMEOUT" env-default:"15s"`
 	IdleTimeout     time.Duration `env:"METRICS_IDLE_TIMEOUT" env-default:"60s"`
 	ShutdownTimeout time.Duration `env:"METRICS_SHUTDOWN_TIMEOUT" env-default:"30s"`
+
+	// Summary латентности публикации в дополнение к гистограмме (точные квантили на экземпляре)
+	PublishSummary          bool      `env:"METRICS_PUBLISH_SUMMARY" env-default:"false"`
+	PublishSummaryQuantiles []float64 `env:"METRICS_PUBLISH_SUMMARY_QUANTILES" env-default:"0.5,0.9,0.99"`
+
+	// Период выгрузки kafka.WriterStats в метрики; 0 — выгрузка отключена
+	WriterStatsInterval time.Duration `env:"METRICS_WRITER_STATS_INTERVAL" env-default:"15s"`
+}
+
+// EventConfig содержит настройки формата событий
+type EventConfig struct {
+	JSONOmitEmpty bool `env:"EVENT_JSON_OMIT_EMPTY" env-default:"false"`
+	MaxDataBytes  int  `env:"EVENT_MAX_DATA_BYTES" env-default:"10000"`
+
+	// Сжатие data на уровне приложения (для брокеров без сжатия): "" или "gzip".
+	// Сжатый data передается в base64 с заголовком data-encoding.
+	DataCompression         string `env:"EVENT_DATA_COMPRESSION" env-default:""`
+	DataCompressionMinBytes int    `env:"EVENT_DATA_COMPRESSION_MIN_BYTES" env-default:"1024"`
+}
+
+// AsyncConfig содержит настройки асинхронной публикации (обогащение вне HTTP обработчика)
+type AsyncConfig struct {
+	Enabled        bool `env:"ASYNC_ENABLED" env-default:"false"`
+	Workers        int  `env:"ASYNC_WORKERS" env-default:"4"`
+	QueueSize      int  `env:"ASYNC_QUEUE_SIZE" env-default:"1000"`
+	StatusCapacity int  `env:"ASYNC_STATUS_CAPACITY" env-default:"10000"`
+}
+
+// DebugConfig содержит настройки отладочных эндпоинтов
+type DebugConfig struct {
+	PprofEnabled    bool     `env:"PPROF_ENABLED" env-default:"false"`
+	PprofAllowedIPs []string `env:"PPROF_ALLOWED_IPS" env-default:"127.0.0.1,::1"`
+
+	// GET /debug/producer на сервере метрик: снимок batch'а и очередей producer'а.
+	// Доступ ограничен тем же списком PPROF_ALLOWED_IPS.
+	ProducerStateEnabled bool `env:"DEBUG_PRODUCER_STATE_ENABLED" env-default:"false"`
 }
 
 // AppConfig содержит общие настройки приложения
@@ -62,8 +154,17 @@ type AppConfig struct {
 	Version     string `env:"APP_VERSION" env-default:"1.0.0"`
 	Environment string `env:"APP_ENV" env-default:"development"`
 	Debug       bool   `env:"APP_DEBUG" env-default:"false"`
+
+	// Сигналы graceful остановки; SIGHUP зарезервирован под перезагрузку конфигурации
+	ShutdownSignals []string `env:"APP_SHUTDOWN_SIGNALS" env-default:"SIGINT,SIGTERM"`
+
+	// Bearer токен административных маршрутов; пустой — маршруты отключены
+	AdminToken string `env:"APP_ADMIN_TOKEN" env-default:""`
 }
 
+// EnvironmentProduction значение APP_ENV для production окружения
+const EnvironmentProduction = "production"
+
 // Load загружает конфигурацию из переменных окружения
 func Load() (*Config, error) {
 	var config Config
@@ -72,5 +173,63 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read environment: %w", err)
 	}
 
+	brokers, err := normalizeBrokers(config.Kafka.Brokers)
+	if err != nil {
+		return nil, err
+	}
+	config.Kafka.Brokers = brokers
+
+	for _, q := range config.Metrics.PublishSummaryQuantiles {
+		if q <= 0 || q >= 1 {
+			return nil, fmt.Errorf("METRICS_PUBLISH_SUMMARY_QUANTILES must be in (0, 1), got %v", q)
+		}
+	}
+
+	if _, err := ParseSignals(config.App.ShutdownSignals); err != nil {
+		return nil, fmt.Errorf("invalid APP_SHUTDOWN_SIGNALS: %w", err)
+	}
+
+	// Событие максимального размера должно помещаться в batch Kafka
+	// с запасом на экранирование JSON, metadata и заголовки
+	if config.Event.MaxDataBytes <= 0 {
+		return nil, fmt.Errorf("EVENT_MAX_DATA_BYTES must be positive, got %d", config.Event.MaxDataBytes)
+	}
+	if config.Kafka.BatchMaxBytes < 2*config.Event.MaxDataBytes {
+		return nil, fmt.Errorf("KAFKA_BATCH_MAX_BYTES (%d) must be at least twice EVENT_MAX_DATA_BYTES (%d)",
+			config.Kafka.BatchMaxBytes, config.Event.MaxDataBytes)
+	}
+
+	switch config.Event.DataCompression {
+	case "", "gzip":
+	default:
+		return nil, fmt.Errorf("invalid EVENT_DATA_COMPRESSION: %q", config.Event.DataCompression)
+	}
+
+	seenTopics := map[string]struct{}{config.Kafka.Topic: {}}
+	for _, topic := range config.Kafka.FanoutTopics {
+		if _, dup := seenTopics[topic]; dup || topic == "" {
+			return nil, fmt.Errorf("invalid KAFKA_FANOUT_TOPICS: empty or duplicate topic %q", topic)
+		}
+		seenTopics[topic] = struct{}{}
+	}
+
+	if config.Kafka.SpoolPath != "" && (config.Kafka.SpoolMaxBytes <= 0 || config.Kafka.SpoolReplayInterval <= 0) {
+		return nil, fmt.Errorf("KAFKA_SPOOL_MAX_BYTES and KAFKA_SPOOL_REPLAY_INTERVAL must be positive, got %d and %s",
+			config.Kafka.SpoolMaxBytes, config.Kafka.SpoolReplayInterval)
+	}
+
+	if config.Kafka.WriteTimeout < 0 {
+		return nil, fmt.Errorf("KAFKA_WRITE_TIMEOUT must not be negative, got %s", config.Kafka.WriteTimeout)
+	}
+
+	if config.Server.MaxInFlight < 0 {
+		return nil, fmt.Errorf("SERVER_MAX_IN_FLIGHT must not be negative, got %d", config.Server.MaxInFlight)
+	}
+
+	if config.Kafka.AutoCreateTopic && (config.Kafka.TopicPartitions <= 0 || config.Kafka.TopicReplicationFactor <= 0) {
+		return nil, fmt.Errorf("KAFKA_TOPIC_PARTITIONS and KAFKA_TOPIC_REPLICATION_FACTOR must be positive, got %d and %d",
+			config.Kafka.TopicPartitions, config.Kafka.TopicReplicationFactor)
+	}
+
 	return &config, nil
 }
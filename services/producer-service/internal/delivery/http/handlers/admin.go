@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminHandler обрабатывает административные запросы тестовых окружений
+type AdminHandler struct {
+	*EventHandler
+	token      string
+	production bool
+}
+
+// NewAdminHandler создает новый AdminHandler. Запросы принимаются только
+// с заголовком Authorization: Bearer <token> и вне production окружения.
+func NewAdminHandler(eventHandler *EventHandler, token string, production bool) *AdminHandler {
+	return &AdminHandler{
+		EventHandler: eventHandler,
+		token:        token,
+		production:   production,
+	}
+}
+
+// ResetStats обнуляет накопленную статистику событий
+func (h *AdminHandler) ResetStats(w http.ResponseWriter, r *http.Request) {
+	endpoint := "/admin/stats/reset"
+
+	if h.production {
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "403")
+		h.writeErrorResponse(w, r, "Admin endpoints are disabled in production", http.StatusForbidden, "FORBIDDEN")
+		return
+	}
+
+	if !h.authorized(r) {
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "401")
+		h.writeErrorResponse(w, r, "Invalid or missing admin token", http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+
+	if err := h.eventService.ResetEventStats(r.Context()); err != nil {
+		h.logger.WithError(err).Error("Failed to reset event stats")
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "500")
+		h.writeErrorResponse(w, r, "Failed to reset event stats", http.StatusInternalServerError, "INTERNAL_ERROR")
+		return
+	}
+
+	h.logger.WithField("remote_addr", r.RemoteAddr).Warn("Event stats reset")
+	h.metrics.IncHTTPRequests(r.Method, endpoint, "200")
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":    "success",
+		"message":   "Event stats reset",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// authorized сравнивает bearer токен за постоянное время
+func (h *AdminHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"producer-service/internal/domain"
+	"producer-service/internal/usecase"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAdminResetStats(t *testing.T) {
+	const token = "s3cret"
+
+	tests := []struct {
+		name          string
+		production    bool
+		authorization string
+		wantStatus    int
+		wantReset     bool
+	}{
+		{name: "reset", authorization: "Bearer " + token, wantStatus: http.StatusOK, wantReset: true},
+		{name: "rejected in production", production: true, authorization: "Bearer " + token, wantStatus: http.StatusForbidden},
+		{name: "missing token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, _ := logtest.NewNullLogger()
+			service := usecase.NewEventService(newGatedPublisher(false), nil, logger)
+			for i := 0; i < 3; i++ {
+				if _, err := service.CreateUserEvent(context.Background(), `{"id":1}`); err != nil {
+					t.Fatalf("CreateUserEvent: %v", err)
+				}
+			}
+			handler := NewAdminHandler(NewEventHandler(service, logger, nopHTTPMetrics{}, domain.NewRedactor(nil)), token, tt.production)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/stats/reset", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ResetStats(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			stats, err := service.GetEventStats(context.Background())
+			if err != nil {
+				t.Fatalf("GetEventStats: %v", err)
+			}
+			wantTotal := int64(3)
+			if tt.wantReset {
+				wantTotal = 0
+			}
+			if stats.TotalEvents != wantTotal || stats.EventsByType[string(domain.UserCreatedEvent)] != wantTotal {
+				t.Fatalf("stats after reset request = %+v, want %d total events", stats, wantTotal)
+			}
+			if tt.wantReset && stats.LastEventTime != nil {
+				t.Fatalf("last event time = %v after reset, want nil", *stats.LastEventTime)
+			}
+		})
+	}
+}
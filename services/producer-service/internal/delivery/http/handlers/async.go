@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// AcceptedResponse представляет ответ на принятое к асинхронной публикации событие
+type AcceptedResponse struct {
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	EventID   string    `json:"event_id"`
+	StatusURL string    `json:"status_url"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AsyncEventHandler принимает события в очередь асинхронной публикации
+type AsyncEventHandler struct {
+	*EventHandler
+	asyncService domain.AsyncEventService
+}
+
+// NewAsyncEventHandler создает новый AsyncEventHandler
+func NewAsyncEventHandler(asyncService domain.AsyncEventService, eventHandler *EventHandler) *AsyncEventHandler {
+	return &AsyncEventHandler{
+		EventHandler: eventHandler,
+		asyncService: asyncService,
+	}
+}
+
+// CreateUserEvent ставит событие пользователя в очередь и отвечает 202 Accepted
+func (h *AsyncEventHandler) CreateUserEvent(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	endpoint := "/events/user"
+
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.ObserveHTTPDuration(r.Method, endpoint, duration)
+	}()
+
+	req, err := h.parseAndValidateRequest(r)
+	if err != nil {
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "400")
+		h.writeValidationErrorResponse(w, r, err)
+		return
+	}
+
+	// Если данные не переданы, используем дефолтные
+	if req.Data == "" {
+		req.Data = `{"message": "New user has been created"}`
+	}
+
+	event, err := h.asyncService.SubmitUserEvent(r.Context(), req.Data)
+	if errors.Is(err, domain.ErrQueueFull) {
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "503")
+		w.Header().Set("Retry-After", "1")
+		h.writeErrorResponse(w, r, "Event queue is full, retry later", http.StatusServiceUnavailable, "QUEUE_FULL")
+		return
+	}
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"endpoint": endpoint,
+			"error":    err,
+		}).Error("Failed to enqueue user event")
+
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "500")
+		h.writeErrorResponse(w, r, "Failed to enqueue user event", http.StatusInternalServerError, "INTERNAL_ERROR")
+		return
+	}
+
+	h.metrics.IncHTTPRequests(r.Method, endpoint, "202")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	response := AcceptedResponse{
+		Status:    "accepted",
+		Message:   "User created event queued for publishing",
+		EventID:   event.ID,
+		StatusURL: "/api/v1/events/" + event.ID + "/status",
+		Timestamp: time.Now().UTC(),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Error("Failed to encode accepted response")
+	}
+}
+
+// GetEventStatus возвращает статус асинхронной публикации события
+func (h *AsyncEventHandler) GetEventStatus(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	endpoint := "/events/{id}/status"
+
+	defer func() {
+		duration := time.Since(start).Seconds()
+		h.metrics.ObserveHTTPDuration(r.Method, endpoint, duration)
+	}()
+
+	status, ok := h.asyncService.Status(mux.Vars(r)["id"])
+	if !ok {
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "404")
+		h.writeErrorResponse(w, r, "Event status not found", http.StatusNotFound, "NOT_FOUND")
+		return
+	}
+
+	h.metrics.IncHTTPRequests(r.Method, endpoint, "200")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		h.logger.WithError(err).Error("Failed to encode status response")
+	}
+}
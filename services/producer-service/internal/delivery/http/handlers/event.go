@@ -2,20 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
+	"producer-service/internal/delivery/http/httpx"
 	"producer-service/internal/domain"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 )
 
+// requestValidator общий валидатор запросов (потокобезопасен)
+var requestValidator = newRequestValidator()
+
+// newRequestValidator создает валидатор с правилом event_data_max,
+// проверяющим данные по настраиваемому лимиту domain.MaxDataLength
+func newRequestValidator() *validator.Validate {
+	v := validator.New()
+	_ = v.RegisterValidation("event_data_max", func(fl validator.FieldLevel) bool {
+		return len(fl.Field().String()) <= domain.MaxDataLength()
+	})
+	return v
+}
+
 // EventRequest представляет запрос на создание события
 type EventRequest struct {
-	Data     string                 `json:"data" validate:"required,min=1,max=10000"`
+	Data string `json:"data" validate:"required,min=1,event_data_max"`
+	// Метаданные запроса пока не переносятся в событие. Зарезервированные
+	// ключи отклоняются уже сейчас, чтобы их пересылка не открыла подмену заголовков
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -27,13 +45,11 @@ type EventResponse struct {
 	Timestamp time.Time     `json:"timestamp"`
 }
 
-// ErrorResponse представляет ответ с ошибкой
-type ErrorResponse struct {
-	Error     string    `json:"error"`
-	Message   string    `json:"message"`
-	Code      string    `json:"code,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
-}
+// ErrorResponse представляет ответ с ошибкой (общий формат httpx)
+type ErrorResponse = httpx.ErrorResponse
+
+// FieldError описывает нарушение валидации отдельного поля
+type FieldError = httpx.FieldError
 
 // StatsResponse представляет ответ со статистикой
 type StatsResponse struct {
@@ -43,8 +59,7 @@ type StatsResponse struct {
 
 // Validate проверяет валидность запроса
 func (r *EventRequest) Validate() error {
-	validate := validator.New()
-	if err := validate.Struct(r); err != nil {
+	if err := requestValidator.Struct(r); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
@@ -53,14 +68,34 @@ func (r *EventRequest) Validate() error {
 		return domain.ErrInvalidEventData
 	}
 
+	if key, ok := reservedMetadataKey(r.Metadata); ok {
+		return fmt.Errorf("%w: %s", domain.ErrReservedMetadataKey, key)
+	}
+
 	return nil
 }
 
+// reservedMetadataKey возвращает первый по алфавиту зарезервированный ключ метаданных запроса
+func reservedMetadataKey(metadata map[string]interface{}) (string, bool) {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if domain.IsReservedMetadataKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return "", false
+	}
+	sort.Strings(keys)
+	return keys[0], true
+}
+
 // EventHandler обрабатывает HTTP запросы для событий
 type EventHandler struct {
 	eventService domain.EventService
 	logger       *logrus.Logger
 	metrics      HTTPMetrics
+	redactor     *domain.Redactor
 }
 
 // HTTPMetrics интерфейс для HTTP метрик
@@ -69,12 +104,14 @@ type HTTPMetrics interface {
 	ObserveHTTPDuration(method, endpoint string, duration float64)
 }
 
-// NewEventHandler создает новый EventHandler
-func NewEventHandler(eventService domain.EventService, logger *logrus.Logger, metrics HTTPMetrics) *EventHandler {
+// NewEventHandler создает новый EventHandler. redactor скрывает поля data
+// в логах; nil — data логируется как есть.
+func NewEventHandler(eventService domain.EventService, logger *logrus.Logger, metrics HTTPMetrics, redactor *domain.Redactor) *EventHandler {
 	return &EventHandler{
 		eventService: eventService,
 		logger:       logger,
 		metrics:      metrics,
+		redactor:     redactor,
 	}
 }
 
@@ -91,7 +128,7 @@ func (h *EventHandler) CreateUserEvent(w http.ResponseWriter, r *http.Request) {
 	req, err := h.parseAndValidateRequest(r)
 	if err != nil {
 		h.metrics.IncHTTPRequests(r.Method, endpoint, "400")
-		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest, "VALIDATION_ERROR")
+		h.writeValidationErrorResponse(w, r, err)
 		return
 	}
 
@@ -101,15 +138,25 @@ func (h *EventHandler) CreateUserEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	event, err := h.eventService.CreateUserEvent(r.Context(), req.Data)
+	if errors.Is(err, domain.ErrTopicNotFound) {
+		h.logger.WithFields(logrus.Fields{
+			"endpoint": endpoint,
+			"error":    err,
+		}).Error("Kafka topic not found")
+
+		h.metrics.IncHTTPRequests(r.Method, endpoint, "503")
+		h.writeErrorResponse(w, r, "Kafka topic not found", http.StatusServiceUnavailable, "TOPIC_NOT_FOUND")
+		return
+	}
 	if err != nil {
 		h.logger.WithFields(logrus.Fields{
 			"endpoint": endpoint,
 			"error":    err,
-			"data":     req.Data,
+			"data":     h.redactor.Redact(req.Data),
 		}).Error("Failed to create user event")
 
 		h.metrics.IncHTTPRequests(r.Method, endpoint, "500")
-		h.writeErrorResponse(w, "Failed to create user event", http.StatusInternalServerError, "INTERNAL_ERROR")
+		h.writeErrorResponse(w, r, "Failed to create user event", http.StatusInternalServerError, "INTERNAL_ERROR")
 		return
 	}
 
@@ -141,7 +188,7 @@ func (h *EventHandler) GetEventStats(w http.ResponseWriter, r *http.Request) {
 		}).Error("Failed to get event stats")
 
 		h.metrics.IncHTTPRequests(r.Method, endpoint, "500")
-		h.writeErrorResponse(w, "Failed to get event stats", http.StatusInternalServerError, "INTERNAL_ERROR")
+		h.writeErrorResponse(w, r, "Failed to get event stats", http.StatusInternalServerError, "INTERNAL_ERROR")
 		return
 	}
 
@@ -200,19 +247,59 @@ func (h *EventHandler) writeStatsResponse(w http.ResponseWriter, stats *domain.E
 	}
 }
 
-// writeErrorResponse записывает ответ с ошибкой
-func (h *EventHandler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int, code string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// writeValidationErrorResponse записывает ответ 400 с перечнем нарушений по полям
+func (h *EventHandler) writeValidationErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		h.writeErrorResponse(w, r, err.Error(), http.StatusBadRequest, "VALIDATION_ERROR")
+		return
+	}
 
-	response := ErrorResponse{
-		Error:     http.StatusText(statusCode),
-		Message:   message,
-		Code:      code,
-		Timestamp: time.Now().UTC(),
+	details := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		details = append(details, FieldError{
+			Field:   fe.Field(),
+			Tag:     fieldErrorTag(fe),
+			Message: fieldErrorMessage(fe),
+		})
 	}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	h.writeErrorResponseWithDetails(w, r, "Request validation failed", http.StatusBadRequest, "VALIDATION_ERROR", details)
+}
+
+// fieldErrorTag возвращает имя правила для клиента: настраиваемый лимит
+// данных сообщается как обычное правило max
+func fieldErrorTag(fe validator.FieldError) string {
+	if fe.Tag() == "event_data_max" {
+		return "max"
+	}
+	return fe.Tag()
+}
+
+// fieldErrorMessage возвращает понятное описание нарушения правила валидации
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters long", fe.Field(), fe.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters long", fe.Field(), fe.Param())
+	case "event_data_max":
+		return fmt.Sprintf("%s must be at most %d bytes long", fe.Field(), domain.MaxDataLength())
+	default:
+		return fmt.Sprintf("%s failed on the '%s' rule", fe.Field(), fe.Tag())
+	}
+}
+
+// writeErrorResponse записывает ответ с ошибкой
+func (h *EventHandler) writeErrorResponse(w http.ResponseWriter, r *http.Request, message string, statusCode int, code string) {
+	h.writeErrorResponseWithDetails(w, r, message, statusCode, code, nil)
+}
+
+// writeErrorResponseWithDetails записывает ответ с ошибкой и деталями по полям
+func (h *EventHandler) writeErrorResponseWithDetails(w http.ResponseWriter, r *http.Request, message string, statusCode int, code string, details []FieldError) {
+	if err := httpx.WriteError(w, r, statusCode, code, message, details...); err != nil {
 		h.logger.WithError(err).Error("Failed to encode error response")
 	}
 }
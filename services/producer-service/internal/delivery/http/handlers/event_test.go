@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"producer-service/internal/domain"
+	"producer-service/internal/usecase"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestReservedMetadataKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		wantKey  string
+		wantOK   bool
+	}{
+		{name: "no metadata", metadata: nil},
+		{name: "user keys", metadata: map[string]interface{}{"source_system": "crm", "priority": 1}},
+		{name: "header name", metadata: map[string]interface{}{"event-type": "user_deleted"}, wantKey: "event-type", wantOK: true},
+		{name: "metadata key", metadata: map[string]interface{}{"correlation_id": "abc"}, wantKey: "correlation_id", wantOK: true},
+		{name: "case and separator ignored", metadata: map[string]interface{}{" Tenant_ID ": "acme"}, wantKey: " Tenant_ID ", wantOK: true},
+		{
+			name:     "first reserved key in order",
+			metadata: map[string]interface{}{"tenant": "acme", "partition": "1", "note": "x"},
+			wantKey:  "partition",
+			wantOK:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := reservedMetadataKey(tt.metadata)
+			if key != tt.wantKey || ok != tt.wantOK {
+				t.Fatalf("reservedMetadataKey() = (%q, %v), want (%q, %v)", key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEventRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		request EventRequest
+		wantErr error
+	}{
+		{name: "valid", request: EventRequest{Data: `{"id":1}`}},
+		{name: "valid with metadata", request: EventRequest{Data: `{"id":1}`, Metadata: map[string]interface{}{"source_system": "crm"}}},
+		{name: "blank data", request: EventRequest{Data: "   "}, wantErr: domain.ErrInvalidEventData},
+		{
+			name:    "spoofed event type",
+			request: EventRequest{Data: `{"id":1}`, Metadata: map[string]interface{}{"event-type": "user_deleted"}},
+			wantErr: domain.ErrReservedMetadataKey,
+		},
+		{
+			name:    "spoofed partition",
+			request: EventRequest{Data: `{"id":1}`, Metadata: map[string]interface{}{"PARTITION": "0"}},
+			wantErr: domain.ErrReservedMetadataKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateUserEventValidationDetails(t *testing.T) {
+	logger, _ := logtest.NewNullLogger()
+	handler := NewEventHandler(failingEventService{}, logger, nopHTTPMetrics{}, domain.NewRedactor(nil))
+
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{
+			name: "over-length data",
+			body: `{"data":"` + strings.Repeat("x", domain.MaxDataLength()+1) + `"}`,
+			want: []FieldError{{Field: "Data", Tag: "max", Message: fmt.Sprintf("Data must be at most %d bytes long", domain.MaxDataLength())}},
+		},
+		{
+			name: "missing data",
+			body: `{}`,
+			want: []FieldError{{Field: "Data", Tag: "required", Message: "Data is required"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.CreateUserEvent(rec, httptest.NewRequest(http.MethodPost, "/events/user", strings.NewReader(tt.body)))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+
+			var resp ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode error response: %v", err)
+			}
+			if resp.Code != "VALIDATION_ERROR" || !reflect.DeepEqual(resp.Details, tt.want) {
+				t.Fatalf("code = %s, details = %+v, want VALIDATION_ERROR with %+v", resp.Code, resp.Details, tt.want)
+			}
+		})
+	}
+}
+
+// failingEventService отклоняет создание событий
+type failingEventService struct {
+	domain.EventService
+}
+
+func (failingEventService) CreateUserEvent(context.Context, string) (*domain.Event, error) {
+	return nil, errors.New("kafka unavailable")
+}
+
+// nopHTTPMetrics не собирает HTTP метрики
+type nopHTTPMetrics struct{}
+
+func (nopHTTPMetrics) IncHTTPRequests(string, string, string)      {}
+func (nopHTTPMetrics) ObserveHTTPDuration(string, string, float64) {}
+
+func TestCreateUserEventRedactsLoggedData(t *testing.T) {
+	const data = `{"email":"ann@example.com","ssn":"123-45-6789","plan":"pro"}`
+
+	tests := []struct {
+		name     string
+		fields   []string
+		wantData string
+	}{
+		{name: "configured fields masked", fields: []string{"email", "ssn"}, wantData: `{"email":"[REDACTED]","plan":"pro","ssn":"[REDACTED]"}`},
+		{name: "no fields configured", wantData: data},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			handler := NewEventHandler(failingEventService{}, logger, nopHTTPMetrics{}, domain.NewRedactor(tt.fields))
+
+			body, _ := json.Marshal(EventRequest{Data: data})
+			rec := httptest.NewRecorder()
+			handler.CreateUserEvent(rec, httptest.NewRequest(http.MethodPost, "/events/user", bytes.NewReader(body)))
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500", rec.Code)
+			}
+
+			entry := hook.LastEntry()
+			if entry == nil || entry.Level != logrus.ErrorLevel {
+				t.Fatalf("last log entry = %v, want the error entry", entry)
+			}
+			if got := entry.Data["data"]; got != tt.wantData {
+				t.Fatalf("logged data = %v, want %s", got, tt.wantData)
+			}
+		})
+	}
+}
+
+// gatedPublisher сообщает о начале каждой публикации в started
+// и завершает ее только после закрытия release
+type gatedPublisher struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu        sync.Mutex
+	published []string
+}
+
+func newGatedPublisher(blocked bool) *gatedPublisher {
+	p := &gatedPublisher{started: make(chan struct{}, 16), release: make(chan struct{})}
+	if !blocked {
+		close(p.release)
+	}
+	return p
+}
+
+func (p *gatedPublisher) Publish(_ context.Context, event *domain.Event) error {
+	p.started <- struct{}{}
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func (p *gatedPublisher) Close() error { return nil }
+
+func (p *gatedPublisher) publishedIDs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+func TestAsyncCreateUserEvent(t *testing.T) {
+	logger, _ := logtest.NewNullLogger()
+
+	post := func(handler *AsyncEventHandler) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.CreateUserEvent(rec, httptest.NewRequest(http.MethodPost, "/events/user", strings.NewReader(`{"data":"{\"id\":1}"}`)))
+		return rec
+	}
+
+	tests := []struct {
+		name string
+		// blocked задерживает публикацию, пока тест не заполнит очередь
+		blocked    bool
+		requests   int
+		wantStatus []int
+	}{
+		{name: "accepted and published", requests: 2, wantStatus: []int{http.StatusAccepted, http.StatusAccepted}},
+		{
+			// Первое событие публикуется worker'ом, второе ждет в очереди, третье отклоняется
+			name: "queue full", blocked: true, requests: 3,
+			wantStatus: []int{http.StatusAccepted, http.StatusAccepted, http.StatusServiceUnavailable},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := newGatedPublisher(tt.blocked)
+			service := usecase.NewEventService(publisher, nil, logger)
+			async := usecase.NewAsyncEventService(service, 1, 1, 16, logger)
+			async.Start()
+			handler := NewAsyncEventHandler(async, NewEventHandler(service, logger, nopHTTPMetrics{}, domain.NewRedactor(nil)))
+
+			var accepted []string
+			for i := 0; i < tt.requests; i++ {
+				rec := post(handler)
+				if rec.Code != tt.wantStatus[i] {
+					t.Fatalf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus[i])
+				}
+				if rec.Code == http.StatusServiceUnavailable {
+					if rec.Header().Get("Retry-After") == "" {
+						t.Fatalf("request %d: 503 without Retry-After", i)
+					}
+					continue
+				}
+
+				var resp AcceptedResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode accepted response: %v", err)
+				}
+				if resp.EventID == "" || resp.StatusURL != "/api/v1/events/"+resp.EventID+"/status" {
+					t.Fatalf("accepted response = %+v, want event ID and its status URL", resp)
+				}
+				accepted = append(accepted, resp.EventID)
+
+				// Дожидаемся, пока worker возьмет первое событие: следующее встанет в очередь
+				if i == 0 {
+					<-publisher.started
+				}
+			}
+			for _, id := range accepted {
+				if status, ok := async.Status(id); !ok || (tt.blocked && status.Status != domain.StatusPending) {
+					t.Fatalf("status of %s = %+v, want pending while publishing is blocked", id, status)
+				}
+			}
+
+			if tt.blocked {
+				close(publisher.release)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for _, id := range accepted {
+				for {
+					status, _ := async.Status(id)
+					if status.Status == domain.StatusPublished {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatalf("event %s status = %s, want published", id, status.Status)
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			async.Close()
+			if got := publisher.publishedIDs(); !reflect.DeepEqual(got, accepted) {
+				t.Fatalf("published = %v, want accepted events %v", got, accepted)
+			}
+		})
+	}
+}
@@ -1,47 +1,123 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"runtime"
+	"runtime/debug"
 	"time"
+
+	"producer-service/internal/domain"
 )
 
+// HealthResponse представляет ответ проверки здоровья
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Service   string `json:"service"`
+	Version   string `json:"version"`
+
+	// Расширенные сведения (HEALTH_VERBOSE=true)
+	Uptime    string `json:"uptime,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+}
+
+// ReadyResponse представляет ответ проверки готовности
+type ReadyResponse struct {
+	Status    string                            `json:"status"`
+	Timestamp string                            `json:"timestamp"`
+	Service   string                            `json:"service"`
+	Detail    string                            `json:"detail,omitempty"`
+	Checks    map[string]domain.ComponentHealth `json:"checks"`
+}
+
+// ReadinessChecker выполняет проверки компонентов для /ready
+type ReadinessChecker interface {
+	Check(ctx context.Context) domain.HealthReport
+}
+
 // HealthHandler обрабатывает запросы проверки здоровья
-type HealthHandler struct{}
+type HealthHandler struct {
+	service   string
+	version   string
+	verbose   bool
+	readiness ReadinessChecker
+	startedAt time.Time
+}
 
 // NewHealthHandler создает новый HealthHandler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(service, version string, verbose bool, readiness ReadinessChecker) *HealthHandler {
+	return &HealthHandler{
+		service:   service,
+		version:   version,
+		verbose:   verbose,
+		readiness: readiness,
+		startedAt: time.Now(),
+	}
 }
 
 // Health возвращает статус здоровья приложения
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	response := HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Service:   h.service,
+		Version:   h.version,
+	}
 
-	response := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"service":   "producer-service",
-		"version":   "1.0.0",
+	if h.verbose {
+		response.Uptime = time.Since(h.startedAt).Round(time.Second).String()
+		response.Commit = buildCommit()
+		response.GoVersion = runtime.Version()
 	}
 
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
-// Ready возвращает статус готовности приложения
+// Ready возвращает статус готовности приложения: 503, если не прошла
+// хотя бы одна критическая проверка компонентов
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.readiness.Check(r.Context())
+
+	response := ReadyResponse{
+		Status:    "ready",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Service:   h.service,
+		Checks:    report.Components,
+	}
+
+	status := http.StatusOK
+	if !report.Ready {
+		response.Status = "not_ready"
+		status = http.StatusServiceUnavailable
+	}
+	if report.TimedOut {
+		response.Detail = "timeout"
+	}
+
+	writeJSON(w, status, response)
+}
+
+// writeJSON записывает ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
 
-	response := map[string]interface{}{
-		"status":    "ready",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"service":   "producer-service",
-		"checks": map[string]string{
-			"kafka": "ok",
-		},
+// buildCommit возвращает ревизию VCS из информации о сборке
+func buildCommit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
 	}
 
-	json.NewEncoder(w).Encode(response)
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return "unknown"
 }
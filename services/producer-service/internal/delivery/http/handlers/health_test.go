@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"producer-service/internal/domain"
+	"producer-service/internal/usecase"
+)
+
+// checkFunc адаптирует функцию к domain.HealthChecker
+type checkFunc func(ctx context.Context) error
+
+func (f checkFunc) Check(ctx context.Context) error { return f(ctx) }
+
+func TestReadyAggregatesComponentChecks(t *testing.T) {
+	ok := checkFunc(func(context.Context) error { return nil })
+	failing := checkFunc(func(context.Context) error { return errors.New("broker unreachable") })
+	hanging := checkFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	type check struct {
+		name     string
+		checker  domain.HealthChecker
+		critical bool
+	}
+	tests := []struct {
+		name       string
+		checks     []check
+		wantStatus int
+		wantDetail string
+		// Ожидаемые статусы компонентов в ответе
+		wantChecks map[string]string
+	}{
+		{
+			name:       "all checks pass",
+			checks:     []check{{"kafka", ok, true}, {"async_queue", ok, false}},
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"kafka": domain.HealthStatusOK, "async_queue": domain.HealthStatusOK},
+		},
+		{
+			name:       "critical check fails",
+			checks:     []check{{"kafka", failing, true}, {"async_queue", ok, false}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"kafka": domain.HealthStatusFail, "async_queue": domain.HealthStatusOK},
+		},
+		{
+			name:       "advisory check fails",
+			checks:     []check{{"kafka", ok, true}, {"async_queue", failing, false}},
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"kafka": domain.HealthStatusOK, "async_queue": domain.HealthStatusFail},
+		},
+		{
+			name:       "critical check times out",
+			checks:     []check{{"kafka", hanging, true}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantDetail: "timeout",
+			wantChecks: map[string]string{"kafka": domain.HealthStatusTimeout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := usecase.NewHealthRegistry(50 * time.Millisecond)
+			for _, c := range tt.checks {
+				registry.Register(c.name, c.checker, c.critical)
+			}
+			handler := NewHealthHandler("producer-service", "test", false, registry)
+
+			rec := httptest.NewRecorder()
+			handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var resp ReadyResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode ready response: %v", err)
+			}
+			if resp.Detail != tt.wantDetail {
+				t.Fatalf("detail = %q, want %q", resp.Detail, tt.wantDetail)
+			}
+			if len(resp.Checks) != len(tt.wantChecks) {
+				t.Fatalf("checks = %+v, want %v", resp.Checks, tt.wantChecks)
+			}
+			for name, want := range tt.wantChecks {
+				if got := resp.Checks[name]; got.Status != want {
+					t.Fatalf("check %s = %+v, want status %s", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// CompressionMiddleware сжимает ответы gzip, если клиент прислал Accept-Encoding: gzip.
+// Ответы короче minSize байт отдаются как есть; пути из skipPaths (например,
+// /metrics, где сжатием управляет сам Prometheus handler) не обрабатываются.
+func CompressionMiddleware(minSize int, skipPaths ...string) func(http.Handler) http.Handler {
+	skip := make(map[string]struct{}, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := skip[r.URL.Path]; ok || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// finish не откладываем через defer: при панике RecoveryMiddleware
+			// должен писать ответ сам, без буферизованных данных обработчика
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, statusCode: http.StatusOK}
+			next.ServeHTTP(gw, r)
+			gw.finish()
+		})
+	}
+}
+
+// acceptsGzip проверяет, поддерживает ли клиент gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(encoding, "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter буферизует начало ответа и включает сжатие,
+// только когда тело превышает minSize. Статус код передается дальше
+// по цепочке, поэтому обертки логирования и метрик видят его как обычно.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	statusCode  int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	g.statusCode = code
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	if g.passthrough {
+		return g.ResponseWriter.Write(p)
+	}
+
+	g.buf = append(g.buf, p...)
+	if len(g.buf) < g.minSize {
+		return len(p), nil
+	}
+
+	if err := g.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start фиксирует заголовки и выбирает режим: сжатие или отдача как есть
+func (g *gzipResponseWriter) start() error {
+	header := g.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || !bodyAllowed(g.statusCode) {
+		g.passthrough = true
+	} else {
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(g.statusCode)
+
+	buf := g.buf
+	g.buf = nil
+	if g.gz != nil {
+		_, err := g.gz.Write(buf)
+		return err
+	}
+	_, err := g.ResponseWriter.Write(buf)
+	return err
+}
+
+// finish дописывает ответ: закрывает gzip поток или отдает короткий ответ без сжатия
+func (g *gzipResponseWriter) finish() {
+	if g.gz != nil {
+		g.gz.Close()
+		return
+	}
+	if g.passthrough {
+		return
+	}
+
+	g.passthrough = true
+	g.ResponseWriter.WriteHeader(g.statusCode)
+	if len(g.buf) > 0 {
+		g.ResponseWriter.Write(g.buf)
+	}
+}
+
+// bodyAllowed проверяет, может ли ответ с данным статусом иметь тело
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified &&
+		(status < 100 || status >= 200)
+}
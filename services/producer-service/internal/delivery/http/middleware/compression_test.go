@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	const minSize = 1024
+
+	// Большой JSON ответ наподобие /stats
+	large := []byte(`{"status":"success","recent_failures":[` +
+		strings.Repeat(`{"event_type":"user_created","error":"downstream unavailable"},`, 100) + `{}]}`)
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		status         int
+		body           []byte
+		wantGzip       bool
+	}{
+		{name: "large JSON gzipped", path: "/stats", acceptEncoding: "gzip", status: http.StatusOK, body: large, wantGzip: true},
+		{name: "gzip among encodings", path: "/stats", acceptEncoding: "br, gzip;q=0.8", status: http.StatusCreated, body: large, wantGzip: true},
+		{name: "gzip not requested", path: "/stats", status: http.StatusOK, body: large},
+		{name: "small body", path: "/stats", acceptEncoding: "gzip", status: http.StatusOK, body: []byte(`{"status":"ok"}`)},
+		{name: "metrics skipped", path: "/metrics", acceptEncoding: "gzip", status: http.StatusOK, body: large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write(tt.body)
+			})
+			chain := LoggingMiddleware(logger)(CompressionMiddleware(minSize, "/metrics")(handler))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			chain.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			// Обертка логирования видит статус обработчика сквозь gzip writer
+			if entry := hook.LastEntry(); entry == nil || entry.Data["status"] != tt.status {
+				t.Fatalf("logged status = %v, want %d", entry, tt.status)
+			}
+
+			body := rec.Body.Bytes()
+			if got := rec.Header().Get("Content-Encoding"); (got == "gzip") != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", got, tt.wantGzip)
+			}
+			if tt.wantGzip {
+				if len(body) >= len(tt.body) {
+					t.Fatalf("gzipped body is %d bytes, want less than %d", len(body), len(tt.body))
+				}
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatalf("decompress: %v", err)
+				}
+				if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+					t.Fatalf("Vary = %q, want Accept-Encoding", vary)
+				}
+			}
+			if string(body) != string(tt.body) {
+				t.Fatalf("body = %.60q, want %.60q", body, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"producer-service/internal/delivery/http/httpx"
+	"producer-service/internal/domain"
+)
+
+// IdempotencyKeyHeader заголовок ключа идемпотентности запроса
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader отмечает ответ, повторенный из кэша
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// idempotencyEntry сохраненный ответ на запрос с ключом идемпотентности
+type idempotencyEntry struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	inFlight    bool
+	status      int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// IdempotencyStore ограниченное in-memory хранилище ответов по ключу.
+// При переполнении вытесняются самые старые записи.
+type IdempotencyStore struct {
+	ttl      time.Duration
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewIdempotencyStore создает хранилище на capacity ключей с временем жизни ttl
+func NewIdempotencyStore(ttl time.Duration, capacity int) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// reserve возвращает сохраненную запись по ключу либо резервирует ключ
+// под выполняющийся запрос (created=true)
+func (s *IdempotencyStore) reserve(key string, fingerprint [sha256.Size]byte) (entry idempotencyEntry, created bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if elem, ok := s.entries[key]; ok {
+		existing := elem.Value.(*idempotencyEntry)
+		if existing.inFlight || now.Before(existing.expiresAt) {
+			return *existing, false
+		}
+		s.remove(elem)
+	}
+
+	for s.order.Len() >= s.capacity {
+		s.remove(s.order.Front())
+	}
+
+	reserved := &idempotencyEntry{key: key, fingerprint: fingerprint, inFlight: true}
+	s.entries[key] = s.order.PushBack(reserved)
+	return *reserved, true
+}
+
+// complete сохраняет ответ для зарезервированного ключа
+func (s *IdempotencyStore) complete(key string, status int, contentType string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return // вытеснен, пока запрос выполнялся
+	}
+
+	entry := elem.Value.(*idempotencyEntry)
+	entry.inFlight = false
+	entry.status = status
+	entry.contentType = contentType
+	entry.body = body
+	entry.expiresAt = time.Now().Add(s.ttl)
+}
+
+// release снимает резервирование, чтобы клиент мог повторить запрос
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+}
+
+func (s *IdempotencyStore) remove(elem *list.Element) {
+	entry := s.order.Remove(elem).(*idempotencyEntry)
+	delete(s.entries, entry.key)
+}
+
+// IdempotencyMiddleware повторяет сохраненный ответ для POST запросов с
+// Idempotency-Key вместо повторной публикации. Тот же ключ с другим телом или
+// путем, а также повтор до завершения первого запроса, получают 409.
+// Ответы 5xx не сохраняются, чтобы клиент мог повторить запрос.
+// Тело буферизуется до валидации в обработчике, поэтому ограничено maxBodyBytes.
+// Ключи разных tenant'ов (X-Tenant-ID) не пересекаются.
+func IdempotencyMiddleware(store *IdempotencyStore, maxBodyBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+			if key == "" || r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if tenant, ok := domain.TenantFromContext(r.Context()); ok {
+				key = tenant + "\x00" + key
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					httpx.WriteError(w, r, http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE", "Request body is too large")
+					return
+				}
+				httpx.WriteError(w, r, http.StatusBadRequest, "VALIDATION_ERROR", "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			fingerprint := sha256.Sum256(append([]byte(r.URL.Path+"\n"), body...))
+			entry, created := store.reserve(key, fingerprint)
+			if !created {
+				switch {
+				case entry.fingerprint != fingerprint:
+					httpx.WriteError(w, r, http.StatusConflict, "IDEMPOTENCY_CONFLICT", "Idempotency-Key was already used with a different request")
+				case entry.inFlight:
+					httpx.WriteError(w, r, http.StatusConflict, "IDEMPOTENCY_CONFLICT", "A request with this Idempotency-Key is still in progress")
+				default:
+					w.Header().Set("Content-Type", entry.contentType)
+					w.Header().Set(IdempotentReplayHeader, "true")
+					w.WriteHeader(entry.status)
+					w.Write(entry.body)
+				}
+				return
+			}
+
+			// При панике резервирование снимается, RecoveryMiddleware пишет ответ сам
+			completed := false
+			defer func() {
+				if !completed {
+					store.release(key)
+				}
+			}()
+
+			rec := &recordingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.statusCode >= http.StatusInternalServerError {
+				return
+			}
+			store.complete(key, rec.statusCode, w.Header().Get("Content-Type"), rec.body.Bytes())
+			completed = true
+		})
+	}
+}
+
+// recordingResponseWriter пишет ответ клиенту и сохраняет копию для повтора
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingResponseWriter) Write(p []byte) (int, error) {
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"producer-service/internal/domain"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	first := sha256.Sum256([]byte("first"))
+	second := sha256.Sum256([]byte("second"))
+
+	tests := []struct {
+		name        string
+		run         func(s *IdempotencyStore) (idempotencyEntry, bool)
+		wantCreated bool
+		wantStatus  int
+	}{
+		{
+			name: "new key is reserved",
+			run: func(s *IdempotencyStore) (idempotencyEntry, bool) {
+				return s.reserve("k", first)
+			},
+			wantCreated: true,
+		},
+		{
+			name: "completed key returns stored response",
+			run: func(s *IdempotencyStore) (idempotencyEntry, bool) {
+				s.reserve("k", first)
+				s.complete("k", http.StatusCreated, "application/json", []byte("{}"))
+				return s.reserve("k", first)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "in-flight key is not reserved twice",
+			run: func(s *IdempotencyStore) (idempotencyEntry, bool) {
+				s.reserve("k", first)
+				return s.reserve("k", second)
+			},
+		},
+		{
+			name: "released key can be reserved again",
+			run: func(s *IdempotencyStore) (idempotencyEntry, bool) {
+				s.reserve("k", first)
+				s.release("k")
+				return s.reserve("k", second)
+			},
+			wantCreated: true,
+		},
+		{
+			name: "oldest key is evicted at capacity",
+			run: func(s *IdempotencyStore) (idempotencyEntry, bool) {
+				s.reserve("k", first)
+				s.complete("k", http.StatusCreated, "application/json", nil)
+				s.reserve("a", first)
+				s.reserve("b", first)
+				return s.reserve("k", first)
+			},
+			wantCreated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, created := tt.run(NewIdempotencyStore(time.Hour, 2))
+			if created != tt.wantCreated {
+				t.Fatalf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if entry.status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", entry.status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	store := NewIdempotencyStore(time.Nanosecond, 10)
+	fingerprint := sha256.Sum256([]byte("body"))
+
+	store.reserve("k", fingerprint)
+	store.complete("k", http.StatusCreated, "application/json", nil)
+	time.Sleep(time.Millisecond)
+
+	if _, created := store.reserve("k", fingerprint); !created {
+		t.Fatal("expired key was not reserved again")
+	}
+}
+
+// idempotencyServer возвращает обработчик, отвечающий номером вызова
+func idempotencyServer(maxBodyBytes int64) (http.Handler, *atomic.Int32) {
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(NewIdempotencyStore(time.Hour, 100), maxBodyBytes)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "call %d", calls.Add(1))
+		}),
+	)
+	return handler, &calls
+}
+
+func postIdempotent(handler http.Handler, key, tenant, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events/user", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	if tenant != "" {
+		req = req.WithContext(domain.WithTenant(req.Context(), tenant))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	handler, calls := idempotencyServer(1024)
+
+	first := postIdempotent(handler, "key-1", "", `{"data":"a"}`)
+	replay := postIdempotent(handler, "key-1", "", `{"data":"a"}`)
+	if calls.Load() != 1 || replay.Body.String() != first.Body.String() || replay.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Fatalf("replay = %d %q after %d calls, want the stored response", replay.Code, replay.Body.String(), calls.Load())
+	}
+
+	if conflict := postIdempotent(handler, "key-1", "", `{"data":"b"}`); conflict.Code != http.StatusConflict {
+		t.Fatalf("same key with another body = %d, want 409", conflict.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareScopesKeysByTenant(t *testing.T) {
+	handler, calls := idempotencyServer(1024)
+
+	acme := postIdempotent(handler, "key-1", "acme", `{"data":"a"}`)
+	globex := postIdempotent(handler, "key-1", "globex", `{"data":"a"}`)
+	if calls.Load() != 2 || globex.Header().Get(IdempotentReplayHeader) != "" {
+		t.Fatalf("tenant globex got a response cached for acme: %q, %q", acme.Body.String(), globex.Body.String())
+	}
+}
+
+func TestIdempotencyMiddlewareLimitsBody(t *testing.T) {
+	handler, calls := idempotencyServer(16)
+
+	rec := postIdempotent(handler, "key-1", "", strings.Repeat("x", 17))
+	if rec.Code != http.StatusRequestEntityTooLarge || calls.Load() != 0 {
+		t.Fatalf("oversized body = %d after %d calls, want 413 without calling the handler", rec.Code, calls.Load())
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"producer-service/internal/delivery/http/httpx"
+)
+
+// inFlightRetryAfter значение Retry-After (секунды) при превышении лимита запросов
+const inFlightRetryAfter = "1"
+
+// InFlightMetrics учитывает запросы, которые обрабатываются в данный момент
+type InFlightMetrics interface {
+	IncActiveRequests()
+	DecActiveRequests()
+}
+
+// InFlightLimitMiddleware ограничивает число одновременно обрабатываемых
+// запросов. Сверх лимита запрос сразу получает 503 с Retry-After, не
+// дожидаясь освобождения слота: очередь ожидающих запросов сама расходовала
+// бы память, от которой защищает лимит.
+func InFlightLimitMiddleware(max int, metrics InFlightMetrics) func(http.Handler) http.Handler {
+	slots := make(chan struct{}, max)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				w.Header().Set("Retry-After", inFlightRetryAfter)
+				httpx.WriteError(w, r, http.StatusServiceUnavailable, "TOO_MANY_REQUESTS_IN_FLIGHT", "Too many concurrent requests, retry later")
+				return
+			}
+
+			metrics.IncActiveRequests()
+			defer func() {
+				metrics.DecActiveRequests()
+				<-slots
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// activeRequests считает запросы в обработке, как gauge HTTP метрик
+type activeRequests struct {
+	active atomic.Int32
+}
+
+func (m *activeRequests) IncActiveRequests() { m.active.Add(1) }
+func (m *activeRequests) DecActiveRequests() { m.active.Add(-1) }
+
+func TestInFlightLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+	}{
+		{name: "single slot", max: 1},
+		{name: "several slots", max: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			metrics := &activeRequests{}
+			handler := InFlightLimitMiddleware(tt.max, metrics)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				<-release
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			// Занимаем все слоты запросами, которые ждут release
+			codes := make([]int, tt.max)
+			var wg sync.WaitGroup
+			for i := 0; i < tt.max; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events/user", nil))
+					codes[i] = rec.Code
+				}(i)
+			}
+			for i := 0; i < tt.max; i++ {
+				<-started
+			}
+			if got := metrics.active.Load(); got != int32(tt.max) {
+				t.Fatalf("active requests = %d, want %d", got, tt.max)
+			}
+
+			// N+1-й запрос отклоняется сразу, не дожидаясь слота
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events/user", nil))
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("N+1th request status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+			}
+			if got := rec.Header().Get("Retry-After"); got != inFlightRetryAfter {
+				t.Fatalf("Retry-After = %q, want %q", got, inFlightRetryAfter)
+			}
+
+			close(release)
+			wg.Wait()
+			for i, code := range codes {
+				if code != http.StatusOK {
+					t.Fatalf("in-flight request %d status = %d, want %d", i, code, http.StatusOK)
+				}
+			}
+			if got := metrics.active.Load(); got != 0 {
+				t.Fatalf("active requests after release = %d, want 0", got)
+			}
+
+			// Освободившийся слот снова принимает запросы
+			go func() { <-started }()
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events/user", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("request after release status = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
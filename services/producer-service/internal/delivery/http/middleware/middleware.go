@@ -1,10 +1,15 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
+	"producer-service/internal/delivery/http/httpx"
 	"producer-service/internal/domain"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -30,6 +35,42 @@ func PrometheusMiddleware(metrics domain.MetricsCollector) func(http.Handler) ht
 	}
 }
 
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware берет X-Request-ID из запроса или генерирует новый,
+// возвращает его в ответе и кладет в контекст как correlation ID
+func RequestIDMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := strings.TrimSpace(r.Header.Get(RequestIDHeader))
+			if requestID == "" {
+				requestID = generateRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := domain.WithCorrelationID(r.Context(), requestID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// TenantHeader заголовок с идентификатором tenant'а
+const TenantHeader = "X-Tenant-ID"
+
+// TenantMiddleware переносит X-Tenant-ID из запроса в контекст
+func TenantMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tenant := strings.TrimSpace(r.Header.Get(TenantHeader)); tenant != "" {
+				r = r.WithContext(domain.WithTenant(r.Context(), tenant))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // LoggingMiddleware создает middleware для логирования запросов
 func LoggingMiddleware(logger *logrus.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -42,6 +83,7 @@ func LoggingMiddleware(logger *logrus.Logger) func(http.Handler) http.Handler {
 			duration := time.Since(start)
 
 			logger.WithFields(logrus.Fields{
+				"request_id": w.Header().Get(RequestIDHeader),
 				"method":     r.Method,
 				"path":       r.URL.Path,
 				"status":     rw.statusCode,
@@ -68,9 +110,7 @@ func RecoveryMiddleware(logger *logrus.Logger) func(http.Handler) http.Handler {
 						"remote_ip":  getClientIP(r),
 					}).Error("Panic recovered")
 
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusInternalServerError)
-					_, err := w.Write([]byte(`{"error":"Internal Server Error","message":"An unexpected error occurred"}`))
+					err := httpx.WriteError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "An unexpected error occurred")
 					if err != nil {
 						logger.WithError(err).Error("Failed to write error response")
 					}
@@ -88,7 +128,7 @@ func CORSMiddleware() func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Idempotency-Key")
 			w.Header().Set("Access-Control-Max-Age", "86400")
 
 			if r.Method == "OPTIONS" {
@@ -115,6 +155,40 @@ func SecurityMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+// AllowlistMiddleware пропускает только запросы с разрешенных IP адресов
+func AllowlistMiddleware(allowedIPs []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(allowedIPs))
+	for _, ip := range allowedIPs {
+		allowed[strings.TrimSpace(ip)] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Используем адрес соединения, так как заголовки прокси можно подделать
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			if _, ok := allowed[host]; !ok {
+				httpx.WriteError(w, r, http.StatusForbidden, "FORBIDDEN", "Access from this address is not allowed")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// generateRequestID генерирует случайный идентификатор запроса
+func generateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
 // getClientIP получает IP адрес клиента
 func getClientIP(r *http.Request) string {
 	// Проверяем заголовки прокси
@@ -0,0 +1,221 @@
+package domain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// DataEncodingGzip data сжат gzip и закодирован base64. Значение передается
+// в заголовке сообщения data-encoding, сам формат события не меняется.
+const DataEncodingGzip = "gzip"
+
+// ErrUnsupportedDataEncoding неизвестное значение заголовка data-encoding
+var ErrUnsupportedDataEncoding = errors.New("unsupported data encoding")
+
+// EventCodec сериализует события в стабильный JSON формат.
+//
+// Формат события: id, type, data, timestamp (RFC3339 в UTC), version, source
+// и необязательные metadata.
+// По умолчанию version и source присутствуют всегда, даже если пусты,
+// чтобы схема не расходилась между сервисами.
+type EventCodec struct {
+	// OmitEmpty пропускает пустые необязательные поля (version, source)
+	OmitEmpty bool
+
+	// Compression сжимает data при кодировании сообщения (EncodeMessage):
+	// "" — без сжатия, DataEncodingGzip — gzip. Данные короче
+	// CompressionMinBytes, как и не уменьшившиеся при сжатии, передаются как есть.
+	Compression         string
+	CompressionMinBytes int
+}
+
+// DefaultCodec кодек, используемый по умолчанию
+var DefaultCodec = EventCodec{}
+
+// eventJSON представление события на проводе
+type eventJSON struct {
+	ID        string            `json:"id"`
+	Type      EventType         `json:"type"`
+	Data      string            `json:"data"`
+	Timestamp time.Time         `json:"timestamp"`
+	Version   string            `json:"version"`
+	Source    string            `json:"source"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// eventJSONOmitEmpty представление события без пустых необязательных полей
+type eventJSONOmitEmpty struct {
+	ID        string            `json:"id"`
+	Type      EventType         `json:"type"`
+	Data      string            `json:"data"`
+	Timestamp time.Time         `json:"timestamp"`
+	Version   string            `json:"version,omitempty"`
+	Source    string            `json:"source,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// NewEventCodec создает кодек с заданной политикой omitempty
+func NewEventCodec(omitEmpty bool) EventCodec {
+	return EventCodec{OmitEmpty: omitEmpty}
+}
+
+// Encode сериализует событие в JSON
+func (c EventCodec) Encode(e *Event) ([]byte, error) {
+	wire := eventJSON{
+		ID:        e.ID,
+		Type:      e.Type,
+		Data:      e.Data,
+		Timestamp: e.Timestamp.UTC(),
+		Version:   e.Version,
+		Source:    e.Source,
+		Metadata:  e.Metadata,
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if c.OmitEmpty {
+		data, err = json.Marshal(eventJSONOmitEmpty(wire))
+	} else {
+		data, err = json.Marshal(wire)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return data, nil
+}
+
+// EncodeMessage сериализует событие для сообщения Kafka, сжимая data
+// по настройкам Compression. Возвращает значение заголовка data-encoding;
+// пустое значение — data не сжат.
+func (c EventCodec) EncodeMessage(e *Event) ([]byte, string, error) {
+	if c.Compression != DataEncodingGzip || len(e.Data) < c.CompressionMinBytes {
+		data, err := c.Encode(e)
+		return data, "", err
+	}
+
+	compressed, err := compressData(e.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(compressed) >= len(e.Data) {
+		data, err := c.Encode(e)
+		return data, "", err
+	}
+
+	wire := *e
+	wire.Data = compressed
+	data, err := c.Encode(&wire)
+	return data, DataEncodingGzip, err
+}
+
+// DecodeMessage десериализует событие из сообщения Kafka и восстанавливает
+// data по значению заголовка data-encoding
+func (c EventCodec) DecodeMessage(data []byte, dataEncoding string) (*Event, error) {
+	event, err := c.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch dataEncoding {
+	case "":
+	case DataEncodingGzip:
+		if event.Data, err = decompressData(event.Data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedDataEncoding, dataEncoding)
+	}
+
+	return event, nil
+}
+
+// compressData сжимает data gzip и кодирует результат base64
+func compressData(data string) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		return "", fmt.Errorf("failed to compress event data: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress event data: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decompressData восстанавливает data, сжатый compressData. Размер результата
+// ограничен MaxDataLength, чтобы сообщение-"бомба" не исчерпало память.
+func decompressData(data string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode compressed event data: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress event data: %w", err)
+	}
+	defer zr.Close()
+
+	maxLen := MaxDataLength()
+	plain, err := io.ReadAll(io.LimitReader(zr, int64(maxLen)+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress event data: %w", err)
+	}
+	if len(plain) > maxLen {
+		return "", fmt.Errorf("%w: decompressed data exceeds maximum %d", ErrEventDataTooLong, maxLen)
+	}
+	return string(plain), nil
+}
+
+// Decode десериализует событие из JSON
+func (c EventCodec) Decode(data []byte) (*Event, error) {
+	var wire eventJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	return &Event{
+		ID:        wire.ID,
+		Type:      wire.Type,
+		Data:      wire.Data,
+		Timestamp: wire.Timestamp,
+		Version:   wire.Version,
+		Source:    wire.Source,
+		Metadata:  wire.Metadata,
+	}, nil
+}
+
+// knownFields поля события, известные текущей версии схемы
+var knownFields = map[string]struct{}{
+	"id": {}, "type": {}, "data": {}, "timestamp": {},
+	"version": {}, "source": {}, "metadata": {},
+}
+
+// UnknownFields возвращает отсортированный список полей верхнего уровня,
+// которых нет в текущей схеме события. Используется для раннего
+// обнаружения расхождения схем между producer и consumer.
+func UnknownFields(data []byte) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	var unknown []string
+	for name := range raw {
+		if _, ok := knownFields[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown, nil
+}
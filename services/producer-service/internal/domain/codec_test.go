@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// largeIntData payload с целыми, которые не представимы в float64 точно
+const largeIntData = `{"order_id":9007199254740993,"amount":12345678901234567890,"card":"4111"}`
+
+func TestCodecKeepsLargeIntegersInData(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec EventCodec
+	}{
+		{name: "plain", codec: DefaultCodec},
+		{name: "gzip", codec: EventCodec{Compression: DataEncodingGzip}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := NewEvent(UserCreatedEvent, largeIntData)
+			if err != nil {
+				t.Fatalf("NewEvent: %v", err)
+			}
+
+			payload, dataEncoding, err := tt.codec.EncodeMessage(event)
+			if err != nil {
+				t.Fatalf("EncodeMessage: %v", err)
+			}
+			decoded, err := tt.codec.DecodeMessage(payload, dataEncoding)
+			if err != nil {
+				t.Fatalf("DecodeMessage: %v", err)
+			}
+			if decoded.Data != largeIntData {
+				t.Fatalf("data after round-trip = %s, want %s", decoded.Data, largeIntData)
+			}
+			// Timestamp передается в RFC3339 и может потерять доли секунды
+			if !decoded.EqualWithin(event, time.Second) {
+				t.Fatalf("event after round-trip = %+v, want %+v", decoded, event)
+			}
+		})
+	}
+}
+
+func TestRedactorKeepsLargeIntegers(t *testing.T) {
+	redacted := NewRedactor([]string{"card"}).Redact(largeIntData)
+
+	for _, want := range []string{`"order_id":9007199254740993`, `"amount":12345678901234567890`, `"card":"[REDACTED]"`} {
+		if !strings.Contains(redacted, want) {
+			t.Errorf("Redact() = %s, want it to contain %s", redacted, want)
+		}
+	}
+}
+
+// updateGolden перезаписывает testdata/*.golden текущим выводом кодека:
+// go test ./internal/domain -run TestEncodeMessageGolden -update
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// goldenEvent событие с фиксированными полями для golden-файлов; время не в UTC,
+// чтобы проверить приведение timestamp к UTC
+func goldenEvent() *Event {
+	return &Event{
+		ID:        "3f1c2b9e-8d4a-4c5e-9f7b-1a2b3c4d5e6f",
+		Type:      UserCreatedEvent,
+		Data:      `{"user_id":42,"email":"user@example.com"}`,
+		Timestamp: time.Date(2024, 3, 1, 12, 30, 45, 0, time.FixedZone("MSK", 3*60*60)),
+		Metadata:  map[string]string{MetadataTenantID: "acme", MetadataCorrelationID: "req-1"},
+	}
+}
+
+func TestEncodeMessageGolden(t *testing.T) {
+	withSource := goldenEvent()
+	withSource.Version, withSource.Source = "1.0", "producer-service"
+
+	tests := []struct {
+		golden string
+		codec  EventCodec
+		event  *Event
+	}{
+		{golden: "event_default", codec: DefaultCodec, event: goldenEvent()},
+		{golden: "event_omitempty", codec: NewEventCodec(true), event: goldenEvent()},
+		{golden: "event_full", codec: DefaultCodec, event: withSource},
+		// data короче порога сжатия передается как есть
+		{golden: "event_default", codec: EventCodec{Compression: DataEncodingGzip, CompressionMinBytes: 1024}, event: goldenEvent()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			payload, dataEncoding, err := tt.codec.EncodeMessage(tt.event)
+			if err != nil {
+				t.Fatalf("EncodeMessage: %v", err)
+			}
+			if dataEncoding != "" {
+				t.Fatalf("data-encoding = %q, want none", dataEncoding)
+			}
+
+			path := filepath.Join("testdata", tt.golden+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(path, append(payload, '\n'), 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			if !bytes.Equal(payload, bytes.TrimSuffix(want, []byte("\n"))) {
+				t.Fatalf("EncodeMessage output changed:\n got: %s\nwant: %s", payload, want)
+			}
+		})
+	}
+}
+
+// setMaxDataLength меняет лимит размера data на время теста
+func setMaxDataLength(t *testing.T, n int) {
+	t.Helper()
+
+	prev := MaxDataLength()
+	SetMaxDataLength(n)
+	t.Cleanup(func() { SetMaxDataLength(prev) })
+}
+
+// largeData JSON payload из n одинаковых записей
+func largeData(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = `{"sku":"SKU-000123","qty":1,"price":"19.99","warehouse":"msk-1"}`
+	}
+	return `{"items":[` + strings.Join(items, ",") + `]}`
+}
+
+func TestCodecCompressionRoundTrip(t *testing.T) {
+	setMaxDataLength(t, 1<<20)
+
+	tests := []struct {
+		name         string
+		codec        EventCodec
+		data         string
+		wantEncoding string
+	}{
+		{name: "large payload compressed", codec: EventCodec{Compression: DataEncodingGzip, CompressionMinBytes: 1024}, data: largeData(8000), wantEncoding: DataEncodingGzip},
+		{name: "below threshold sent as is", codec: EventCodec{Compression: DataEncodingGzip, CompressionMinBytes: 1024}, data: largeData(2)},
+		{name: "compression disabled", codec: DefaultCodec, data: largeData(8000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := NewEvent(UserCreatedEvent, tt.data)
+			if err != nil {
+				t.Fatalf("NewEvent: %v", err)
+			}
+
+			payload, dataEncoding, err := tt.codec.EncodeMessage(event)
+			if err != nil {
+				t.Fatalf("EncodeMessage: %v", err)
+			}
+			if dataEncoding != tt.wantEncoding {
+				t.Fatalf("data-encoding = %q, want %q", dataEncoding, tt.wantEncoding)
+			}
+			if dataEncoding != "" && len(payload) >= len(tt.data)/10 {
+				t.Fatalf("compressed payload is %d bytes for %d bytes of data", len(payload), len(tt.data))
+			}
+
+			decoded, err := DefaultCodec.DecodeMessage(payload, dataEncoding)
+			if err != nil {
+				t.Fatalf("DecodeMessage: %v", err)
+			}
+			if decoded.Data != tt.data || decoded.ID != event.ID {
+				t.Fatalf("round-trip changed the event: got %d bytes of data for %s, want %d for %s",
+					len(decoded.Data), decoded.ID, len(tt.data), event.ID)
+			}
+		})
+	}
+}
+
+func TestDecodeMessageRejects(t *testing.T) {
+	setMaxDataLength(t, 4096)
+
+	// Сжатый data больше лимита: на проводе сообщение маленькое
+	bomb, err := compressData(largeData(1000))
+	if err != nil {
+		t.Fatalf("compressData: %v", err)
+	}
+	wire := func(data string) []byte {
+		payload, err := DefaultCodec.Encode(&Event{ID: "evt-1", Type: UserCreatedEvent, Data: data, Timestamp: time.Now()})
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		return payload
+	}
+
+	tests := []struct {
+		name         string
+		payload      []byte
+		dataEncoding string
+		wantErr      error
+	}{
+		{name: "decompressed data over limit", payload: wire(bomb), dataEncoding: DataEncodingGzip, wantErr: ErrEventDataTooLong},
+		{name: "unknown data encoding", payload: wire(`{"id":1}`), dataEncoding: "br", wantErr: ErrUnsupportedDataEncoding},
+		{name: "data is not base64", payload: wire(`{"id":1}`), dataEncoding: DataEncodingGzip},
+		{name: "data is not gzip", payload: wire("bm90IGd6aXA="), dataEncoding: DataEncodingGzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DefaultCodec.DecodeMessage(tt.payload, tt.dataEncoding)
+			if err == nil {
+				t.Fatal("DecodeMessage succeeded, want error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeMessage error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
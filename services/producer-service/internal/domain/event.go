@@ -2,10 +2,10 @@ package domain
 
 import (
 	"crypto/rand"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -13,11 +13,35 @@ import (
 
 // Константы для валидации
 const (
-	MaxEventDataLength = 10000 // 10KB
+	MaxEventDataLength = 10000 // 10KB, лимит по умолчанию
 	MinEventDataLength = 1
 	EventIDLength      = 8
 )
 
+// validate переиспользуется между вызовами: он потокобезопасен
+// и кэширует разобранные теги структур
+var validate = validator.New()
+
+// maxDataLength действующий лимит размера данных события (EVENT_MAX_DATA_BYTES)
+var maxDataLength atomic.Int64
+
+func init() {
+	maxDataLength.Store(MaxEventDataLength)
+}
+
+// SetMaxDataLength задает лимит размера данных события; вызывается при старте сервиса
+func SetMaxDataLength(n int) {
+	if n <= 0 {
+		n = MaxEventDataLength
+	}
+	maxDataLength.Store(int64(n))
+}
+
+// MaxDataLength возвращает действующий лимит размера данных события
+func MaxDataLength() int {
+	return int(maxDataLength.Load())
+}
+
 // Доменные ошибки
 var (
 	ErrInvalidEventData      = errors.New("event data cannot be empty")
@@ -50,38 +74,95 @@ func (et EventType) IsValid() bool {
 	}
 }
 
-// Event представляет доменное событие
+// Event представляет доменное событие.
+// Определение совпадает в producer-service и consumer-service: сервисы
+// собираются независимо, поэтому изменения нужно вносить в оба файла.
 type Event struct {
-	ID        string    `json:"id" validate:"required,min=1"`
-	Type      EventType `json:"type" validate:"required"`
-	Data      string    `json:"data" validate:"required,min=1,max=10000"`
-	Timestamp time.Time `json:"timestamp" validate:"required"`
-	Version   string    `json:"version,omitempty"`
-	Source    string    `json:"source,omitempty"`
+	ID        string            `json:"id" validate:"required,min=1"`
+	Type      EventType         `json:"type" validate:"required"`
+	Data      string            `json:"data" validate:"required,min=1"`
+	Timestamp time.Time         `json:"timestamp" validate:"required"`
+	Version   string            `json:"version,omitempty"`
+	Source    string            `json:"source,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
-// NewEvent создает новое событие
+// IDGenerator генерирует идентификаторы событий
+type IDGenerator interface {
+	Next(eventType EventType) string
+}
+
+// IDGeneratorFunc позволяет использовать функцию как IDGenerator
+type IDGeneratorFunc func(eventType EventType) string
+
+// Next возвращает следующий идентификатор
+func (f IDGeneratorFunc) Next(eventType EventType) string {
+	return f(eventType)
+}
+
+// DefaultIDGenerator формирует ID вида <type>_<YYYYMMDDhhmmss>_<random>
+var DefaultIDGenerator IDGenerator = IDGeneratorFunc(generateEventID)
+
+// EventFactory создает события с заданным генератором идентификаторов
+type EventFactory struct {
+	ids   IDGenerator
+	clock Clock
+}
+
+// NewEventFactory создает фабрику событий; nil означает DefaultIDGenerator
+func NewEventFactory(ids IDGenerator) *EventFactory {
+	if ids == nil {
+		ids = DefaultIDGenerator
+	}
+	return &EventFactory{ids: ids, clock: SystemClock}
+}
+
+// WithClock возвращает копию фабрики, берущую время событий из clock
+func (f *EventFactory) WithClock(clock Clock) *EventFactory {
+	return &EventFactory{ids: f.ids, clock: clock}
+}
+
+// Now возвращает текущее время по часам фабрики
+func (f *EventFactory) Now() time.Time {
+	return f.clock.Now()
+}
+
+// defaultFactory используется функцией NewEvent
+var defaultFactory = NewEventFactory(nil)
+
+// NewEvent создает новое событие с генератором ID по умолчанию
 func NewEvent(eventType EventType, data string) (*Event, error) {
+	return defaultFactory.NewEvent(eventType, data)
+}
+
+// NewEvent создает новое событие
+func (f *EventFactory) NewEvent(eventType EventType, data string) (*Event, error) {
+	now := f.clock.Now()
 	event := &Event{
-		ID:        generateEventID(eventType),
+		ID:        f.ids.Next(eventType),
 		Type:      eventType,
 		Data:      data,
-		Timestamp: time.Now().UTC(),
+		Timestamp: now.UTC(),
 		Version:   "1.0",
 		Source:    "producer-service",
 	}
 
-	if err := event.Validate(); err != nil {
+	if err := event.ValidateAt(now); err != nil {
 		return nil, fmt.Errorf("failed to create event: %w", err)
 	}
 
 	return event, nil
 }
 
-// Validate проверяет валидность события
+// Validate проверяет валидность события относительно текущего времени
 func (e *Event) Validate() error {
+	return e.ValidateAt(time.Now())
+}
+
+// ValidateAt проверяет валидность события; now — момент, относительно
+// которого timestamp считается будущим
+func (e *Event) ValidateAt(now time.Time) error {
 	// Структурная валидация
-	validate := validator.New()
 	if err := validate.Struct(e); err != nil {
 		return fmt.Errorf("%w: %v", ErrEventValidationFailed, err)
 	}
@@ -91,9 +172,9 @@ func (e *Event) Validate() error {
 		return fmt.Errorf("%w: %s", ErrInvalidEventType, e.Type)
 	}
 
-	if len(e.Data) > MaxEventDataLength {
+	if maxLen := MaxDataLength(); len(e.Data) > maxLen {
 		return fmt.Errorf("%w: data length %d exceeds maximum %d",
-			ErrEventDataTooLong, len(e.Data), MaxEventDataLength)
+			ErrEventDataTooLong, len(e.Data), maxLen)
 	}
 
 	if len(e.Data) < MinEventDataLength {
@@ -105,20 +186,38 @@ func (e *Event) Validate() error {
 		return fmt.Errorf("%w: timestamp cannot be zero", ErrInvalidTimestamp)
 	}
 
-	if e.Timestamp.After(time.Now().Add(time.Minute)) {
+	if e.Timestamp.After(now.Add(time.Minute)) {
 		return fmt.Errorf("%w: timestamp cannot be in the future", ErrInvalidTimestamp)
 	}
 
 	return nil
 }
 
-// ToJSON сериализует событие в JSON
+// ToJSON сериализует событие в JSON кодеком по умолчанию
 func (e *Event) ToJSON() ([]byte, error) {
-	return json.Marshal(e)
+	return DefaultCodec.Encode(e)
+}
+
+// FromJSON преобразует JSON в Event кодеком по умолчанию
+func FromJSON(data []byte) (*Event, error) {
+	return DefaultCodec.Decode(data)
+}
+
+// MarshalJSON сериализует событие в стабильный формат DefaultCodec
+func (e *Event) MarshalJSON() ([]byte, error) {
+	return DefaultCodec.Encode(e)
 }
 
 // Clone создает копию события
 func (e *Event) Clone() *Event {
+	var metadata map[string]string
+	if e.Metadata != nil {
+		metadata = make(map[string]string, len(e.Metadata))
+		for k, v := range e.Metadata {
+			metadata[k] = v
+		}
+	}
+
 	return &Event{
 		ID:        e.ID,
 		Type:      e.Type,
@@ -126,7 +225,54 @@ func (e *Event) Clone() *Event {
 		Timestamp: e.Timestamp,
 		Version:   e.Version,
 		Source:    e.Source,
+		Metadata:  metadata,
+	}
+}
+
+// Equal сравнивает все поля событий, включая metadata. Timestamp сравнивается
+// через time.Equal, поэтому разные часовые пояса одного момента равны.
+// nil равно только nil; пустая и nil metadata считаются равными.
+func (e *Event) Equal(other *Event) bool {
+	return e.EqualWithin(other, 0)
+}
+
+// EqualWithin как Equal, но допускает расхождение Timestamp не больше tolerance
+// (например, после сериализации с потерей точности)
+func (e *Event) EqualWithin(other *Event, tolerance time.Duration) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+
+	if e.ID != other.ID || e.Type != other.Type || e.Data != other.Data ||
+		e.Version != other.Version || e.Source != other.Source {
+		return false
+	}
+
+	diff := e.Timestamp.Sub(other.Timestamp)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > tolerance {
+		return false
+	}
+
+	if len(e.Metadata) != len(other.Metadata) {
+		return false
+	}
+	for k, v := range e.Metadata {
+		if ov, ok := other.Metadata[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SetMetadata устанавливает значение метаданных события
+func (e *Event) SetMetadata(key, value string) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
 	}
+	e.Metadata[key] = value
 }
 
 func generateEventID(eventType EventType) string {
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestEventEqual(t *testing.T) {
+	base := &Event{
+		ID:        "evt-1",
+		Type:      UserCreatedEvent,
+		Data:      `{"id":1}`,
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Version:   "1.0",
+		Source:    "producer",
+		Metadata:  map[string]string{"tenant": "acme"},
+	}
+
+	tests := []struct {
+		name      string
+		change    func(e *Event)
+		tolerance time.Duration
+		want      bool
+	}{
+		{name: "identical", change: func(*Event) {}, want: true},
+		{name: "same instant in another zone", change: func(e *Event) { e.Timestamp = e.Timestamp.In(time.FixedZone("MSK", 3*3600)) }, want: true},
+		{name: "metadata removed", change: func(e *Event) { e.Metadata = nil }},
+		{name: "id differs", change: func(e *Event) { e.ID = "evt-2" }},
+		{name: "data differs", change: func(e *Event) { e.Data = `{"id":2}` }},
+		{name: "source differs", change: func(e *Event) { e.Source = "other" }},
+		{name: "metadata value differs", change: func(e *Event) { e.Metadata["tenant"] = "globex" }},
+		{name: "metadata key added", change: func(e *Event) { e.Metadata["region"] = "eu" }},
+		{name: "timestamp differs", change: func(e *Event) { e.Timestamp = e.Timestamp.Add(time.Millisecond) }},
+		{name: "timestamp within tolerance", change: func(e *Event) { e.Timestamp = e.Timestamp.Add(-time.Millisecond) }, tolerance: time.Second, want: true},
+		{name: "timestamp beyond tolerance", change: func(e *Event) { e.Timestamp = e.Timestamp.Add(2 * time.Second) }, tolerance: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := base.Clone()
+			tt.change(other)
+			if got := base.EqualWithin(other, tt.tolerance); got != tt.want {
+				t.Fatalf("EqualWithin = %v, want %v", got, tt.want)
+			}
+			if got := other.EqualWithin(base, tt.tolerance); got != tt.want {
+				t.Fatalf("EqualWithin is not symmetric: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventEqualNil(t *testing.T) {
+	var missing *Event
+	event := &Event{ID: "evt-1"}
+
+	if !missing.Equal(nil) {
+		t.Error("nil event is not equal to nil")
+	}
+	if event.Equal(nil) || missing.Equal(event) {
+		t.Error("nil event is equal to a non-nil event")
+	}
+	if !event.Equal(&Event{ID: "evt-1", Metadata: map[string]string{}}) {
+		t.Error("nil and empty metadata are not equal")
+	}
+}
+
+func TestEventFactoryClock(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := NewFakeClock(created)
+
+	event, err := NewEventFactory(nil).WithClock(clock).NewEvent(UserCreatedEvent, `{"id":1}`)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	if !event.Timestamp.Equal(created) {
+		t.Fatalf("Timestamp = %v, want the fake clock time %v", event.Timestamp, created)
+	}
+
+	// Допустимое опережение часов источника — одна минута
+	tests := []struct {
+		name    string
+		advance time.Duration
+		wantErr bool
+	}{
+		{name: "same moment", advance: 0},
+		{name: "timestamp in the past", advance: 24 * time.Hour},
+		{name: "timestamp a minute ahead", advance: -time.Minute},
+		{name: "timestamp beyond the allowed skew", advance: -time.Minute - time.Nanosecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Set(created)
+			clock.Advance(tt.advance)
+
+			err := event.ValidateAt(clock.Now())
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidTimestamp)) {
+				t.Fatalf("ValidateAt() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// sharedDomainFiles файлы (относительно internal/domain), которые должны
+// совпадать в producer-service и consumer-service с точностью до имени модуля:
+// формат события на проводе и ответа с ошибкой у сервисов общий
+var sharedDomainFiles = []string{
+	"event.go", "codec.go", "clock.go", "redact.go",
+	"event_test.go", "codec_test.go",
+	"testdata/event_default.golden", "testdata/event_omitempty.golden", "testdata/event_full.golden",
+	"../delivery/http/httpx/error.go",
+}
+
+// moduleImport импорт пакета producer-service; в копии consumer-service
+// на его месте импорт того же пакета своего модуля
+var moduleImport = regexp.MustCompile(`(?m)^\t"producer-service/`)
+
+func TestSharedDomainFilesInSync(t *testing.T) {
+	// Тест лежит в обоих сервисах, поэтому пути считаются от каталога services
+	services := filepath.Join("..", "..", "..")
+	producer := filepath.Join(services, "producer-service", "internal", "domain")
+	consumer := filepath.Join(services, "consumer-service", "internal", "domain")
+	for _, dir := range []string{producer, consumer} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Skipf("sibling service is not available (%v), e.g. in a Docker build context", err)
+		}
+	}
+
+	for _, name := range sharedDomainFiles {
+		t.Run(name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join(producer, name))
+			if err != nil {
+				t.Fatalf("read producer copy: %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(consumer, name))
+			if err != nil {
+				t.Fatalf("read consumer copy: %v", err)
+			}
+			want = moduleImport.ReplaceAll(want, []byte("\t\"consumer-service/"))
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s differs between producer-service and consumer-service; apply the change to both copies", name)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 // EventPublisher интерфейс для публикации событий
 type EventPublisher interface {
@@ -16,13 +20,56 @@ type EventService interface {
 	// CreateAndPublish создает и публикует событие
 	CreateAndPublish(ctx context.Context, eventType EventType, data string) (*Event, error)
 
+	// PublishExisting публикует готовое событие без изменения ID и timestamp
+	PublishExisting(ctx context.Context, event *Event) error
+
 	// GetEventStats получает статистику по событиям
 	GetEventStats(ctx context.Context) (*EventStats, error)
 
+	// ResetEventStats обнуляет накопленную статистику
+	ResetEventStats(ctx context.Context) error
+
 	// CreateUserEvent создает событие создания пользователя
 	CreateUserEvent(ctx context.Context, data string) (*Event, error)
 }
 
+// AsyncEventService интерфейс асинхронной публикации событий
+type AsyncEventService interface {
+	// SubmitUserEvent ставит событие создания пользователя в очередь публикации
+	SubmitUserEvent(ctx context.Context, data string) (*Event, error)
+
+	// Status возвращает статус ранее поставленного события
+	Status(eventID string) (*EventStatus, bool)
+}
+
+// EventAuditor фиксирует опубликованные события в журнале аудита
+type EventAuditor interface {
+	// RecordPublished вызывается после подтвержденной записи события в Kafka
+	RecordPublished(event *Event)
+}
+
+// ErrQueueFull очередь асинхронной публикации заполнена
+var ErrQueueFull = errors.New("async publish queue is full")
+
+// ErrTopicNotFound топик публикации отсутствует в кластере (например, удален во время работы)
+var ErrTopicNotFound = errors.New("kafka topic not found")
+
+// Статусы асинхронной публикации события
+const (
+	StatusPending   = "pending"
+	StatusPublished = "published"
+	StatusFailed    = "failed"
+)
+
+// EventStatus состояние асинхронно публикуемого события
+type EventStatus struct {
+	EventID   string    `json:"event_id"`
+	EventType EventType `json:"event_type"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // EventStats статистика по событиям
 type EventStats struct {
 	TotalEvents   int64            `json:"total_events"`
@@ -38,6 +85,29 @@ type HealthChecker interface {
 	Check(ctx context.Context) error
 }
 
+// Статусы проверки компонента
+const (
+	HealthStatusOK      = "ok"
+	HealthStatusFail    = "fail"
+	HealthStatusTimeout = "timeout" // проверка не уложилась в общий бюджет readiness
+)
+
+// ComponentHealth результат проверки одного компонента
+type ComponentHealth struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// HealthReport сводный результат проверок: Ready ложно,
+// если не прошла хотя бы одна критическая проверка
+type HealthReport struct {
+	Ready      bool                       `json:"ready"`
+	TimedOut   bool                       `json:"timed_out"` // критическая проверка прервана по бюджету
+	Components map[string]ComponentHealth `json:"components"`
+}
+
 // Logger интерфейс для логирования
 type Logger interface {
 	Debug(msg string, fields ...interface{})
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StdoutSink значение AUDIT_LOG_PATH для записи журнала в stdout
+const StdoutSink = "stdout"
+
+// record строка журнала аудита об опубликованном событии
+type record struct {
+	ID            string           `json:"id"`
+	Type          domain.EventType `json:"type"`
+	Timestamp     time.Time        `json:"timestamp"`
+	Source        string           `json:"source,omitempty"`
+	CorrelationID string           `json:"correlation_id,omitempty"`
+	PublishedAt   time.Time        `json:"published_at"`
+}
+
+// AuditLogger пишет журнал аудита: одна JSON строка на опубликованное событие.
+// Журнал не зависит от уровня и формата операционных логов; файл открывается
+// только на дозапись. Записи буферизуются и сбрасываются при Close.
+type AuditLogger struct {
+	mu     sync.Mutex
+	out    *bufio.Writer
+	file   *os.File // nil для stdout
+	logger *logrus.Logger
+}
+
+var _ domain.EventAuditor = (*AuditLogger)(nil)
+
+// NewAuditLogger открывает журнал аудита: путь к файлу или StdoutSink
+func NewAuditLogger(path string, logger *logrus.Logger) (*AuditLogger, error) {
+	a := &AuditLogger{logger: logger}
+
+	var w io.Writer = os.Stdout
+	if path != StdoutSink {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		a.file = file
+		w = file
+	}
+	a.out = bufio.NewWriter(w)
+
+	return a, nil
+}
+
+// RecordPublished добавляет в журнал запись об опубликованном событии
+func (a *AuditLogger) RecordPublished(event *domain.Event) {
+	line, err := json.Marshal(record{
+		ID:            event.ID,
+		Type:          event.Type,
+		Timestamp:     event.Timestamp.UTC(),
+		Source:        event.Source,
+		CorrelationID: event.Metadata[domain.MetadataCorrelationID],
+		PublishedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		a.logger.WithError(err).WithField("event_id", event.ID).Error("Failed to encode audit record")
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.out.Write(append(line, '\n')); err != nil {
+		a.logger.WithError(err).WithField("event_id", event.ID).Error("Failed to write audit record")
+	}
+}
+
+// Close сбрасывает буфер журнала на диск и закрывает файл
+func (a *AuditLogger) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.out.Flush(); err != nil {
+		return fmt.Errorf("failed to flush audit log: %w", err)
+	}
+	if a.file == nil {
+		return nil
+	}
+	if err := a.file.Sync(); err != nil {
+		a.file.Close()
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return a.file.Close()
+}
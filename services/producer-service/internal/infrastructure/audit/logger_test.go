@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAuditLoggerRecordsPublishedEvents(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	timestamp := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	tests := []struct {
+		name  string
+		event *domain.Event
+		want  map[string]interface{}
+	}{
+		{
+			name: "all fields",
+			event: &domain.Event{
+				ID: "user_created_1", Type: domain.UserCreatedEvent, Timestamp: timestamp, Source: "producer-service",
+				Data: `{"email":"ann@example.com"}`, Metadata: map[string]string{domain.MetadataCorrelationID: "req-42"},
+			},
+			want: map[string]interface{}{
+				"id": "user_created_1", "type": "user_created", "timestamp": "2026-03-01T09:00:00Z",
+				"source": "producer-service", "correlation_id": "req-42",
+			},
+		},
+		{
+			name:  "optional fields omitted",
+			event: &domain.Event{ID: "user_created_2", Type: domain.UserCreatedEvent, Timestamp: timestamp},
+			want:  map[string]interface{}{"id": "user_created_2", "type": "user_created", "timestamp": "2026-03-01T09:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+
+			// Журнал только дописывается: повторное открытие сохраняет прежние записи
+			for i := 0; i < 2; i++ {
+				auditLogger, err := NewAuditLogger(path, logger)
+				if err != nil {
+					t.Fatalf("NewAuditLogger: %v", err)
+				}
+				auditLogger.RecordPublished(tt.event)
+				if err := auditLogger.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("open audit log: %v", err)
+			}
+			defer file.Close()
+
+			var lines int
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				lines++
+				var got map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+					t.Fatalf("line %d is not JSON: %v", lines, err)
+				}
+				published, _ := got["published_at"].(string)
+				publishedAt, err := time.Parse(time.RFC3339Nano, published)
+				if err != nil || time.Since(publishedAt) > time.Minute {
+					t.Fatalf("published_at = %v, want the publish time", got["published_at"])
+				}
+				delete(got, "published_at")
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("audit line %d = %v, want %v", lines, got, tt.want)
+				}
+			}
+			if lines != 2 {
+				t.Fatalf("audit log has %d lines, want one per publish", lines)
+			}
+		})
+	}
+}
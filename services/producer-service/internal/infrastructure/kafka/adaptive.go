@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// batchSizeController подстраивает порог сброса batch'а под нагрузку:
+// растет, пока отправка быстрая, а события копятся в eventChan,
+// и уменьшается, когда отправка batch'а превышает целевую задержку.
+type batchSizeController struct {
+	min           int
+	max           int
+	targetLatency time.Duration
+}
+
+// next вычисляет новый порог по задержке отправки и заполненности очереди (0..1)
+func (c *batchSizeController) next(current int, latency time.Duration, backlog float64) int {
+	switch {
+	case latency > c.targetLatency:
+		current = current * 3 / 4
+	case backlog >= 0.5:
+		current += max(current/4, 1)
+	default:
+		return current
+	}
+
+	return min(max(current, c.min), c.max)
+}
+
+// adjustBatchSize пересчитывает эффективный размер batch'а после отправки
+func (p *Producer) adjustBatchSize(latency time.Duration) {
+	if p.adaptive == nil {
+		return
+	}
+
+	backlog := float64(len(p.eventChan)) / float64(cap(p.eventChan))
+
+	p.batchMu.Lock()
+	previous := p.batchSize
+	p.batchSize = p.adaptive.next(previous, latency, backlog)
+	current := p.batchSize
+	p.batchMu.Unlock()
+
+	if current == previous {
+		return
+	}
+
+	p.metrics.SetEffectiveBatchSize(current)
+	p.logger.WithFields(logrus.Fields{
+		"previous_batch_size": previous,
+		"batch_size":          current,
+		"latency":             latency,
+		"backlog":             backlog,
+	}).Debug("Adaptive batch size changed")
+}
+
+// effectiveBatchSize возвращает текущий порог сброса batch'а; при адаптивном
+// батчинге его меняет sender, поэтому чтение идет под batchMu
+func (p *Producer) effectiveBatchSize() int {
+	p.batchMu.Lock()
+	defer p.batchMu.Unlock()
+	return p.batchSize
+}
@@ -0,0 +1,40 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchSizeControllerNext(t *testing.T) {
+	controller := &batchSizeController{min: 10, max: 400, targetLatency: 50 * time.Millisecond}
+
+	tests := []struct {
+		name    string
+		current int
+		latency time.Duration
+		backlog float64
+		want    int
+	}{
+		{name: "fast send with backlog grows by a quarter", current: 100, latency: 10 * time.Millisecond, backlog: 0.5, want: 125},
+		{name: "size below min is raised to min", current: 3, latency: 10 * time.Millisecond, backlog: 0.9, want: 10},
+		{name: "growth is capped at max", current: 380, latency: 10 * time.Millisecond, backlog: 1, want: 400},
+		{name: "slow send shrinks by a quarter", current: 100, latency: 80 * time.Millisecond, backlog: 1, want: 75},
+		{name: "shrink is floored at min", current: 12, latency: 80 * time.Millisecond, backlog: 0, want: 10},
+		{name: "latency at target keeps size without backlog", current: 100, latency: 50 * time.Millisecond, backlog: 0.2, want: 100},
+		{name: "idle queue keeps size", current: 100, latency: 10 * time.Millisecond, backlog: 0, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := controller.next(tt.current, tt.latency, tt.backlog); got != tt.want {
+				t.Fatalf("next(%d, %s, %v) = %d, want %d", tt.current, tt.latency, tt.backlog, got, tt.want)
+			}
+		})
+	}
+
+	// current/4 == 0 для малых batch'ей: рост все равно не меньше одного
+	small := &batchSizeController{min: 1, max: 10, targetLatency: time.Second}
+	if got := small.next(2, 0, 1); got != 3 {
+		t.Fatalf("next(2) with backlog = %d, want 3", got)
+	}
+}
@@ -0,0 +1,40 @@
+package kafka
+
+import "time"
+
+// BatchSnapshot состояние батчинга producer'а для диагностики зависаний
+type BatchSnapshot struct {
+	Async             bool       `json:"async"`
+	CurrentBatchSize  int        `json:"current_batch_size"`
+	CurrentBatchBytes int        `json:"current_batch_bytes"`
+	BatchThreshold    int        `json:"batch_threshold"`
+	EventQueueLength  int        `json:"event_queue_length"`
+	EventQueueCap     int        `json:"event_queue_capacity"`
+	BatchQueueLength  int        `json:"batch_queue_length"`
+	BatchQueueCap     int        `json:"batch_queue_capacity"`
+	LastFlush         *time.Time `json:"last_flush,omitempty"`
+}
+
+// DebugSnapshot возвращает мгновенный снимок текущего batch'а и очередей.
+// Длины каналов читаются без блокировки и могут немного расходиться между собой.
+func (p *Producer) DebugSnapshot() BatchSnapshot {
+	p.batchMu.Lock()
+	snapshot := BatchSnapshot{
+		Async:             p.async,
+		CurrentBatchSize:  len(p.currentBatch),
+		CurrentBatchBytes: p.currentBytes,
+		BatchThreshold:    p.batchSize,
+		EventQueueLength:  len(p.eventChan),
+		EventQueueCap:     cap(p.eventChan),
+		BatchQueueLength:  len(p.batchChan),
+		BatchQueueCap:     cap(p.batchChan),
+	}
+	p.batchMu.Unlock()
+
+	if nanos := p.lastFlush.Load(); nanos != 0 {
+		lastFlush := time.Unix(0, nanos).UTC()
+		snapshot.LastFlush = &lastFlush
+	}
+
+	return snapshot
+}
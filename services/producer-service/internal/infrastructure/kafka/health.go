@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"producer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var _ domain.HealthChecker = (*Producer)(nil)
+
+// Check проверяет доступность брокера и метаданных топика
+func (p *Producer) Check(ctx context.Context) error {
+	_, err := p.readPartitions(ctx)
+	return err
+}
+
+// readPartitions читает метаданные партиций топика с первого брокера, который
+// их вернул; ошибка возвращается, только если не ответил ни один брокер
+func (p *Producer) readPartitions(ctx context.Context) ([]kafka.Partition, error) {
+	var errs []error
+	for _, broker := range p.config.Brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("broker %s: %w", broker, err))
+			continue
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		partitions, err := conn.ReadPartitions(p.topic)
+		conn.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("broker %s: failed to read metadata for topic %s: %w", broker, p.topic, err))
+			continue
+		}
+		return partitions, nil
+	}
+
+	return nil, fmt.Errorf("no kafka broker reachable: %w", errors.Join(errs...))
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"producer-service/internal/config"
+	"producer-service/internal/domain"
+	"producer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// closingBroker принимает соединения и сразу закрывает их: подключение
+// проходит, а чтение метаданных завершается ошибкой
+func closingBroker(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestReadPartitionsTriesEveryBroker(t *testing.T) {
+	const unreachable = "127.0.0.1:1"
+	broken := closingBroker(t)
+
+	tests := []struct {
+		name    string
+		brokers []string
+		// Каждая строка должна войти в объединенную ошибку
+		wantErr []string
+	}{
+		{name: "unreachable", brokers: []string{unreachable}, wantErr: []string{"broker " + unreachable}},
+		{
+			name:    "metadata error does not stop the loop",
+			brokers: []string{broken, unreachable},
+			wantErr: []string{"broker " + broken + ": failed to read metadata", "broker " + unreachable},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer, err := NewProducer(
+				config.KafkaConfig{Brokers: tt.brokers, Topic: "events", WriteTimeout: time.Second},
+				domain.DefaultCodec, discardLogger(), metrics.NewProducerMetrics(prometheus.NewRegistry()),
+			)
+			if err != nil {
+				t.Fatalf("NewProducer: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err = producer.readPartitions(ctx)
+			if err == nil || !strings.Contains(err.Error(), "no kafka broker reachable") {
+				t.Fatalf("readPartitions() = %v, want no kafka broker reachable", err)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("readPartitions() = %v, want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"producer-service/internal/domain"
+)
+
+// FanoutMetrics учитывает результат публикации в каждый топик fan-out
+type FanoutMetrics interface {
+	IncFanoutPublish(topic, result string)
+}
+
+// TopicPublisher publisher одного топика fan-out
+type TopicPublisher struct {
+	Topic     string
+	Publisher domain.EventPublisher
+}
+
+// FanoutError ошибка публикации в часть топиков fan-out. Событие, уже
+// записанное в остальные топики, не откатывается.
+type FanoutError struct {
+	Failed map[string]error // топик -> ошибка
+}
+
+// Error перечисляет топики, в которые событие не попало
+func (e *FanoutError) Error() string {
+	topics := e.Topics()
+	parts := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		parts = append(parts, fmt.Sprintf("%s: %v", topic, e.Failed[topic]))
+	}
+	return "failed to publish to topics: " + strings.Join(parts, "; ")
+}
+
+// Unwrap позволяет проверять ошибки отдельных топиков через errors.Is
+func (e *FanoutError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, topic := range e.Topics() {
+		errs = append(errs, e.Failed[topic])
+	}
+	return errs
+}
+
+// Topics возвращает отсортированный список топиков с ошибкой
+func (e *FanoutError) Topics() []string {
+	topics := make([]string, 0, len(e.Failed))
+	for topic := range e.Failed {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// MultiTopicPublisher публикует одно событие в несколько топиков, например
+// в канонический топик и топик индексации. Топики пишутся параллельно и
+// независимо: ошибка одного не мешает доставке в остальные.
+type MultiTopicPublisher struct {
+	publishers []TopicPublisher
+	metrics    FanoutMetrics
+}
+
+var _ domain.EventPublisher = (*MultiTopicPublisher)(nil)
+
+// NewMultiTopicPublisher создает publisher для набора топиков
+func NewMultiTopicPublisher(publishers []TopicPublisher, metrics FanoutMetrics) (*MultiTopicPublisher, error) {
+	if len(publishers) == 0 {
+		return nil, fmt.Errorf("fan-out requires at least one topic")
+	}
+
+	seen := make(map[string]struct{}, len(publishers))
+	for _, p := range publishers {
+		if _, dup := seen[p.Topic]; dup || p.Topic == "" {
+			return nil, fmt.Errorf("empty or duplicate fan-out topic %q", p.Topic)
+		}
+		seen[p.Topic] = struct{}{}
+	}
+
+	return &MultiTopicPublisher{publishers: publishers, metrics: metrics}, nil
+}
+
+// Publish публикует событие во все топики. При ошибке части топиков
+// возвращает *FanoutError с перечнем неудавшихся.
+func (m *MultiTopicPublisher) Publish(ctx context.Context, event *domain.Event) error {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed map[string]error
+	)
+
+	for _, p := range m.publishers {
+		wg.Add(1)
+		go func(p TopicPublisher) {
+			defer wg.Done()
+
+			err := p.Publisher.Publish(ctx, event)
+			if err == nil {
+				m.metrics.IncFanoutPublish(p.Topic, "success")
+				return
+			}
+
+			m.metrics.IncFanoutPublish(p.Topic, "error")
+			mu.Lock()
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[p.Topic] = err
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	if failed != nil {
+		return &FanoutError{Failed: failed}
+	}
+	return nil
+}
+
+// Close закрывает publisher'ы всех топиков
+func (m *MultiTopicPublisher) Close() error {
+	var errs []error
+	for _, p := range m.publishers {
+		if err := p.Publisher.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Topic, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"producer-service/internal/config"
+)
+
+// fanoutRecorder запоминает вызовы IncFanoutPublish как "топик/результат"
+type fanoutRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *fanoutRecorder) IncFanoutPublish(topic, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, topic+"/"+result)
+}
+
+func TestMultiTopicPublisher(t *testing.T) {
+	errBrokerDown := errors.New("kafka: broker not available")
+	topics := []string{"events", "search-index", "audit"}
+
+	tests := []struct {
+		name        string
+		failing     []string
+		wantMetrics []string
+	}{
+		{
+			name:        "all topics",
+			wantMetrics: []string{"audit/success", "events/success", "search-index/success"},
+		},
+		{
+			// Ошибка одного топика не мешает доставке в остальные
+			name:        "single topic fails",
+			failing:     []string{"search-index"},
+			wantMetrics: []string{"audit/success", "events/success", "search-index/error"},
+		},
+		{
+			name:        "several topics fail",
+			failing:     []string{"audit", "events"},
+			wantMetrics: []string{"audit/error", "events/error", "search-index/success"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := make(map[string]bool, len(tt.failing))
+			for _, topic := range tt.failing {
+				failing[topic] = true
+			}
+
+			writers := make(map[string]*recordingWriter, len(topics))
+			publishers := make([]TopicPublisher, 0, len(topics))
+			for _, topic := range topics {
+				var writer MessageWriter = &failingWriter{err: errBrokerDown}
+				if !failing[topic] {
+					writers[topic] = &recordingWriter{}
+					writer = writers[topic]
+				}
+				publishers = append(publishers, TopicPublisher{Topic: topic, Publisher: newTestProducer(t, config.KafkaConfig{}, writer)})
+			}
+			recorder := &fanoutRecorder{}
+			fanout, err := NewMultiTopicPublisher(publishers, recorder)
+			if err != nil {
+				t.Fatalf("NewMultiTopicPublisher: %v", err)
+			}
+			defer fanout.Close()
+
+			event := newTestEvents(t, 1)[0]
+			err = fanout.Publish(context.Background(), event)
+
+			var fanoutErr *FanoutError
+			switch {
+			case len(tt.failing) == 0 && err != nil:
+				t.Fatalf("Publish() error = %v, want nil", err)
+			case len(tt.failing) > 0 && !errors.As(err, &fanoutErr):
+				t.Fatalf("Publish() error = %v, want *FanoutError", err)
+			}
+			if fanoutErr != nil {
+				wantFailed := append([]string(nil), tt.failing...)
+				sort.Strings(wantFailed)
+				if got := fanoutErr.Topics(); !reflect.DeepEqual(got, wantFailed) {
+					t.Fatalf("failed topics = %v, want %v", got, wantFailed)
+				}
+				if !errors.Is(err, errBrokerDown) {
+					t.Fatalf("Publish() error = %v, want wrapping %v", err, errBrokerDown)
+				}
+			}
+
+			// Ключ сообщения — ID события
+			for topic, writer := range writers {
+				messages := writer.messages()
+				if len(messages) != 1 || string(messages[0].Key) != event.ID {
+					t.Fatalf("topic %s received %d messages, want event %s", topic, len(messages), event.ID)
+				}
+			}
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			sort.Strings(recorder.calls)
+			if !reflect.DeepEqual(recorder.calls, tt.wantMetrics) {
+				t.Fatalf("fan-out metrics = %v, want %v", recorder.calls, tt.wantMetrics)
+			}
+		})
+	}
+}
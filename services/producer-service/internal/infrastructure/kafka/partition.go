@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// partitionHeader заголовок с явно заданной партицией сообщения.
+// kafka.Writer игнорирует Message.Partition, поэтому override читает balancer.
+const partitionHeader = "partition"
+
+// partitionCacheTTL время жизни закэшированного числа партиций топика
+const partitionCacheTTL = time.Minute
+
+// partitionMissTTL время жизни отрицательного результата: ошибки чтения
+// метаданных или override за пределами топика. Чаще метаданные не
+// перечитываются, даже если такие события идут подряд.
+const partitionMissTTL = 5 * time.Second
+
+// partitionOverrideBalancer отправляет сообщение с заголовком partition в указанную
+// партицию, остальные сообщения распределяет исходный balancer
+type partitionOverrideBalancer struct {
+	next kafka.Balancer
+}
+
+// Balance реализует kafka.Balancer
+func (b *partitionOverrideBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	for _, header := range msg.Headers {
+		if header.Key != partitionHeader {
+			continue
+		}
+
+		target, err := strconv.Atoi(string(header.Value))
+		if err != nil {
+			break
+		}
+		for _, partition := range partitions {
+			if partition == target {
+				return target
+			}
+		}
+		break
+	}
+
+	return b.next.Balance(msg, partitions...)
+}
+
+// partitionCache кэширует число партиций топика (или ошибку его чтения)
+// для проверки override
+type partitionCache struct {
+	mu        sync.Mutex
+	count     int
+	err       error
+	fetchedAt time.Time
+}
+
+// validatePartition проверяет, что партиция из метаданных события
+// существует в топике; события без override проходят без проверки
+func (p *Producer) validatePartition(ctx context.Context, event *domain.Event) error {
+	value, ok := event.Metadata[domain.MetadataPartition]
+	if !ok {
+		return nil
+	}
+
+	partition, err := strconv.Atoi(value)
+	if err != nil || partition < 0 {
+		return fmt.Errorf("invalid partition override %q", value)
+	}
+
+	count, err := p.partitionCount(ctx, partition)
+	if err != nil {
+		return err
+	}
+	if partition >= count {
+		return fmt.Errorf("partition %d does not exist in topic %s (%d partitions)", partition, p.topic, count)
+	}
+
+	return nil
+}
+
+// partitionCount возвращает число партиций топика. Кэш обновляется по TTL
+// и досрочно, если запрошенная партиция за его пределами (топик мог вырасти),
+// но не чаще partitionMissTTL. Чтение метаданных ограничено KAFKA_WRITE_TIMEOUT:
+// sender вызывает проверку без дедлайна и держит блокировку кэша.
+func (p *Producer) partitionCount(ctx context.Context, partition int) (int, error) {
+	p.partitions.mu.Lock()
+	defer p.partitions.mu.Unlock()
+
+	if cache := &p.partitions; !cache.fetchedAt.IsZero() {
+		age := time.Since(cache.fetchedAt)
+		switch {
+		case cache.err != nil && age < partitionMissTTL:
+			return 0, cache.err
+		case cache.err == nil && age < partitionCacheTTL && (partition < cache.count || age < partitionMissTTL):
+			return cache.count, nil
+		}
+	}
+
+	if p.config.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.WriteTimeout)
+		defer cancel()
+	}
+
+	partitions, err := p.readPartitions(ctx)
+	p.partitions.count = len(partitions)
+	p.partitions.err = err
+	p.partitions.fetchedAt = time.Now()
+	if err != nil {
+		return 0, err
+	}
+	return p.partitions.count, nil
+}
@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"producer-service/internal/config"
+	"producer-service/internal/domain"
+	"producer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// fixedBalancer всегда выбирает одну партицию
+type fixedBalancer int
+
+func (b fixedBalancer) Balance(kafka.Message, ...int) int { return int(b) }
+
+func TestPartitionOverrideBalancerBalance(t *testing.T) {
+	producer, _ := newReplayProducer(t)
+	balancer := &partitionOverrideBalancer{next: fixedBalancer(0)}
+
+	// Сообщение события с override, собранное так же, как при отправке
+	event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	event.SetMetadata(domain.MetadataPartition, "2")
+	eventMessage := producer.buildMessage(event, []byte(`{}`), "", nil)
+
+	withHeader := func(value string) kafka.Message {
+		return kafka.Message{Headers: []kafka.Header{{Key: partitionHeader, Value: []byte(value)}}}
+	}
+
+	tests := []struct {
+		name       string
+		message    kafka.Message
+		partitions []int
+		want       int
+	}{
+		{name: "event with override", message: eventMessage, partitions: []int{0, 1, 2, 3}, want: 2},
+		{name: "override", message: withHeader("3"), partitions: []int{0, 1, 2, 3}, want: 3},
+		{name: "no override", message: kafka.Message{}, partitions: []int{0, 1, 2, 3}, want: 0},
+		{name: "missing partition", message: withHeader("7"), partitions: []int{0, 1, 2, 3}, want: 0},
+		{name: "not a number", message: withHeader("two"), partitions: []int{0, 1, 2, 3}, want: 0},
+		{name: "negative", message: withHeader("-1"), partitions: []int{0, 1, 2, 3}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := balancer.Balance(tt.message, tt.partitions...); got != tt.want {
+				t.Fatalf("Balance() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePartitionUsesCache(t *testing.T) {
+	// Брокер недоступен: любое чтение метаданных вернуло бы ошибку подключения
+	newProducer := func(t *testing.T) *Producer {
+		t.Helper()
+
+		producer, err := NewProducer(
+			config.KafkaConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "events", WriteTimeout: time.Second},
+			domain.DefaultCodec, discardLogger(), metrics.NewProducerMetrics(prometheus.NewRegistry()),
+		)
+		if err != nil {
+			t.Fatalf("NewProducer: %v", err)
+		}
+		return producer
+	}
+	eventFor := func(t *testing.T, partition string) *domain.Event {
+		t.Helper()
+
+		event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		if partition != "" {
+			event.SetMetadata(domain.MetadataPartition, partition)
+		}
+		return event
+	}
+	errMetadata := errors.New("metadata unavailable")
+
+	// cached=false — кэш пуст; age — возраст закэшированного результата
+	tests := []struct {
+		name      string
+		partition string
+		cached    bool
+		count     int
+		err       error
+		age       time.Duration
+		wantErr   string
+	}{
+		{name: "no override", partition: ""},
+		{name: "invalid override", partition: "x", wantErr: "invalid partition override"},
+		{name: "cached in range", partition: "1", cached: true, count: 3},
+		{name: "cached out of range", partition: "5", cached: true, count: 3, wantErr: "does not exist"},
+		{name: "cached error", partition: "1", cached: true, err: errMetadata, wantErr: errMetadata.Error()},
+		{name: "stale cache refetched", partition: "1", cached: true, count: 3, age: partitionCacheTTL, wantErr: "no kafka broker reachable"},
+		{name: "miss refetched after its TTL", partition: "5", cached: true, count: 3, age: partitionMissTTL, wantErr: "no kafka broker reachable"},
+		{name: "empty cache fetched", partition: "1", wantErr: "no kafka broker reachable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := newProducer(t)
+			if tt.cached {
+				producer.partitions.count = tt.count
+				producer.partitions.err = tt.err
+				producer.partitions.fetchedAt = time.Now().Add(-tt.age)
+			}
+
+			err := producer.validatePartition(context.Background(), eventFor(t, tt.partition))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validatePartition() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("validatePartition() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMurmur2BalancerMatchesJavaPartitioner(t *testing.T) {
+	balancer, err := newBalancer("murmur2")
+	if err != nil {
+		t.Fatalf("newBalancer: %v", err)
+	}
+
+	// Хэши — эталон Java Utils.murmur2 (UtilsTest.testMurmur2); партиция
+	// Java producer'а — Utils.toPositive(hash) % numPartitions
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	tests := []struct {
+		key       string
+		javaHash  int32
+		partition int
+	}{
+		{key: "21", javaHash: -973932308, partition: 0},
+		{key: "foobar", javaHash: -790332482, partition: 6},
+		{key: "a-little-bit-long-string", javaHash: -985981536, partition: 8},
+		{key: "a-little-bit-longer-string", javaHash: -1486304829, partition: 11},
+		{key: "lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", javaHash: -58897971, partition: 5},
+		{key: "abc", javaHash: 479470107, partition: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if want := int(tt.javaHash&0x7fffffff) % len(partitions); want != tt.partition {
+				t.Fatalf("reference partition %d does not match Java toPositive(%d) %% %d = %d", tt.partition, tt.javaHash, len(partitions), want)
+			}
+			if got := balancer.Balance(kafka.Message{Key: []byte(tt.key)}, partitions...); got != tt.partition {
+				t.Fatalf("key %q → partition %d, Java producer uses %d", tt.key, got, tt.partition)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"producer-service/internal/config"
@@ -15,39 +17,88 @@ import (
 
 // ProducerMetrics интерфейс для метрик producer
 type ProducerMetrics interface {
-	IncPublishedEvents(eventType string)
+	IncPublishedEvents(eventType string, confirmed bool)
 	IncFailedEvents(eventType string, reason string)
 	ObservePublishDuration(eventType string, duration time.Duration)
+	ObserveEnqueueToSend(eventType string, latency time.Duration)
+	SetEffectiveBatchSize(size int)
+	IncWorkerPanics(worker string)
+	IncRetryAttempts(eventType string, attempt int)
+	ObserveWriterStats(stats kafka.WriterStats)
+	IncTopicMissing(result string)
+	IncSpoolEvents(result string)
 }
 
 // EventBatch представляет batch событий для отправки
 type EventBatch struct {
-	Events    []*domain.Event
-	Timestamp time.Time
-	ResultCh  chan error
+	Events     []*domain.Event
+	EnqueuedAt []time.Time // время постановки каждого события в eventChan
+	Keys       [][]byte    // ключи сообщений из PublishWithKey; nil — ключ по ID события
+	Timestamp  time.Time
+	ResultCh   chan error
+
+	pooled *[]*domain.Event // слайс Events из eventSlicePool; nil — не из пула
+}
+
+// queuedEvent событие в eventChan с временем постановки в очередь
+// и явным ключом сообщения (nil — ключ по ID события)
+type queuedEvent struct {
+	event      *domain.Event
+	key        []byte
+	enqueuedAt time.Time
 }
 
+// MessageWriter записывает сообщения в Kafka; реализуется *kafka.Writer.
+// Подменяется через SetWriter, чтобы проверять повторы, batch'и и ошибки
+// записи без брокера.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+	Stats() kafka.WriterStats
+}
+
+var _ MessageWriter = (*kafka.Writer)(nil)
+
 // Producer реализует интерфейс EventPublisher с асинхронным батчингом
 type Producer struct {
-	writer  *kafka.Writer
+	writer  MessageWriter
 	topic   string
 	logger  *logrus.Logger
 	metrics ProducerMetrics
+	codec   domain.EventCodec
 	config  config.KafkaConfig
 	mu      sync.RWMutex
 	closed  bool
 	wg      sync.WaitGroup
 
 	// Батчинг
-	eventChan    chan *domain.Event
+	eventChan    chan queuedEvent
 	batchChan    chan *EventBatch
 	batchSize    int
+	batchBytes   int
 	currentBatch []*domain.Event
+	currentQueue []time.Time  // время постановки событий currentBatch
+	currentKeys  [][]byte     // явные ключи событий currentBatch
+	lastFlush    atomic.Int64 // UnixNano последнего сброса batch'а, 0 — сбросов не было
+	currentBytes int
 	batchMu      sync.Mutex
+	adaptive     *batchSizeController
+	async        bool
+
+	// Число партиций топика для проверки partition override
+	partitions partitionCache
+
+	// Журнал аудита опубликованных событий; nil — аудит отключен
+	auditor domain.EventAuditor
+
+	// Локальный спул недоставленных событий; nil — спул отключен
+	spool         *Spool
+	spoolInterval time.Duration
+	spoolStop     chan struct{}
 }
 
 // NewProducer создает новый Kafka producer с асинхронным батчингом
-func NewProducer(cfg config.KafkaConfig, logger *logrus.Logger, metrics ProducerMetrics) (*Producer, error) {
+func NewProducer(cfg config.KafkaConfig, codec domain.EventCodec, logger *logrus.Logger, metrics ProducerMetrics) (*Producer, error) {
 	if len(cfg.Brokers) == 0 {
 		return nil, fmt.Errorf("kafka brokers not configured")
 	}
@@ -72,13 +123,17 @@ func NewProducer(cfg config.KafkaConfig, logger *logrus.Logger, metrics Producer
 	}
 
 	// Настраиваем balancer
-	balancer := &kafka.LeastBytes{}
+	balancer, err := newBalancer(cfg.Balancer)
+	if err != nil {
+		return nil, err
+	}
 
 	writer := &kafka.Writer{
 		Addr:         kafka.TCP(cfg.Brokers...),
 		Topic:        cfg.Topic,
-		Balancer:     balancer,
+		Balancer:     &partitionOverrideBalancer{next: balancer},
 		BatchSize:    cfg.BatchSize,
+		BatchBytes:   int64(cfg.BatchMaxBytes),
 		BatchTimeout: cfg.BatchTimeout,
 		RequiredAcks: kafka.RequiredAcks(cfg.RequiredAcks),
 		Compression:  compression,
@@ -90,29 +145,83 @@ func NewProducer(cfg config.KafkaConfig, logger *logrus.Logger, metrics Producer
 		batchSize = 100 // default batch size
 	}
 
+	batchBytes := cfg.BatchMaxBytes
+	if batchBytes <= 0 {
+		batchBytes = 1048576 // default batch bytes (1MB)
+	}
+
+	// Адаптивный размер batch'а (opt-in): порог меняется в пределах [min, max]
+	var adaptive *batchSizeController
+	queueSize := batchSize * 2
+	if cfg.AdaptiveBatch {
+		if cfg.BatchMinSize <= 0 || cfg.BatchMaxSize < cfg.BatchMinSize {
+			return nil, fmt.Errorf("invalid adaptive batch bounds: min=%d max=%d", cfg.BatchMinSize, cfg.BatchMaxSize)
+		}
+
+		adaptive = &batchSizeController{
+			min:           cfg.BatchMinSize,
+			max:           cfg.BatchMaxSize,
+			targetLatency: cfg.BatchTargetLatency,
+		}
+		batchSize = min(max(batchSize, cfg.BatchMinSize), cfg.BatchMaxSize)
+		queueSize = cfg.BatchMaxSize * 2
+		writer.BatchSize = cfg.BatchMaxSize
+	}
+
 	producer := &Producer{
 		writer:       writer,
 		topic:        cfg.Topic,
 		logger:       logger,
 		metrics:      metrics,
+		codec:        codec,
 		config:       cfg,
-		eventChan:    make(chan *domain.Event, batchSize*2),
-		batchChan:    make(chan *EventBatch, 10),
+		async:        cfg.AsyncBatch,
 		batchSize:    batchSize,
+		batchBytes:   batchBytes,
 		currentBatch: make([]*domain.Event, 0, batchSize),
+		adaptive:     adaptive,
+		spoolStop:    make(chan struct{}),
+	}
+	metrics.SetEffectiveBatchSize(batchSize)
+
+	// В синхронном режиме каналы батчинга не создаются: Publish пишет напрямую
+	if cfg.AsyncBatch {
+		producer.eventChan = make(chan queuedEvent, queueSize)
+		producer.batchChan = make(chan *EventBatch, 10)
 	}
 
 	logger.WithFields(logrus.Fields{
 		"brokers":     cfg.Brokers,
 		"topic":       cfg.Topic,
 		"batch_size":  cfg.BatchSize,
+		"batch_bytes": batchBytes,
 		"compression": cfg.CompressionType,
-		"async_batch": true,
-	}).Info("Kafka producer initialized with async batching")
+		"balancer":    cfg.Balancer,
+		"async_batch": cfg.AsyncBatch,
+		"adaptive":    cfg.AdaptiveBatch,
+	}).Info("Kafka producer initialized")
 
 	return producer, nil
 }
 
+// newBalancer создает стратегию выбора партиции по имени из KAFKA_BALANCER.
+// murmur2 совпадает с партиционированием Java producer'а по ключу, hash — с
+// партиционированием sarama (FNV-1a).
+func newBalancer(name string) (kafka.Balancer, error) {
+	switch name {
+	case "", "least-bytes":
+		return &kafka.LeastBytes{}, nil
+	case "hash":
+		return &kafka.Hash{}, nil
+	case "murmur2":
+		return &kafka.Murmur2Balancer{}, nil
+	case "round-robin":
+		return &kafka.RoundRobin{}, nil
+	default:
+		return nil, fmt.Errorf("unknown kafka balancer: %q", name)
+	}
+}
+
 // Start запускает асинхронные worker'ы для батчинга
 func (p *Producer) Start(ctx context.Context) error {
 	p.mu.Lock()
@@ -122,6 +231,16 @@ func (p *Producer) Start(ctx context.Context) error {
 	}
 	p.mu.Unlock()
 
+	if p.spool != nil {
+		p.wg.Add(1)
+		go p.spoolReplayer(ctx)
+	}
+
+	if !p.async {
+		p.logger.Info("Producer runs in synchronous mode, no batch workers started")
+		return nil
+	}
+
 	p.logger.Info("Starting async batch producer")
 
 	// Запускаем batch collector
@@ -135,122 +254,253 @@ func (p *Producer) Start(ctx context.Context) error {
 	return nil
 }
 
-// batchCollector собирает события в batch'и
+// batchCollector собирает события в batch'и и перезапускает сбор после паники
 func (p *Producer) batchCollector(ctx context.Context) {
 	defer p.wg.Done()
 	defer close(p.batchChan)
 
+	for !p.recoverWorker("collector", func() { p.collectBatches(ctx) }) {
+		p.logger.Warn("Restarting batch collector after panic")
+	}
+}
+
+// collectBatches читает события из eventChan до его закрытия
+func (p *Producer) collectBatches(ctx context.Context) {
 	flushTicker := time.NewTicker(p.config.BatchTimeout)
 	defer flushTicker.Stop()
 
+	// Отмена контекста не останавливает сборщик: события продолжают
+	// собираться, пока Close не закроет eventChan, иначе они потеряются
+	done := ctx.Done()
+
 	for {
 		select {
-		case <-ctx.Done():
-			p.logger.Info("Batch collector context cancelled, flushing final batch")
-			p.flushCurrentBatch()
-			return
+		case <-done:
+			p.logger.Info("Batch collector context cancelled, draining until producer is closed")
+			done = nil
 
-		case event, ok := <-p.eventChan:
+		case queued, ok := <-p.eventChan:
 			if !ok {
 				p.logger.Info("Event channel closed, flushing final batch")
-				p.flushCurrentBatch()
+				p.flushCurrentBatch(true)
 				return
 			}
 
+			event := queued.event
+			size := estimateMessageSize(event) + len(queued.key)
+
+			// Сбрасываем текущий batch, если событие не помещается по размеру
+			p.batchMu.Lock()
+			overflow := len(p.currentBatch) > 0 && p.currentBytes+size > p.batchBytes
+			p.batchMu.Unlock()
+
+			if overflow {
+				p.flushCurrentBatch(false)
+			}
+
 			p.batchMu.Lock()
 			p.currentBatch = append(p.currentBatch, event)
-			shouldFlush := len(p.currentBatch) >= p.batchSize
+			p.currentQueue = append(p.currentQueue, queued.enqueuedAt)
+			p.currentKeys = append(p.currentKeys, queued.key)
+			p.currentBytes += size
+			shouldFlush := len(p.currentBatch) >= p.batchSize || p.currentBytes >= p.batchBytes
 			p.batchMu.Unlock()
 
 			if shouldFlush {
-				p.flushCurrentBatch()
+				p.flushCurrentBatch(false)
 			}
 
 		case <-flushTicker.C:
-			p.flushCurrentBatch()
+			p.flushCurrentBatch(false)
 		}
 	}
 }
 
-// flushCurrentBatch отправляет текущий batch в канал для отправки
-func (p *Producer) flushCurrentBatch() {
+// flushCurrentBatch отправляет текущий batch в канал для отправки.
+// При final=true ожидает места в канале вместо сброса batch'а,
+// чтобы последний batch при остановке гарантированно ушел в sender.
+func (p *Producer) flushCurrentBatch(final bool) {
 	p.batchMu.Lock()
 	if len(p.currentBatch) == 0 {
 		p.batchMu.Unlock()
 		return
 	}
 
+	pooled := getEventSlice(len(p.currentBatch))
+	*pooled = append(*pooled, p.currentBatch...)
 	batch := &EventBatch{
-		Events:    make([]*domain.Event, len(p.currentBatch)),
-		Timestamp: time.Now(),
-		ResultCh:  make(chan error, 1),
+		Events:     *pooled,
+		pooled:     pooled,
+		EnqueuedAt: append([]time.Time(nil), p.currentQueue...),
+		Keys:       append([][]byte(nil), p.currentKeys...),
+		Timestamp:  time.Now(),
+		ResultCh:   make(chan error, 1),
 	}
-	copy(batch.Events, p.currentBatch)
 	p.currentBatch = p.currentBatch[:0] // Очищаем batch
+	p.currentQueue = p.currentQueue[:0]
+	clear(p.currentKeys)
+	p.currentKeys = p.currentKeys[:0]
+	p.lastFlush.Store(batch.Timestamp.UnixNano())
+	p.currentBytes = 0
 	p.batchMu.Unlock()
 
+	// После передачи в batchChan batch принадлежит sender'у: он возвращает
+	// слайс событий в пул, поэтому размер запоминается заранее
+	size := len(batch.Events)
+
+	if final {
+		p.batchChan <- batch
+		p.logger.WithField("batch_size", size).Info("Final batch queued for sending")
+		return
+	}
+
 	select {
 	case p.batchChan <- batch:
-		p.logger.WithField("batch_size", len(batch.Events)).Debug("Batch queued for sending")
+		p.logger.WithField("batch_size", size).Debug("Batch queued for sending")
 	default:
 		p.logger.Warn("Batch channel full, dropping batch")
 		batch.ResultCh <- fmt.Errorf("batch channel full")
 		close(batch.ResultCh)
+		batch.releaseEvents()
 	}
 }
 
+// eventSlicePool переиспользует слайсы событий batch'ей между flush'ами,
+// чтобы не выделять новый слайс на каждый batch при высокой нагрузке.
+// В пуле лежат указатели на слайсы: Put не выделяет память под заголовок.
+var eventSlicePool sync.Pool
+
+// getEventSlice возвращает пустой слайс из пула с емкостью не меньше n
+func getEventSlice(n int) *[]*domain.Event {
+	if pooled, ok := eventSlicePool.Get().(*[]*domain.Event); ok && cap(*pooled) >= n {
+		*pooled = (*pooled)[:0]
+		return pooled
+	}
+	events := make([]*domain.Event, 0, n)
+	return &events
+}
+
+// putEventSlice возвращает слайс в пул. Ссылки на события обнуляются,
+// чтобы пул не удерживал отправленные события от сборки мусора.
+func putEventSlice(events *[]*domain.Event) {
+	clear(*events)
+	*events = (*events)[:0]
+	eventSlicePool.Put(events)
+}
+
+// releaseEvents возвращает слайс событий в пул; после вызова Events равен nil
+func (b *EventBatch) releaseEvents() {
+	if b.pooled == nil {
+		return
+	}
+	*b.pooled = b.Events
+	putEventSlice(b.pooled)
+	b.pooled, b.Events = nil, nil
+}
+
+// estimateMessageSize оценивает размер сообщения Kafka для события:
+// JSON payload, ключ и заголовки с небольшим запасом на разметку
+func estimateMessageSize(event *domain.Event) int {
+	const overhead = 128 // JSON разметка, timestamp и имена заголовков
+
+	fields := len(event.ID) + len(event.Type) + len(event.Version) + len(event.Source)
+	return overhead + len(event.Data) + 2*fields + len(event.ID)
+}
+
 // batchSender отправляет batch'и в Kafka
 func (p *Producer) batchSender(ctx context.Context) {
 	defer p.wg.Done()
 
-	for {
-		select {
-		case <-ctx.Done():
-			p.logger.Info("Batch sender context cancelled")
-			return
+	// Отправка не прерывается отменой ctx: sender дренирует batchChan,
+	// пока collector не закроет его после финального batch'а
+	sendCtx := context.WithoutCancel(ctx)
 
-		case batch, ok := <-p.batchChan:
-			if !ok {
-				p.logger.Info("Batch channel closed")
-				return
-			}
+	// После отправки слайс событий batch'а больше не нужен и уходит в пул
+	for batch := range p.batchChan {
+		p.deliverBatch(sendCtx, batch)
+		batch.releaseEvents()
+	}
 
-			start := time.Now()
-			err := p.sendBatch(ctx, batch.Events)
-			duration := time.Since(start)
-
-			if err != nil {
-				p.logger.WithFields(logrus.Fields{
-					"batch_size": len(batch.Events),
-					"error":      err,
-					"duration":   duration,
-				}).Error("Failed to send batch")
-			} else {
-				p.logger.WithFields(logrus.Fields{
-					"batch_size": len(batch.Events),
-					"duration":   duration,
-				}).Debug("Batch sent successfully")
-			}
+	p.logger.Info("Batch channel closed")
+}
 
-			// Отправляем результат
-			select {
-			case batch.ResultCh <- err:
-			default:
-			}
-			close(batch.ResultCh)
+// deliverBatch отправляет один batch и сообщает результат.
+// Паника при отправке не останавливает sender: batch завершается ошибкой.
+func (p *Producer) deliverBatch(ctx context.Context, batch *EventBatch) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			p.reportPanic("sender", r)
+			err = fmt.Errorf("panic while sending batch: %v", r)
+		}
+
+		select {
+		case batch.ResultCh <- err:
+		default:
+		}
+		close(batch.ResultCh)
+	}()
+
+	start := time.Now()
+	err = p.sendBatch(ctx, batch.Events, batch.Keys)
+	duration := time.Since(start)
+	p.adjustBatchSize(duration)
+
+	if err != nil {
+		p.logger.WithFields(logrus.Fields{
+			"batch_size": len(batch.Events),
+			"error":      err,
+			"duration":   duration,
+		}).Error("Failed to send batch")
+	} else {
+		// Полная задержка публикации: ожидание в очереди, сборка batch'а и запись в Kafka
+		sentAt := time.Now()
+		for i, event := range batch.Events {
+			p.metrics.ObserveEnqueueToSend(string(event.Type), sentAt.Sub(batch.EnqueuedAt[i]))
 		}
+
+		p.logger.WithFields(logrus.Fields{
+			"batch_size": len(batch.Events),
+			"duration":   duration,
+		}).Debug("Batch sent successfully")
 	}
 }
 
-// sendBatch отправляет batch событий в Kafka
-func (p *Producer) sendBatch(ctx context.Context, events []*domain.Event) error {
+// recoverWorker выполняет fn и возвращает false, если fn завершилась паникой
+func (p *Producer) recoverWorker(worker string, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.reportPanic(worker, r)
+			ok = false
+		}
+	}()
+
+	fn()
+	return true
+}
+
+// reportPanic логирует панику worker'а со стеком и учитывает ее в метриках
+func (p *Producer) reportPanic(worker string, r interface{}) {
+	p.metrics.IncWorkerPanics(worker)
+	p.logger.WithFields(logrus.Fields{
+		"worker": worker,
+		"panic":  r,
+		"stack":  string(debug.Stack()),
+	}).Error("Producer worker panicked")
+}
+
+// sendBatch отправляет batch событий в Kafka. keys — явные ключи сообщений
+// по индексу события; nil слайс или элемент — ключ по ID события.
+func (p *Producer) sendBatch(ctx context.Context, events []*domain.Event, keys [][]byte) error {
 	if len(events) == 0 {
 		return nil
 	}
 
 	// Подготавливаем сообщения
 	messages := make([]kafka.Message, 0, len(events))
-	for _, event := range events {
+	sent := make([]*domain.Event, 0, len(events))
+	sentKeys := make([][]byte, 0, len(events))
+	for i, event := range events {
 		// Валидируем событие
 		if err := event.Validate(); err != nil {
 			p.metrics.IncFailedEvents(string(event.Type), "validation_error")
@@ -262,8 +512,18 @@ func (p *Producer) sendBatch(ctx context.Context, events []*domain.Event) error
 			continue
 		}
 
+		if err := p.validatePartition(ctx, event); err != nil {
+			p.metrics.IncFailedEvents(string(event.Type), "validation_error")
+			p.logger.WithFields(logrus.Fields{
+				"event_id":   event.ID,
+				"event_type": event.Type,
+				"error":      err,
+			}).Error("Partition override rejected")
+			continue
+		}
+
 		// Сериализуем событие
-		eventJSON, err := event.ToJSON()
+		eventJSON, dataEncoding, err := p.codec.EncodeMessage(event)
 		if err != nil {
 			p.metrics.IncFailedEvents(string(event.Type), "serialization_error")
 			p.logger.WithFields(logrus.Fields{
@@ -274,18 +534,13 @@ func (p *Producer) sendBatch(ctx context.Context, events []*domain.Event) error
 			continue
 		}
 
-		message := kafka.Message{
-			Key:   []byte(event.ID),
-			Value: eventJSON,
-			Time:  event.Timestamp,
-			Headers: []kafka.Header{
-				{Key: "event-type", Value: []byte(event.Type)},
-				{Key: "event-id", Value: []byte(event.ID)},
-				{Key: "event-version", Value: []byte(event.Version)},
-				{Key: "event-source", Value: []byte(event.Source)},
-			},
+		var key []byte
+		if i < len(keys) {
+			key = keys[i]
 		}
-		messages = append(messages, message)
+		messages = append(messages, p.buildMessage(event, eventJSON, dataEncoding, key))
+		sent = append(sent, event)
+		sentKeys = append(sentKeys, key)
 	}
 
 	if len(messages) == 0 {
@@ -295,6 +550,10 @@ func (p *Producer) sendBatch(ctx context.Context, events []*domain.Event) error
 	// Публикуем batch с retry логикой
 	err := p.publishBatchWithRetry(ctx, messages)
 	if err != nil {
+		// Брокер недоступен: события уходят в спул и будут переиграны позже
+		if p.spoolEvents(sent, sentKeys, err) {
+			return nil
+		}
 		for _, event := range events {
 			p.metrics.IncFailedEvents(string(event.Type), "publish_error")
 		}
@@ -303,20 +562,82 @@ func (p *Producer) sendBatch(ctx context.Context, events []*domain.Event) error
 
 	// Обновляем метрики успеха
 	for _, event := range events {
-		p.metrics.IncPublishedEvents(string(event.Type))
+		p.metrics.IncPublishedEvents(string(event.Type), p.deliveryConfirmed())
+	}
+	for _, event := range sent {
+		p.recordPublished(event)
 	}
 
 	return nil
 }
 
-// Publish публикует событие асинхронно через батчинг
+// dataEncodingHeader заголовок со способом кодирования data (сжатие на уровне приложения)
+const dataEncodingHeader = "data-encoding"
+
+// buildMessage создает сообщение Kafka для сериализованного события.
+// Ключ сообщения — key, а если он пуст — ID события.
+// При KAFKA_USE_EVENT_TIME=false время сообщения назначает брокер,
+// а время события остается только в payload.
+func (p *Producer) buildMessage(event *domain.Event, payload []byte, dataEncoding string, key []byte) kafka.Message {
+	headers := []kafka.Header{
+		{Key: "event-type", Value: []byte(event.Type)},
+		{Key: "event-id", Value: []byte(event.ID)},
+		{Key: "event-version", Value: []byte(event.Version)},
+		{Key: "event-source", Value: []byte(event.Source)},
+	}
+
+	if id, ok := event.Metadata[domain.MetadataCorrelationID]; ok {
+		headers = append(headers, kafka.Header{Key: "correlation-id", Value: []byte(id)})
+	}
+	if id, ok := event.Metadata[domain.MetadataTenantID]; ok {
+		headers = append(headers, kafka.Header{Key: "tenant", Value: []byte(id)})
+	}
+	if partition, ok := event.Metadata[domain.MetadataPartition]; ok {
+		headers = append(headers, kafka.Header{Key: partitionHeader, Value: []byte(partition)})
+	}
+	if dataEncoding != "" {
+		headers = append(headers, kafka.Header{Key: dataEncodingHeader, Value: []byte(dataEncoding)})
+	}
+
+	if len(key) == 0 {
+		key = []byte(event.ID)
+	}
+
+	message := kafka.Message{
+		Key:     key,
+		Value:   payload,
+		Headers: headers,
+	}
+	if p.config.UseEventTime {
+		message.Time = event.Timestamp
+	}
+
+	return message
+}
+
+// Publish публикует событие асинхронно через батчинг; ключ сообщения — ID события
 func (p *Producer) Publish(ctx context.Context, event *domain.Event) error {
+	return p.publish(ctx, event, nil)
+}
+
+// PublishWithKey публикует событие с явным ключом сообщения, например ID
+// пользователя, чтобы его события попадали в одну партицию. Партицию по
+// ключу выбирают балансировщики hash и murmur2; least-bytes и round-robin
+// ключ не учитывают. Пустой key — ключ по ID события, как в Publish.
+func (p *Producer) PublishWithKey(ctx context.Context, event *domain.Event, key []byte) error {
+	return p.publish(ctx, event, key)
+}
+
+// publish ставит событие в batch или пишет его синхронно
+func (p *Producer) publish(ctx context.Context, event *domain.Event, key []byte) error {
+	// Блокировка удерживается до отправки в eventChan, чтобы Close
+	// не закрыл канал между проверкой closed и отправкой
 	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	if p.closed {
-		p.mu.RUnlock()
 		return fmt.Errorf("producer is closed")
 	}
-	p.mu.RUnlock()
 
 	start := time.Now()
 	defer func() {
@@ -330,9 +651,13 @@ func (p *Producer) Publish(ctx context.Context, event *domain.Event) error {
 		return fmt.Errorf("event validation failed: %w", err)
 	}
 
+	if !p.async {
+		return p.publishSync(ctx, event, key)
+	}
+
 	// Отправляем событие в канал для батчинга
 	select {
-	case p.eventChan <- event:
+	case p.eventChan <- queuedEvent{event: event, key: key, enqueuedAt: time.Now()}:
 		p.logger.WithFields(logrus.Fields{
 			"event_id":   event.ID,
 			"event_type": event.Type,
@@ -343,40 +668,39 @@ func (p *Producer) Publish(ctx context.Context, event *domain.Event) error {
 	default:
 		// Канал полный, отправляем синхронно
 		p.logger.Warn("Event channel full, sending synchronously")
-		return p.publishSync(ctx, event)
+		return p.publishSync(ctx, event, key)
 	}
 }
 
 // publishSync отправляет событие синхронно (fallback)
-func (p *Producer) publishSync(ctx context.Context, event *domain.Event) error {
+func (p *Producer) publishSync(ctx context.Context, event *domain.Event, key []byte) error {
+	if err := p.validatePartition(ctx, event); err != nil {
+		p.metrics.IncFailedEvents(string(event.Type), "validation_error")
+		return fmt.Errorf("failed to route event: %w", err)
+	}
+
 	// Сериализуем событие
-	eventJSON, err := event.ToJSON()
+	eventJSON, dataEncoding, err := p.codec.EncodeMessage(event)
 	if err != nil {
 		p.metrics.IncFailedEvents(string(event.Type), "serialization_error")
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
 	// Создаем сообщение Kafka
-	message := kafka.Message{
-		Key:   []byte(event.ID),
-		Value: eventJSON,
-		Time:  event.Timestamp,
-		Headers: []kafka.Header{
-			{Key: "event-type", Value: []byte(event.Type)},
-			{Key: "event-id", Value: []byte(event.ID)},
-			{Key: "event-version", Value: []byte(event.Version)},
-			{Key: "event-source", Value: []byte(event.Source)},
-		},
-	}
+	message := p.buildMessage(event, eventJSON, dataEncoding, key)
 
 	// Публикуем с retry логикой
 	err = p.publishWithRetry(ctx, message)
 	if err != nil {
+		if p.spoolEvents([]*domain.Event{event}, [][]byte{key}, err) {
+			return nil
+		}
 		p.metrics.IncFailedEvents(string(event.Type), "publish_error")
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
-	p.metrics.IncPublishedEvents(string(event.Type))
+	p.metrics.IncPublishedEvents(string(event.Type), p.deliveryConfirmed())
+	p.recordPublished(event)
 	return nil
 }
 
@@ -393,6 +717,11 @@ func (p *Producer) PublishBatch(ctx context.Context, events []*domain.Event) err
 		return nil
 	}
 
+	// Отмененный контекст: не тратим время на валидацию и сериализацию
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("publish batch cancelled: %w", err)
+	}
+
 	start := time.Now()
 	defer func() {
 		duration := time.Since(start)
@@ -403,14 +732,19 @@ func (p *Producer) PublishBatch(ctx context.Context, events []*domain.Event) err
 		}
 	}()
 
-	return p.sendBatch(ctx, events)
+	return p.sendBatch(ctx, events, nil)
 }
 
 // publishWithRetry публикует сообщение с retry логикой
 func (p *Producer) publishWithRetry(ctx context.Context, message kafka.Message) error {
 	var lastErr error
 
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+	for attempt := 0; attempt < p.maxAttempts(); attempt++ {
+		// Не пытаемся писать, если родительский контекст уже отменен
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if attempt > 0 {
 			// Exponential backoff
 			backoff := time.Duration(attempt) * p.config.RetryBackoff
@@ -419,14 +753,22 @@ func (p *Producer) publishWithRetry(ctx context.Context, message kafka.Message)
 				return ctx.Err()
 			case <-time.After(backoff):
 			}
+			p.metrics.IncRetryAttempts(messageEventType(message), attempt)
 		}
 
-		err := p.writer.WriteMessages(ctx, message)
+		err := p.writeMessages(ctx, message)
 		if err == nil {
 			return nil
 		}
 
 		lastErr = err
+		if isTopicMissing(err) {
+			if p.handleTopicMissing(ctx, err) {
+				continue
+			}
+			return fmt.Errorf("%w: %s: %w", domain.ErrTopicNotFound, p.topic, err)
+		}
+
 		p.logger.WithFields(logrus.Fields{
 			"attempt":     attempt + 1,
 			"max_retries": p.config.MaxRetries,
@@ -434,14 +776,56 @@ func (p *Producer) publishWithRetry(ctx context.Context, message kafka.Message)
 		}).Warn("Failed to publish message, retrying")
 	}
 
-	return fmt.Errorf("failed to publish after %d attempts: %w", p.config.MaxRetries+1, lastErr)
+	return fmt.Errorf("failed to publish after %d attempts: %w", p.maxAttempts(), lastErr)
+}
+
+// countBatchRetry учитывает повтор записи batch'а по каждому типу событий в нем
+func (p *Producer) countBatchRetry(messages []kafka.Message, attempt int) {
+	seen := make(map[string]struct{})
+	for _, message := range messages {
+		eventType := messageEventType(message)
+		if _, ok := seen[eventType]; ok {
+			continue
+		}
+		seen[eventType] = struct{}{}
+		p.metrics.IncRetryAttempts(eventType, attempt)
+	}
+}
+
+// messageEventType возвращает тип события из заголовка сообщения
+func messageEventType(message kafka.Message) string {
+	for _, header := range message.Headers {
+		if header.Key == "event-type" {
+			return string(header.Value)
+		}
+	}
+	return "unknown"
+}
+
+// deliveryConfirmed сообщает, подтверждает ли брокер запись (acks != 0)
+func (p *Producer) deliveryConfirmed() bool {
+	return p.config.RequiredAcks != 0
+}
+
+// maxAttempts возвращает число попыток записи. При acks=0 WriteMessages
+// не ждет ответа брокера, поэтому повторы бессмысленны.
+func (p *Producer) maxAttempts() int {
+	if !p.deliveryConfirmed() {
+		return 1
+	}
+	return p.config.MaxRetries + 1
 }
 
 // publishBatchWithRetry публикует batch сообщений с retry логикой
 func (p *Producer) publishBatchWithRetry(ctx context.Context, messages []kafka.Message) error {
 	var lastErr error
 
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+	for attempt := 0; attempt < p.maxAttempts(); attempt++ {
+		// Не пытаемся писать, если родительский контекст уже отменен
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if attempt > 0 {
 			// Exponential backoff
 			backoff := time.Duration(attempt) * p.config.RetryBackoff
@@ -450,14 +834,22 @@ func (p *Producer) publishBatchWithRetry(ctx context.Context, messages []kafka.M
 				return ctx.Err()
 			case <-time.After(backoff):
 			}
+			p.countBatchRetry(messages, attempt)
 		}
 
-		err := p.writer.WriteMessages(ctx, messages...)
+		err := p.writeMessages(ctx, messages...)
 		if err == nil {
 			return nil
 		}
 
 		lastErr = err
+		if isTopicMissing(err) {
+			if p.handleTopicMissing(ctx, err) {
+				continue
+			}
+			return fmt.Errorf("%w: %s: %w", domain.ErrTopicNotFound, p.topic, err)
+		}
+
 		p.logger.WithFields(logrus.Fields{
 			"attempt":     attempt + 1,
 			"max_retries": p.config.MaxRetries,
@@ -466,7 +858,42 @@ func (p *Producer) publishBatchWithRetry(ctx context.Context, messages []kafka.M
 		}).Warn("Failed to publish batch, retrying")
 	}
 
-	return fmt.Errorf("failed to publish batch after %d attempts: %w", p.config.MaxRetries+1, lastErr)
+	return fmt.Errorf("failed to publish batch after %d attempts: %w", p.maxAttempts(), lastErr)
+}
+
+// writeMessages выполняет одну попытку записи. Попытка ограничена
+// KAFKA_WRITE_TIMEOUT независимо от контекста вызывающего: даже с
+// context.Background() запись в зависший брокер не блокирует навсегда,
+// а каждый повтор получает собственный таймаут.
+func (p *Producer) writeMessages(ctx context.Context, messages ...kafka.Message) error {
+	if p.config.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.WriteTimeout)
+		defer cancel()
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+// SetWriter заменяет writer, созданный NewProducer (он еще не открыл соединений
+// и закрывается здесь же). Вызывается до Start.
+func (p *Producer) SetWriter(writer MessageWriter) {
+	if err := p.writer.Close(); err != nil {
+		p.logger.WithError(err).Warn("Failed to close replaced Kafka writer")
+	}
+	p.writer = writer
+}
+
+// SetAuditor включает журнал аудита опубликованных событий.
+// Вызывается до Start.
+func (p *Producer) SetAuditor(auditor domain.EventAuditor) {
+	p.auditor = auditor
+}
+
+// recordPublished записывает опубликованное событие в журнал аудита
+func (p *Producer) recordPublished(event *domain.Event) {
+	if p.auditor != nil {
+		p.auditor.RecordPublished(event)
+	}
 }
 
 // Close закрывает Kafka producer
@@ -481,8 +908,12 @@ func (p *Producer) Close() error {
 	p.closed = true
 	p.logger.Info("Closing Kafka producer")
 
-	// Закрываем канал событий
-	close(p.eventChan)
+	// Закрываем канал событий: collector отправит финальный batch,
+	// а sender дождется его доставки перед завершением
+	if p.async {
+		close(p.eventChan)
+	}
+	close(p.spoolStop)
 
 	// Ждем завершения горутин
 	p.wg.Wait()
@@ -501,3 +932,20 @@ func (p *Producer) Close() error {
 func (p *Producer) Stats() kafka.WriterStats {
 	return p.writer.Stats()
 }
+
+// RunStatsCollector периодически передает статистику writer'а в метрики до отмены ctx.
+// kafka.Writer.Stats сбрасывает счетчики при каждом вызове, поэтому статистику
+// должен читать только один сборщик.
+func (p *Producer) RunStatsCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.metrics.ObserveWriterStats(p.writer.Stats())
+		}
+	}
+}
@@ -0,0 +1,932 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"producer-service/internal/config"
+	"producer-service/internal/domain"
+	"producer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// recordingWriter запоминает записанные сообщения; каждая запись занимает delay.
+// Первые failures записей отклоняются; при failFirst первая запись каждого
+// набора сообщений отклоняется, чтобы batch'и шли через повтор.
+// batches хранит число сообщений в каждой записи, attempts — число вызовов.
+type recordingWriter struct {
+	mu        sync.Mutex
+	delay     time.Duration
+	failures  int
+	attempts  int
+	failFirst bool
+	attempted map[string]bool
+	written   []kafka.Message
+	batches   []int
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts++
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("kafka: leader not available")
+	}
+	if w.failFirst {
+		if w.attempted == nil {
+			w.attempted = make(map[string]bool)
+		}
+		if key := string(msgs[0].Key); !w.attempted[key] {
+			w.attempted[key] = true
+			return errors.New("kafka: request timed out")
+		}
+	}
+	w.written = append(w.written, msgs...)
+	w.batches = append(w.batches, len(msgs))
+	return nil
+}
+
+func (w *recordingWriter) Close() error { return nil }
+
+func (w *recordingWriter) Stats() kafka.WriterStats { return kafka.WriterStats{} }
+
+func (w *recordingWriter) messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.written...)
+}
+
+// newTestProducer создает producer c recordingWriter вместо брокера
+func newTestProducer(t *testing.T, cfg config.KafkaConfig, writer MessageWriter) *Producer {
+	t.Helper()
+
+	cfg.Brokers = []string{"localhost:9092"}
+	cfg.Topic = "events"
+	cfg.RequiredAcks = 1
+	producer, err := NewProducer(cfg, domain.DefaultCodec, discardLogger(), metrics.NewProducerMetrics(prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	producer.SetWriter(writer)
+	return producer
+}
+
+func newTestEvents(t *testing.T, n int) []*domain.Event {
+	t.Helper()
+
+	events := make([]*domain.Event, n)
+	for i := range events {
+		event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		events[i] = event
+	}
+	return events
+}
+
+// metricsRecorder запоминает вызовы метрик, нужные тестам; остальные
+// уходят в настоящие метрики. panicOnEnqueueToSend раз паникует в ObserveEnqueueToSend.
+type metricsRecorder struct {
+	*metrics.ProducerMetrics
+	mu                   sync.Mutex
+	panicOnEnqueueToSend int
+	panics               []string
+	retries              []string
+	topicMissing         []string
+	published            []string
+	failed               []string
+	enqueueToSend        []time.Duration
+	publishDurations     []time.Duration
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{ProducerMetrics: metrics.NewProducerMetrics(prometheus.NewRegistry())}
+}
+
+func (m *metricsRecorder) IncWorkerPanics(worker string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.panics = append(m.panics, worker)
+}
+
+func (m *metricsRecorder) IncRetryAttempts(eventType string, attempt int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries = append(m.retries, eventType+"/"+strconv.Itoa(attempt))
+}
+
+func (m *metricsRecorder) IncPublishedEvents(eventType string, confirmed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.published = append(m.published, eventType+"/"+strconv.FormatBool(confirmed))
+}
+
+func (m *metricsRecorder) IncFailedEvents(eventType, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed = append(m.failed, eventType+"/"+reason)
+}
+
+func (m *metricsRecorder) IncTopicMissing(result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topicMissing = append(m.topicMissing, result)
+}
+
+func (m *metricsRecorder) ObserveEnqueueToSend(eventType string, latency time.Duration) {
+	m.mu.Lock()
+	inject := m.panicOnEnqueueToSend > 0
+	if inject {
+		m.panicOnEnqueueToSend--
+	}
+	m.mu.Unlock()
+	if inject {
+		panic("metrics backend failure")
+	}
+	m.mu.Lock()
+	m.enqueueToSend = append(m.enqueueToSend, latency)
+	m.mu.Unlock()
+	m.ProducerMetrics.ObserveEnqueueToSend(eventType, latency)
+}
+
+func (m *metricsRecorder) ObservePublishDuration(eventType string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishDurations = append(m.publishDurations, duration)
+}
+
+func (m *metricsRecorder) workerPanics() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.panics...)
+}
+
+func (m *metricsRecorder) retryAttempts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.retries...)
+}
+
+// panickingWriter паникует на первых panics записях, затем передает их в recordingWriter
+type panickingWriter struct {
+	recordingWriter
+	panics int
+}
+
+func (w *panickingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	inject := w.panics > 0
+	if inject {
+		w.panics--
+	}
+	w.mu.Unlock()
+	if inject {
+		panic("writer bug")
+	}
+	return w.recordingWriter.WriteMessages(ctx, msgs...)
+}
+
+func TestProducerSenderRecoversFromPanic(t *testing.T) {
+	tests := []struct {
+		name          string
+		writerPanics  int
+		metricsPanics int
+	}{
+		{name: "panic in writer", writerPanics: 1},
+		{name: "panic in metrics call", metricsPanics: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &panickingWriter{panics: tt.writerPanics}
+			producer := newTestProducer(t, config.KafkaConfig{AsyncBatch: true, BatchSize: 1, BatchTimeout: time.Hour}, writer)
+			recorder := newMetricsRecorder()
+			recorder.panicOnEnqueueToSend = tt.metricsPanics
+			producer.metrics = recorder
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			events := newTestEvents(t, 2)
+			if err := producer.Publish(context.Background(), events[0]); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			waitFor(t, 5*time.Second, "recovered sender panic", func() bool { return len(recorder.workerPanics()) == 1 })
+
+			// После паники sender продолжает доставку
+			if err := producer.Publish(context.Background(), events[1]); err != nil {
+				t.Fatalf("Publish after panic: %v", err)
+			}
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			if got := recorder.workerPanics(); !reflect.DeepEqual(got, []string{"sender"}) {
+				t.Fatalf("worker panics = %v, want [sender]", got)
+			}
+			delivered := false
+			for _, message := range writer.messages() {
+				delivered = delivered || string(message.Key) == events[1].ID
+			}
+			if !delivered {
+				t.Fatalf("event published after the panic was not delivered")
+			}
+		})
+	}
+}
+
+func TestProducerCountsRetryAttempts(t *testing.T) {
+	// Writer отклоняет две записи, третья проходит
+	const failures = 2
+
+	tests := []struct {
+		name        string
+		publish     func(p *Producer, events []*domain.Event) error
+		events      int
+		wantRetries []string
+	}{
+		{
+			name:        "single event",
+			publish:     func(p *Producer, events []*domain.Event) error { return p.Publish(context.Background(), events[0]) },
+			events:      1,
+			wantRetries: []string{"user_created/1", "user_created/2"},
+		},
+		{
+			name:        "batch",
+			publish:     func(p *Producer, events []*domain.Event) error { return p.PublishBatch(context.Background(), events) },
+			events:      3,
+			wantRetries: []string{"user_created/1", "user_created/2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{failures: failures}
+			producer := newTestProducer(t, config.KafkaConfig{MaxRetries: 3, RetryBackoff: time.Millisecond}, writer)
+			recorder := newMetricsRecorder()
+			producer.metrics = recorder
+
+			events := newTestEvents(t, tt.events)
+			if err := tt.publish(producer, events); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+			if got := len(writer.messages()); got != tt.events {
+				t.Fatalf("written %d messages, want %d", got, tt.events)
+			}
+			if got := recorder.retryAttempts(); !reflect.DeepEqual(got, tt.wantRetries) {
+				t.Fatalf("retry attempts = %v, want %v", got, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestProducerRetriesWithFakeWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		wantErr       bool
+		wantAttempts  int
+		wantPublished []string
+		wantFailed    []string
+	}{
+		{name: "first write succeeds", wantAttempts: 1, wantPublished: []string{"user_created/true"}},
+		{name: "succeeds after retries", failures: 2, wantAttempts: 3, wantPublished: []string{"user_created/true"}},
+		// MaxRetries 2 — три попытки, все отклонены
+		{name: "retries exhausted", failures: 5, wantErr: true, wantAttempts: 3, wantFailed: []string{"user_created/publish_error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{failures: tt.failures}
+			producer := newTestProducer(t, config.KafkaConfig{MaxRetries: 2, RetryBackoff: time.Millisecond}, writer)
+			recorder := newMetricsRecorder()
+			producer.metrics = recorder
+
+			err := producer.Publish(context.Background(), newTestEvents(t, 1)[0])
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			writer.mu.Lock()
+			attempts := writer.attempts
+			writer.mu.Unlock()
+			if attempts != tt.wantAttempts {
+				t.Fatalf("write attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			if !reflect.DeepEqual(recorder.published, tt.wantPublished) {
+				t.Fatalf("published events = %v, want %v", recorder.published, tt.wantPublished)
+			}
+			if !reflect.DeepEqual(recorder.failed, tt.wantFailed) {
+				t.Fatalf("failed events = %v, want %v", recorder.failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+// stuckWriter зависает на первых stuck записях до отмены контекста
+// и запоминает, сколько длилась каждая попытка
+type stuckWriter struct {
+	recordingWriter
+	stuck    int
+	attempts []time.Duration
+}
+
+func (w *stuckWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	start := time.Now()
+	w.mu.Lock()
+	stuck := len(w.attempts) < w.stuck
+	w.mu.Unlock()
+	if stuck {
+		<-ctx.Done()
+	}
+	w.mu.Lock()
+	w.attempts = append(w.attempts, time.Since(start))
+	w.mu.Unlock()
+	if stuck {
+		return ctx.Err()
+	}
+	return w.recordingWriter.WriteMessages(ctx, msgs...)
+}
+
+func TestProducerWriteTimeoutPerAttempt(t *testing.T) {
+	const writeTimeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		stuck        int
+		wantErr      bool
+		wantAttempts int
+	}{
+		// Каждая попытка получает свой таймаут, а не остаток общего
+		{name: "every attempt times out", stuck: 5, wantErr: true, wantAttempts: 3},
+		{name: "retry after timeout succeeds", stuck: 1, wantAttempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &stuckWriter{stuck: tt.stuck}
+			producer := newTestProducer(t, config.KafkaConfig{MaxRetries: 2, RetryBackoff: time.Millisecond, WriteTimeout: writeTimeout}, writer)
+
+			// Контекст вызывающего без дедлайна не должен блокировать запись навсегда
+			err := producer.Publish(context.Background(), newTestEvents(t, 1)[0])
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Publish() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			writer.mu.Lock()
+			defer writer.mu.Unlock()
+			if len(writer.attempts) != tt.wantAttempts {
+				t.Fatalf("write attempts = %d, want %d", len(writer.attempts), tt.wantAttempts)
+			}
+			for i, took := range writer.attempts {
+				if i >= tt.stuck {
+					break
+				}
+				if took < writeTimeout || took > 10*writeTimeout {
+					t.Fatalf("attempt %d took %v, want about %v", i+1, took, writeTimeout)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncProducerDeliversWithoutStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish func(p *Producer, events []*domain.Event) error
+	}{
+		{
+			name: "publish",
+			publish: func(p *Producer, events []*domain.Event) error {
+				for _, event := range events {
+					if err := p.Publish(context.Background(), event); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "publish with key",
+			publish: func(p *Producer, events []*domain.Event) error {
+				for _, event := range events {
+					if err := p.PublishWithKey(context.Background(), event, []byte(event.ID)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "publish batch",
+			publish: func(p *Producer, events []*domain.Event) error {
+				return p.PublishBatch(context.Background(), events)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{}
+			producer := newTestProducer(t, config.KafkaConfig{AsyncBatch: false}, writer)
+
+			// Start не вызывается: в синхронном режиме запись идет прямо из Publish
+			events := newTestEvents(t, 3)
+			if err := tt.publish(producer, events); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+			written := writer.messages()
+			if len(written) != len(events) {
+				t.Fatalf("written %d messages when publish returned, want %d", len(written), len(events))
+			}
+			for i, message := range written {
+				if string(message.Key) != events[i].ID {
+					t.Fatalf("message %d key = %s, want %s", i, message.Key, events[i].ID)
+				}
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- producer.Close() }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Close blocked on a producer that was never started")
+			}
+			if err := producer.Publish(context.Background(), events[0]); err == nil {
+				t.Fatal("Publish succeeded after Close")
+			}
+		})
+	}
+}
+
+// auditRecorder запоминает ID событий, переданных в журнал аудита
+type auditRecorder struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (a *auditRecorder) RecordPublished(event *domain.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ids = append(a.ids, event.ID)
+}
+
+func TestProducerAuditsPublishedEvents(t *testing.T) {
+	const total = 3
+
+	tests := []struct {
+		name     string
+		async    bool
+		failures int
+		// wantErr — публикация не удалась, и в журнале аудита нет записей
+		wantErr bool
+	}{
+		{name: "sync"},
+		{name: "async", async: true},
+		{name: "sync after retry", failures: 1},
+		{name: "publish failed", failures: 100, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{failures: tt.failures}
+			producer := newTestProducer(t, config.KafkaConfig{
+				AsyncBatch: tt.async, BatchSize: total, BatchTimeout: time.Hour, MaxRetries: 2, RetryBackoff: time.Millisecond,
+			}, writer)
+			auditor := &auditRecorder{}
+			producer.SetAuditor(auditor)
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			events := newTestEvents(t, total)
+			var want []string
+			for _, event := range events {
+				err := producer.Publish(context.Background(), event)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					want = append(want, event.ID)
+				}
+			}
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			auditor.mu.Lock()
+			defer auditor.mu.Unlock()
+			if !reflect.DeepEqual(auditor.ids, want) {
+				t.Fatalf("audited events = %v, want %v", auditor.ids, want)
+			}
+		})
+	}
+}
+
+func TestEnqueueToSendReflectsBatchingDelay(t *testing.T) {
+	const batchTimeout = 200 * time.Millisecond
+
+	tests := []struct {
+		name        string
+		batchSize   int
+		writeDelay  time.Duration
+		wantAtLeast time.Duration
+		wantBelow   time.Duration
+	}{
+		// Единственное событие ждет flush по таймеру
+		{name: "timer flush", batchSize: 100, wantAtLeast: batchTimeout / 2, wantBelow: 5 * batchTimeout},
+		// Полный batch уходит сразу, задержка — только запись
+		{name: "count flush", batchSize: 1, wantBelow: batchTimeout / 2},
+		{name: "slow write", batchSize: 1, writeDelay: batchTimeout, wantAtLeast: batchTimeout, wantBelow: 5 * batchTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{delay: tt.writeDelay}
+			producer := newTestProducer(t, config.KafkaConfig{AsyncBatch: true, BatchSize: tt.batchSize, BatchTimeout: batchTimeout}, writer)
+			recorder := newMetricsRecorder()
+			producer.metrics = recorder
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer producer.Close()
+
+			if err := producer.Publish(context.Background(), newTestEvents(t, 1)[0]); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			waitFor(t, 5*time.Second, "enqueue-to-send observation", func() bool {
+				recorder.mu.Lock()
+				defer recorder.mu.Unlock()
+				return len(recorder.enqueueToSend) == 1
+			})
+
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			latency := recorder.enqueueToSend[0]
+			if latency < tt.wantAtLeast || latency >= tt.wantBelow {
+				t.Fatalf("enqueue-to-send = %v, want in [%v, %v)", latency, tt.wantAtLeast, tt.wantBelow)
+			}
+			// Длительность Publish — только постановка в очередь
+			if len(recorder.publishDurations) != 1 || recorder.publishDurations[0] >= batchTimeout/2 {
+				t.Fatalf("publish durations = %v, want one below %v", recorder.publishDurations, batchTimeout/2)
+			}
+		})
+	}
+}
+
+func TestProducerCloseDeliversQueuedEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		delay     time.Duration
+		events    int
+	}{
+		{name: "only final batch", batchSize: 100, events: 25},
+		{name: "slow writer behind full batches", batchSize: 5, delay: 10 * time.Millisecond, events: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{delay: tt.delay}
+			producer := newTestProducer(t, config.KafkaConfig{
+				AsyncBatch: true, BatchSize: tt.batchSize, BatchTimeout: time.Hour,
+			}, writer)
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			events := newTestEvents(t, tt.events)
+			for _, event := range events {
+				if err := producer.Publish(context.Background(), event); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+			}
+			// Close сразу после постановки в очередь: batch'и еще не отправлены
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			sent := make(map[string]int)
+			for _, message := range writer.messages() {
+				sent[string(message.Key)]++
+			}
+			for _, event := range events {
+				if sent[event.ID] != 1 {
+					t.Fatalf("event %s sent %d times, want 1 (%d of %d messages sent)", event.ID, sent[event.ID], len(sent), len(events))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBatchEventsCopy сравнивает копию событий batch'а из пула с новым слайсом на каждый flush
+func BenchmarkBatchEventsCopy(b *testing.B) {
+	events := make([]*domain.Event, 100)
+
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			batch := getEventSlice(len(events))
+			*batch = append(*batch, events...)
+			putEventSlice(batch)
+		}
+	})
+	b.Run("fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		var batch []*domain.Event
+		for range b.N {
+			batch = append([]*domain.Event(nil), events...)
+		}
+		_ = batch
+	})
+}
+
+// TestProducerPooledBatchesKeepEvents проверяет, что слайс событий batch'а
+// возвращается в пул только после повторов и записи в спул: иначе следующий
+// flush перезаписал бы события, которые еще отправляются
+func TestProducerPooledBatchesKeepEvents(t *testing.T) {
+	// Не больше batch'ей, чем вмещает batchChan: переполнение сбрасывает batch
+	const total, batchSize = 100, 10
+
+	tests := []struct {
+		name   string
+		writer MessageWriter
+		spool  bool
+	}{
+		{name: "retry path", writer: &recordingWriter{failFirst: true}},
+		{name: "spool path", writer: &outageWriter{down: true}, spool: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := newTestProducer(t, config.KafkaConfig{
+				AsyncBatch: true, BatchSize: batchSize, BatchTimeout: time.Hour, MaxRetries: 1,
+			}, tt.writer)
+			var spool *Spool
+			if tt.spool {
+				spool = openSpool(t, 1<<20)
+				producer.SetSpool(spool, time.Hour)
+			}
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			// Ключ сообщения — номер события: он должен совпасть с ID в payload
+			ids := make(map[string]string, total)
+			for i, event := range newTestEvents(t, total) {
+				key := strconv.Itoa(i)
+				ids[key] = event.ID
+				if err := producer.PublishWithKey(context.Background(), event, []byte(key)); err != nil {
+					t.Fatalf("PublishWithKey: %v", err)
+				}
+			}
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			delivered := make(map[string]string, total)
+			record := func(key []byte, payload []byte) {
+				var event struct {
+					ID string `json:"id"`
+				}
+				if err := json.Unmarshal(payload, &event); err != nil {
+					t.Fatalf("decode payload: %v", err)
+				}
+				if _, dup := delivered[string(key)]; dup {
+					t.Fatalf("key %s delivered twice", key)
+				}
+				delivered[string(key)] = event.ID
+			}
+			if tt.spool {
+				spool.mu.Lock()
+				records, err := spool.readAll()
+				spool.mu.Unlock()
+				if err != nil {
+					t.Fatalf("read spool: %v", err)
+				}
+				for _, r := range records {
+					record(r.Key, r.Event)
+				}
+			} else {
+				for _, message := range tt.writer.(*recordingWriter).messages() {
+					record(message.Key, message.Value)
+				}
+			}
+
+			if len(delivered) != total {
+				t.Fatalf("delivered %d events, want %d", len(delivered), total)
+			}
+			for key, id := range ids {
+				if delivered[key] != id {
+					t.Fatalf("key %s carries event %s, want %s", key, delivered[key], id)
+				}
+			}
+		})
+	}
+}
+
+func TestProducerFlushesOnBatchBytes(t *testing.T) {
+	const total, batchSize, batchBytes = 12, 10, 5000
+
+	tests := []struct {
+		name        string
+		data        string
+		wantBatches []int
+	}{
+		// Событие ~1.3 КБ: в batch помещаются 3 события, задолго до порога по числу
+		{name: "large events flush on bytes", data: `{"blob":"` + strings.Repeat("x", 1000) + `"}`, wantBatches: []int{3, 3, 3, 3}},
+		{name: "small events flush on count", data: `{"id":1}`, wantBatches: []int{10, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{}
+			producer := newTestProducer(t, config.KafkaConfig{
+				AsyncBatch: true, BatchSize: batchSize, BatchMaxBytes: batchBytes, BatchTimeout: time.Hour,
+			}, writer)
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			for i := 0; i < total; i++ {
+				event, err := domain.NewEvent(domain.UserCreatedEvent, tt.data)
+				if err != nil {
+					t.Fatalf("NewEvent: %v", err)
+				}
+				if err := producer.Publish(context.Background(), event); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+			}
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			writer.mu.Lock()
+			defer writer.mu.Unlock()
+			if !reflect.DeepEqual(writer.batches, tt.wantBatches) {
+				t.Fatalf("written batches = %v, want %v", writer.batches, tt.wantBatches)
+			}
+		})
+	}
+}
+
+func TestPublishWithKeyOnTheWire(t *testing.T) {
+	tests := []struct {
+		name  string
+		async bool
+		key   []byte
+	}{
+		{name: "sync custom key", key: []byte("user-42")},
+		{name: "batched custom key", async: true, key: []byte("user-42")},
+		{name: "sync empty key falls back to event ID"},
+		{name: "batched empty key falls back to event ID", async: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{}
+			producer := newTestProducer(t, config.KafkaConfig{
+				AsyncBatch: tt.async, BatchSize: 10, BatchTimeout: time.Hour,
+			}, writer)
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			event := newTestEvents(t, 1)[0]
+			if err := producer.PublishWithKey(context.Background(), event, tt.key); err != nil {
+				t.Fatalf("PublishWithKey: %v", err)
+			}
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			messages := writer.messages()
+			if len(messages) != 1 {
+				t.Fatalf("wrote %d messages, want 1", len(messages))
+			}
+			want := tt.key
+			if len(want) == 0 {
+				want = []byte(event.ID)
+			}
+			if string(messages[0].Key) != string(want) {
+				t.Fatalf("message key = %q, want %q", messages[0].Key, want)
+			}
+		})
+	}
+}
+
+func TestProducerCompressedDataOnTheWire(t *testing.T) {
+	data := `{"items":[` + strings.Repeat(`{"sku":"SKU-1","qty":1},`, 200) + `{"sku":"SKU-2","qty":2}]}`
+	codec := domain.EventCodec{Compression: domain.DataEncodingGzip, CompressionMinBytes: 1024}
+
+	tests := []struct {
+		name         string
+		async        bool
+		data         string
+		wantEncoding string
+	}{
+		{name: "sync large data", data: data, wantEncoding: domain.DataEncodingGzip},
+		{name: "batched large data", async: true, data: data, wantEncoding: domain.DataEncodingGzip},
+		{name: "small data without header", data: `{"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{}
+			producer := newTestProducer(t, config.KafkaConfig{AsyncBatch: tt.async, BatchSize: 10, BatchTimeout: time.Hour}, writer)
+			producer.codec = codec
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			event, err := domain.NewEvent(domain.UserCreatedEvent, tt.data)
+			if err != nil {
+				t.Fatalf("NewEvent: %v", err)
+			}
+			if err := producer.Publish(context.Background(), event); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if err := producer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			messages := writer.messages()
+			if len(messages) != 1 {
+				t.Fatalf("wrote %d messages, want 1", len(messages))
+			}
+			var dataEncoding string
+			for _, header := range messages[0].Headers {
+				if header.Key == dataEncodingHeader {
+					dataEncoding = string(header.Value)
+				}
+			}
+			if dataEncoding != tt.wantEncoding {
+				t.Fatalf("data-encoding header = %q, want %q", dataEncoding, tt.wantEncoding)
+			}
+
+			decoded, err := domain.DefaultCodec.DecodeMessage(messages[0].Value, dataEncoding)
+			if err != nil {
+				t.Fatalf("DecodeMessage: %v", err)
+			}
+			if decoded.Data != tt.data {
+				t.Fatalf("decoded data differs from the published data")
+			}
+		})
+	}
+}
+
+func TestDebugSnapshotReflectsBufferedEvents(t *testing.T) {
+	tests := []struct {
+		name          string
+		async         bool
+		publish       int
+		wantBatch     int
+		wantFlushed   bool
+		wantQueueCaps [2]int // емкость eventChan и batchChan
+	}{
+		{name: "sync mode has no queues", publish: 2},
+		{name: "events wait in current batch", async: true, publish: 3, wantBatch: 3, wantQueueCaps: [2]int{8, 10}},
+		{name: "full batch flushed", async: true, publish: 5, wantBatch: 1, wantFlushed: true, wantQueueCaps: [2]int{8, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := newTestProducer(t, config.KafkaConfig{AsyncBatch: tt.async, BatchSize: 4, BatchTimeout: time.Hour}, &recordingWriter{})
+			if err := producer.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer producer.Close()
+
+			for _, event := range newTestEvents(t, tt.publish) {
+				if err := producer.Publish(context.Background(), event); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+			}
+
+			// Collector переносит события из очереди в batch асинхронно
+			waitFor(t, time.Second, "events to reach the current batch", func() bool {
+				return producer.DebugSnapshot().CurrentBatchSize == tt.wantBatch
+			})
+			snapshot := producer.DebugSnapshot()
+			if snapshot.Async != tt.async || snapshot.BatchThreshold != 4 || snapshot.EventQueueLength != 0 {
+				t.Fatalf("snapshot = %+v, want async=%v, threshold 4 and an empty event queue", snapshot, tt.async)
+			}
+			if caps := [2]int{snapshot.EventQueueCap, snapshot.BatchQueueCap}; caps != tt.wantQueueCaps {
+				t.Fatalf("queue capacities = %v, want %v", caps, tt.wantQueueCaps)
+			}
+			if flushed := snapshot.LastFlush != nil; flushed != tt.wantFlushed {
+				t.Fatalf("last flush = %v, want flushed=%v", snapshot.LastFlush, tt.wantFlushed)
+			}
+			if (snapshot.CurrentBatchBytes > 0) != (tt.wantBatch > 0) {
+				t.Fatalf("current batch bytes = %d with %d buffered events", snapshot.CurrentBatchBytes, tt.wantBatch)
+			}
+		})
+	}
+}
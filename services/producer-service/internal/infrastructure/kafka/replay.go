@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReplayEvents повторно публикует исторические события с заданной частотой
+// (событий в секунду). Используется в нагрузочных тестах и для воспроизведения
+// ошибок. Останавливается при отмене контекста или первой ошибке публикации.
+func (p *Producer) ReplayEvents(ctx context.Context, events []*domain.Event, rate float64) error {
+	// !(rate > 0) отсекает и NaN
+	if !(rate > 0) {
+		return fmt.Errorf("replay rate must be positive, got %v", rate)
+	}
+	// При частоте выше 1e9 событий в секунду интервал округляется до нуля,
+	// а time.NewTicker(0) паникует
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval <= 0 {
+		return fmt.Errorf("replay rate %v exceeds one event per nanosecond", rate)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for i, event := range events {
+		// Первое событие публикуем сразу, остальные — по тикам
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("replay cancelled after %d of %d events: %w", i, len(events), ctx.Err())
+			case <-ticker.C:
+			}
+		}
+
+		if err := p.Publish(ctx, event); err != nil {
+			return fmt.Errorf("replay failed at event %d of %d (%s): %w", i+1, len(events), event.ID, err)
+		}
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"events":   len(events),
+		"rate":     rate,
+		"duration": time.Since(start),
+	}).Info("Events replayed")
+
+	return nil
+}
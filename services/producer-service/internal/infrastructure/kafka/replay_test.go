@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"producer-service/internal/config"
+	"producer-service/internal/domain"
+	"producer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newReplayProducer(t *testing.T) (*Producer, *outageWriter) {
+	t.Helper()
+
+	producer, err := NewProducer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", RequiredAcks: 1},
+		domain.DefaultCodec, discardLogger(), metrics.NewProducerMetrics(prometheus.NewRegistry()),
+	)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	writer := &outageWriter{}
+	producer.SetWriter(writer)
+	return producer, writer
+}
+
+func TestReplayEventsRate(t *testing.T) {
+	producer, writer := newReplayProducer(t)
+
+	events := make([]*domain.Event, 6)
+	for i := range events {
+		event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		events[i] = event
+	}
+
+	// Первое событие уходит сразу, остальные пять — по тикам в 20ms
+	start := time.Now()
+	if err := producer.ReplayEvents(context.Background(), events, 50); err != nil {
+		t.Fatalf("ReplayEvents: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if len(writer.written) != len(events) {
+		t.Fatalf("published %d events, want %d", len(writer.written), len(events))
+	}
+	if want := 100 * time.Millisecond; elapsed < want-10*time.Millisecond || elapsed > want+400*time.Millisecond {
+		t.Fatalf("replay took %s, want about %s", elapsed, want)
+	}
+}
+
+func TestReplayEventsRejectsInvalidRate(t *testing.T) {
+	producer, _ := newReplayProducer(t)
+	event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+
+	for _, rate := range []float64{0, -1, math.NaN(), 2e9, math.Inf(1)} {
+		if err := producer.ReplayEvents(context.Background(), []*domain.Event{event, event}, rate); err == nil {
+			t.Errorf("ReplayEvents(rate=%v) succeeded, want error", rate)
+		}
+	}
+}
+
+func TestReplayEventsStopsOnCancel(t *testing.T) {
+	producer, writer := newReplayProducer(t)
+	event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := producer.ReplayEvents(ctx, []*domain.Event{event, event, event}, 1); err == nil {
+		t.Fatal("ReplayEvents ignored context cancellation")
+	}
+	if len(writer.written) != 1 {
+		t.Fatalf("published %d events before cancellation, want 1", len(writer.written))
+	}
+}
@@ -0,0 +1,332 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrSpoolFull спул достиг KAFKA_SPOOL_MAX_BYTES, новые события не принимаются
+var ErrSpoolFull = errors.New("spool is full")
+
+// spoolRecord строка спула: событие в формате DefaultCodec и явный ключ сообщения
+type spoolRecord struct {
+	Key   []byte          `json:"key,omitempty"`
+	Event json.RawMessage `json:"event"`
+}
+
+// Spool локальный append-only файл для событий, которые не удалось записать
+// в Kafka после всех повторов (например, на edge-узлах с нестабильной связью).
+// Producer периодически переигрывает спул в Kafka и очищает его.
+// Одна JSON строка на событие; размер файла ограничен maxBytes.
+type Spool struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxBytes int64
+	logger   *logrus.Logger
+}
+
+// NewSpool открывает спул по пути path. События, оставшиеся в файле
+// после прошлого запуска, будут переиграны.
+func NewSpool(path string, maxBytes int64, logger *logrus.Logger) (*Spool, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("spool max bytes must be positive, got %d", maxBytes)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat spool: %w", err)
+	}
+
+	return &Spool{
+		path:     path,
+		file:     file,
+		size:     info.Size(),
+		maxBytes: maxBytes,
+		logger:   logger,
+	}, nil
+}
+
+// append дописывает записи в спул целиком или не дописывает ничего
+func (s *Spool) append(records []spoolRecord) error {
+	var buf []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode spool record: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+int64(len(buf)) > s.maxBytes {
+		return ErrSpoolFull
+	}
+	n, err := s.file.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write spool: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+	return nil
+}
+
+// replay передает записи спула в send порциями по batch штук. Отправленные
+// записи удаляются из файла; при ошибке send в спуле остаются неотправленные.
+// Поврежденные строки пропускаются. Блокировка не держится во время send:
+// запись в Kafka с повторами может идти долго, а append в это время должен
+// принимать новые недоставленные события. Вызывается из одной горутины.
+func (s *Spool) replay(batch int, send func([]spoolRecord) error) (replayed int, err error) {
+	s.mu.Lock()
+	if s.size == 0 {
+		s.mu.Unlock()
+		return 0, nil
+	}
+	records, err := s.readAll()
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	for replayed < len(records) {
+		end := min(replayed+batch, len(records))
+		if err = send(records[replayed:end]); err != nil {
+			break
+		}
+		replayed = end
+	}
+
+	if dropErr := s.dropPrefix(replayed); dropErr != nil {
+		return replayed, errors.Join(err, dropErr)
+	}
+	return replayed, err
+}
+
+// dropPrefix удаляет n первых записей спула. Файл за время отправки
+// только дописывался, поэтому его первые n записей — отправленные,
+// а дописанные после снимка сохраняются.
+func (s *Spool) dropPrefix(n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	return s.rewrite(records[min(n, len(records)):])
+}
+
+// readAll читает все записи спула
+func (s *Spool) readAll() ([]spoolRecord, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read spool: %w", err)
+	}
+
+	var records []spoolRecord
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(make([]byte, 64*1024), int(s.maxBytes))
+	for scanner.Scan() {
+		var record spoolRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			s.logger.WithError(err).Warn("Skipping corrupted spool record")
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spool: %w", err)
+	}
+	return records, nil
+}
+
+// rewrite заменяет содержимое спула записями records через временный файл,
+// чтобы сбой посреди записи не повредил спул
+func (s *Spool) rewrite(records []spoolRecord) error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to rewrite spool: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to reopen spool: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat spool: %w", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// Close закрывает файл спула; неотправленные события остаются в нем до следующего запуска
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// SetSpool включает спул для событий, не записанных в Kafka после всех
+// повторов, и его переигрывание раз в interval. Вызывается до Start.
+func (p *Producer) SetSpool(spool *Spool, interval time.Duration) {
+	p.spool = spool
+	p.spoolInterval = interval
+}
+
+// spoolEvents сохраняет неотправленные события в спул. Возвращает true,
+// если события сохранены и публикацию можно считать принятой.
+func (p *Producer) spoolEvents(events []*domain.Event, keys [][]byte, cause error) bool {
+	if p.spool == nil {
+		return false
+	}
+
+	records := make([]spoolRecord, 0, len(events))
+	for i, event := range events {
+		payload, err := event.ToJSON()
+		if err != nil {
+			return false
+		}
+		records = append(records, spoolRecord{Key: keys[i], Event: payload})
+	}
+
+	if err := p.spool.append(records); err != nil {
+		for range events {
+			p.metrics.IncSpoolEvents("dropped")
+		}
+		p.logger.WithFields(logrus.Fields{
+			"events": len(events),
+			"cause":  cause,
+			"error":  err,
+		}).Error("Failed to spool unpublished events")
+		return false
+	}
+
+	for range events {
+		p.metrics.IncSpoolEvents("spooled")
+	}
+	p.logger.WithFields(logrus.Fields{
+		"events": len(events),
+		"cause":  cause,
+	}).Warn("Kafka unavailable, events spooled to disk")
+	return true
+}
+
+// spoolReplayer периодически переигрывает спул в Kafka до остановки producer'а
+func (p *Producer) spoolReplayer(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.spoolInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.spoolStop:
+			return
+		case <-ticker.C:
+			p.replaySpool(ctx)
+		}
+	}
+}
+
+// replaySpool отправляет события спула в Kafka batch'ами текущего размера
+func (p *Producer) replaySpool(ctx context.Context) {
+	replayed, err := p.spool.replay(p.effectiveBatchSize(), func(records []spoolRecord) error {
+		events := make([]*domain.Event, 0, len(records))
+		messages := make([]kafka.Message, 0, len(records))
+		for _, record := range records {
+			event, err := domain.FromJSON(record.Event)
+			if err != nil {
+				p.logger.WithError(err).Warn("Skipping undecodable spooled event")
+				continue
+			}
+			payload, dataEncoding, err := p.codec.EncodeMessage(event)
+			if err != nil {
+				p.logger.WithError(err).WithField("event_id", event.ID).Warn("Skipping unencodable spooled event")
+				continue
+			}
+			events = append(events, event)
+			messages = append(messages, p.buildMessage(event, payload, dataEncoding, record.Key))
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		if err := p.publishBatchWithRetry(ctx, messages); err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			p.metrics.IncSpoolEvents("replayed")
+			p.metrics.IncPublishedEvents(string(event.Type), p.deliveryConfirmed())
+			p.recordPublished(event)
+		}
+		return nil
+	})
+
+	if replayed > 0 {
+		p.logger.WithField("events", replayed).Info("Replayed spooled events to Kafka")
+	}
+	if err != nil {
+		p.logger.WithError(err).Warn("Spool replay interrupted, will retry")
+	}
+}
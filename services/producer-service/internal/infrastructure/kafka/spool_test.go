@@ -0,0 +1,278 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"producer-service/internal/config"
+	"producer-service/internal/domain"
+	"producer-service/internal/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func openSpool(t *testing.T, maxBytes int64) *Spool {
+	t.Helper()
+
+	spool, err := NewSpool(filepath.Join(t.TempDir(), "events.spool"), maxBytes, discardLogger())
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	t.Cleanup(func() { spool.Close() })
+	return spool
+}
+
+func spoolKeys(records []spoolRecord) []string {
+	keys := make([]string, 0, len(records))
+	for _, record := range records {
+		keys = append(keys, string(record.Key))
+	}
+	return keys
+}
+
+func TestSpoolReplayKeepsUnsentAndAppendedRecords(t *testing.T) {
+	spool := openSpool(t, 1<<20)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := spool.append([]spoolRecord{{Key: []byte(key), Event: []byte(`{}`)}}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// Первый batch уходит, второй падает; во время отправки дописывается новая запись,
+	// что заодно проверяет, что append не ждет окончания replay
+	var sent []string
+	replayed, err := spool.replay(2, func(records []spoolRecord) error {
+		if len(sent) > 0 {
+			return errors.New("broker unavailable")
+		}
+		if err := spool.append([]spoolRecord{{Key: []byte("d"), Event: []byte(`{}`)}}); err != nil {
+			t.Fatalf("append during replay: %v", err)
+		}
+		sent = append(sent, spoolKeys(records)...)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("replay returned no error for a failed batch")
+	}
+	if replayed != 2 {
+		t.Fatalf("replayed = %d, want 2", replayed)
+	}
+
+	sent = nil
+	replayed, err = spool.replay(10, func(records []spoolRecord) error {
+		sent = append(sent, spoolKeys(records)...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed != 2 || len(sent) != 2 || sent[0] != "c" || sent[1] != "d" {
+		t.Fatalf("second replay sent %v (%d), want [c d]", sent, replayed)
+	}
+	if spool.size != 0 {
+		t.Fatalf("spool size after full replay = %d, want 0", spool.size)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	spool := openSpool(t, 64)
+
+	record := spoolRecord{Key: []byte("k"), Event: []byte(`{"id":"0123456789"}`)}
+	if err := spool.append([]spoolRecord{record}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := spool.append([]spoolRecord{record, record}); !errors.Is(err, ErrSpoolFull) {
+		t.Fatalf("append over the limit returned %v, want ErrSpoolFull", err)
+	}
+}
+
+// outageWriter отклоняет запись, пока down == true, и запоминает записанные сообщения
+type outageWriter struct {
+	mu      sync.Mutex
+	down    bool
+	written []kafka.Message
+}
+
+func (w *outageWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.down {
+		return errors.New("kafka: broker not available")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *outageWriter) Close() error { return nil }
+
+func (w *outageWriter) Stats() kafka.WriterStats { return kafka.WriterStats{} }
+
+func TestProducerSpoolsDuringOutageAndReplays(t *testing.T) {
+	producer, err := NewProducer(
+		config.KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", RequiredAcks: 1, MaxRetries: 1},
+		domain.DefaultCodec, discardLogger(), metrics.NewProducerMetrics(prometheus.NewRegistry()),
+	)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	writer := &outageWriter{down: true}
+	producer.SetWriter(writer)
+	spool := openSpool(t, 1<<20)
+	producer.SetSpool(spool, 0)
+
+	event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := producer.PublishWithKey(ctx, event, []byte("user-1")); err != nil {
+		t.Fatalf("Publish during outage: %v, want the event spooled", err)
+	}
+	if len(writer.written) != 0 || spool.size == 0 {
+		t.Fatalf("event not spooled: written=%d spool size=%d", len(writer.written), spool.size)
+	}
+
+	// Kafka вернулась: replay доставляет событие с исходным ключом и очищает спул
+	writer.down = false
+	producer.replaySpool(ctx)
+
+	if len(writer.written) != 1 {
+		t.Fatalf("replay wrote %d messages, want 1", len(writer.written))
+	}
+	if got := string(writer.written[0].Key); got != "user-1" {
+		t.Fatalf("replayed key = %q, want user-1", got)
+	}
+	replayed, err := domain.FromJSON(writer.written[0].Value)
+	if err != nil {
+		t.Fatalf("decode replayed event: %v", err)
+	}
+	if replayed.ID != event.ID {
+		t.Fatalf("replayed event ID = %q, want %q", replayed.ID, event.ID)
+	}
+	if spool.size != 0 {
+		t.Fatalf("spool size after replay = %d, want 0", spool.size)
+	}
+}
+
+// spooledRecords возвращает число записей в спуле
+func spooledRecords(t *testing.T, spool *Spool) int {
+	t.Helper()
+
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+	records, err := spool.readAll()
+	if err != nil {
+		t.Fatalf("read spool: %v", err)
+	}
+	return len(records)
+}
+
+// waitFor ждет выполнения условия не дольше timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAsyncProducerSpoolsFailedBatchAndReplays(t *testing.T) {
+	// Адаптивный батчинг меняет размер batch'а в sender'е одновременно
+	// с replay спула: тест также проверяет отсутствие гонки под -race
+	producer, err := NewProducer(
+		config.KafkaConfig{
+			Brokers:            []string{"localhost:9092"},
+			Topic:              "events",
+			RequiredAcks:       1,
+			AsyncBatch:         true,
+			BatchSize:          2,
+			BatchTimeout:       5 * time.Millisecond,
+			AdaptiveBatch:      true,
+			BatchMinSize:       1,
+			BatchMaxSize:       4,
+			BatchTargetLatency: time.Nanosecond,
+		},
+		domain.DefaultCodec, discardLogger(), metrics.NewProducerMetrics(prometheus.NewRegistry()),
+	)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	writer := &outageWriter{down: true}
+	producer.SetWriter(writer)
+	spool := openSpool(t, 1<<20)
+	producer.SetSpool(spool, 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := producer.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	const total = 5
+	ids := make(map[string]bool, total)
+	for i := 0; i < total; i++ {
+		event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		ids[event.ID] = true
+		if err := producer.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	// publishBatchWithRetry падает: все события batch'ей оказываются в спуле
+	waitFor(t, 5*time.Second, "events to be spooled", func() bool {
+		return spooledRecords(t, spool) == total
+	})
+
+	writer.mu.Lock()
+	if len(writer.written) != 0 {
+		t.Fatalf("%d messages written during the outage", len(writer.written))
+	}
+	writer.down = false
+	writer.mu.Unlock()
+
+	// Kafka вернулась: replayer доставляет события и очищает спул
+	waitFor(t, 5*time.Second, "spooled events to be replayed", func() bool {
+		writer.mu.Lock()
+		defer writer.mu.Unlock()
+		return len(writer.written) == total
+	})
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, message := range writer.written {
+		event, err := domain.FromJSON(message.Value)
+		if err != nil {
+			t.Fatalf("decode replayed event: %v", err)
+		}
+		if !ids[event.ID] {
+			t.Fatalf("replayed unknown or duplicate event %q", event.ID)
+		}
+		delete(ids, event.ID)
+	}
+	if n := spooledRecords(t, spool); n != 0 {
+		t.Fatalf("%d records left in spool after replay", n)
+	}
+}
@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Исходы обработки пропавшего топика для метрики producer_topic_missing_total
+const (
+	topicMissingFailed         = "failed"
+	topicMissingRecreated      = "recreated"
+	topicMissingRecreateFailed = "recreate_failed"
+)
+
+// isTopicMissing сообщает, что запись не удалась из-за отсутствия топика.
+// Повторять такую запись бессмысленно: брокер отвечает той же ошибкой,
+// пока топик не будет создан заново.
+func isTopicMissing(err error) bool {
+	if errors.Is(err, kafka.UnknownTopicOrPartition) {
+		return true
+	}
+
+	// WriteMessages возвращает ошибки batch'а по сообщениям
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		for _, writeErr := range writeErrs {
+			if errors.Is(writeErr, kafka.UnknownTopicOrPartition) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// handleTopicMissing обрабатывает пропажу топика во время записи. Возвращает
+// true, если топик пересоздан и запись можно повторить; иначе запись
+// должна завершиться ошибкой без оставшихся попыток.
+func (p *Producer) handleTopicMissing(ctx context.Context, err error) bool {
+	logger := p.logger.WithFields(logrus.Fields{
+		"topic": p.topic,
+		"error": err,
+	})
+
+	if !p.config.AutoCreateTopic {
+		p.metrics.IncTopicMissing(topicMissingFailed)
+		logger.Error("Kafka topic does not exist, dropping write without retries")
+		return false
+	}
+
+	if createErr := p.createTopic(ctx); createErr != nil {
+		p.metrics.IncTopicMissing(topicMissingRecreateFailed)
+		logger.WithField("create_error", createErr).Error("Kafka topic does not exist and could not be recreated")
+		return false
+	}
+
+	p.metrics.IncTopicMissing(topicMissingRecreated)
+	logger.Warn("Kafka topic was missing and has been recreated")
+	return true
+}
+
+// createTopic создает топик через controller брокер. Уже существующий топик
+// (например, созданный соседней репликой) считается успехом.
+func (p *Producer) createTopic(ctx context.Context) error {
+	// Пересоздание идет внутри повторов записи и ограничено тем же KAFKA_WRITE_TIMEOUT
+	if p.config.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.WriteTimeout)
+		defer cancel()
+	}
+
+	var conn *kafka.Conn
+	var err error
+	for _, broker := range p.config.Brokers {
+		if conn, err = dialWithDeadline(ctx, broker); err == nil {
+			break
+		}
+	}
+	if conn == nil {
+		return fmt.Errorf("failed to dial any broker: %w", err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("failed to get controller: %w", err)
+	}
+
+	controllerConn, err := dialWithDeadline(ctx, net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to dial controller: %w", err)
+	}
+	defer controllerConn.Close()
+
+	err = controllerConn.CreateTopics(kafka.TopicConfig{
+		Topic:             p.topic,
+		NumPartitions:     p.config.TopicPartitions,
+		ReplicationFactor: p.config.TopicReplicationFactor,
+	})
+	if err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+		return fmt.Errorf("failed to create topic: %w", err)
+	}
+
+	// Новый топик может иметь другое число партиций
+	p.partitions.mu.Lock()
+	p.partitions.fetchedAt = time.Time{}
+	p.partitions.mu.Unlock()
+
+	p.logger.WithFields(logrus.Fields{
+		"topic":              p.topic,
+		"partitions":         p.config.TopicPartitions,
+		"replication_factor": p.config.TopicReplicationFactor,
+	}).Info("Kafka topic created")
+
+	return nil
+}
+
+// dialWithDeadline подключается к брокеру с deadline соединения из ctx.
+// Без него Controller и CreateTopics ждали бы зависший брокер бесконечно:
+// DialContext учитывает контекст только при установке соединения.
+func dialWithDeadline(ctx context.Context, address string) (*kafka.Conn, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set connection deadline: %w", err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"producer-service/internal/config"
+	"producer-service/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCreateTopicHangingBroker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	// Брокер принимает соединения и никогда не отвечает
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	producer := &Producer{
+		topic:  "events",
+		logger: logger,
+		config: config.KafkaConfig{
+			Brokers:      []string{listener.Addr().String()},
+			WriteTimeout: 200 * time.Millisecond,
+		},
+	}
+
+	start := time.Now()
+	if err := producer.createTopic(context.Background()); err == nil {
+		t.Fatal("createTopic succeeded against a broker that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("createTopic took %s, want it bounded by KAFKA_WRITE_TIMEOUT", elapsed)
+	}
+}
+
+// failingWriter отклоняет каждую запись ошибкой err и считает попытки
+type failingWriter struct {
+	err      error
+	attempts atomic.Int64
+}
+
+func (w *failingWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	w.attempts.Add(1)
+	return w.err
+}
+
+func (w *failingWriter) Close() error { return nil }
+
+func (w *failingWriter) Stats() kafka.WriterStats { return kafka.WriterStats{} }
+
+func TestProducerStopsRetryingWhenTopicMissing(t *testing.T) {
+	publishOne := func(p *Producer, events []*domain.Event) error { return p.Publish(context.Background(), events[0]) }
+	publishBatch := func(p *Producer, events []*domain.Event) error { return p.PublishBatch(context.Background(), events) }
+
+	tests := []struct {
+		name       string
+		err        error
+		autoCreate bool
+		publish    func(p *Producer, events []*domain.Event) error
+		// Попыток записи: без пересоздания топика повторов нет
+		wantAttempts int64
+		wantMetric   []string
+	}{
+		{name: "single event", err: kafka.UnknownTopicOrPartition, publish: publishOne, wantAttempts: 1, wantMetric: []string{topicMissingFailed}},
+		{
+			name: "batch with per-message errors", err: kafka.WriteErrors{nil, kafka.UnknownTopicOrPartition},
+			publish: publishBatch, wantAttempts: 1, wantMetric: []string{topicMissingFailed},
+		},
+		{
+			name: "recreate failed", err: kafka.UnknownTopicOrPartition, autoCreate: true,
+			publish: publishOne, wantAttempts: 1, wantMetric: []string{topicMissingRecreateFailed},
+		},
+		// Контрольный случай: обычная ошибка брокера повторяется
+		{name: "other error retried", err: kafka.LeaderNotAvailable, publish: publishOne, wantAttempts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &failingWriter{err: tt.err}
+			producer := newTestProducer(t, config.KafkaConfig{
+				MaxRetries: 3, RetryBackoff: time.Millisecond, WriteTimeout: time.Second, AutoCreateTopic: tt.autoCreate,
+			}, writer)
+			// Пересоздание топика упирается в недоступный брокер
+			producer.config.Brokers = []string{"127.0.0.1:1"}
+			recorder := newMetricsRecorder()
+			producer.metrics = recorder
+
+			err := tt.publish(producer, newTestEvents(t, 2))
+			if err == nil {
+				t.Fatal("publish succeeded with a failing writer")
+			}
+			if missing := tt.wantMetric != nil; errors.Is(err, domain.ErrTopicNotFound) != missing {
+				t.Fatalf("publish error = %v, want ErrTopicNotFound %v", err, missing)
+			}
+			if got := writer.attempts.Load(); got != tt.wantAttempts {
+				t.Fatalf("write attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			if !reflect.DeepEqual(recorder.topicMissing, tt.wantMetric) {
+				t.Fatalf("topic missing metric = %v, want %v", recorder.topicMissing, tt.wantMetric)
+			}
+		})
+	}
+}
@@ -9,19 +9,26 @@ import (
 type HTTPMetrics struct {
 	httpRequests *prometheus.CounterVec
 	httpDuration *prometheus.HistogramVec
+	httpActive   prometheus.Gauge
 }
 
-// NewHTTPMetrics создает новые HTTP метрики
-func NewHTTPMetrics() *HTTPMetrics {
+// NewHTTPMetrics создает новые HTTP метрики.
+// При reg == nil используется prometheus.DefaultRegisterer.
+func NewHTTPMetrics(reg prometheus.Registerer) *HTTPMetrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	factory := promauto.With(reg)
+
 	return &HTTPMetrics{
-		httpRequests: promauto.NewCounterVec(
+		httpRequests: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "http_requests_total",
 				Help: "Total number of HTTP requests",
 			},
 			[]string{"method", "endpoint", "status"},
 		),
-		httpDuration: promauto.NewHistogramVec(
+		httpDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "http_request_duration_seconds",
 				Help:    "Duration of HTTP requests",
@@ -29,6 +36,12 @@ func NewHTTPMetrics() *HTTPMetrics {
 			},
 			[]string{"method", "endpoint"},
 		),
+		httpActive: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_active_requests",
+				Help: "Number of API requests currently being processed",
+			},
+		),
 	}
 }
 
@@ -41,3 +54,13 @@ func (m *HTTPMetrics) IncHTTPRequests(method, endpoint, status string) {
 func (m *HTTPMetrics) ObserveHTTPDuration(method, endpoint string, duration float64) {
 	m.httpDuration.WithLabelValues(method, endpoint).Observe(duration)
 }
+
+// IncActiveRequests увеличивает число обрабатываемых запросов
+func (m *HTTPMetrics) IncActiveRequests() {
+	m.httpActive.Inc()
+}
+
+// DecActiveRequests уменьшает число обрабатываемых запросов
+func (m *HTTPMetrics) DecActiveRequests() {
+	m.httpActive.Dec()
+}
@@ -1,10 +1,12 @@
 package metrics
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/kafka-go"
 )
 
 // ProducerMetrics реализует интерфейс ProducerMetrics
@@ -12,39 +14,167 @@ type ProducerMetrics struct {
 	publishedEvents *prometheus.CounterVec
 	failedEvents    *prometheus.CounterVec
 	publishDuration *prometheus.HistogramVec
+	enqueueToSend   *prometheus.HistogramVec
+	batchSize       prometheus.Gauge
+	workerPanics    *prometheus.CounterVec
+	retryAttempts   *prometheus.CounterVec
+	writerTotals    *prometheus.CounterVec
+	writerStats     *prometheus.GaugeVec
+	topicMissing    *prometheus.CounterVec
+	fanoutPublish   *prometheus.CounterVec
+	spoolEvents     *prometheus.CounterVec
+
+	// publishSummary создается только при WithPublishSummary
+	publishSummary *prometheus.SummaryVec
 }
 
-// NewProducerMetrics создает новые метрики для producer
-func NewProducerMetrics() *ProducerMetrics {
-	return &ProducerMetrics{
-		publishedEvents: promauto.NewCounterVec(
+// ProducerMetricsOption настраивает необязательные метрики producer
+type ProducerMetricsOption func(m *ProducerMetrics, factory promauto.Factory)
+
+// WithPublishSummary добавляет summary producer_publish_latency_summary с заданными
+// квантилями (например, 0.5, 0.9, 0.99) для точных p99 на коротком пути публикации.
+// Квантили summary считаются на экземпляре и не агрегируются между репликами,
+// поэтому гистограмма остается основной метрикой.
+func WithPublishSummary(quantiles []float64) ProducerMetricsOption {
+	return func(m *ProducerMetrics, factory promauto.Factory) {
+		objectives := make(map[float64]float64, len(quantiles))
+		for _, q := range quantiles {
+			objectives[q] = (1 - q) / 10 // допустимая ошибка квантиля
+		}
+
+		m.publishSummary = factory.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name:       "producer_publish_latency_summary",
+				Help:       "Summary of event publishing latency in seconds",
+				Objectives: objectives,
+				MaxAge:     time.Minute,
+			},
+			[]string{"event_type"},
+		)
+	}
+}
+
+// NewProducerMetrics создает новые метрики для producer.
+// reg позволяет изолировать метрики в отдельном реестре, nil — глобальный реестр.
+func NewProducerMetrics(reg prometheus.Registerer, opts ...ProducerMetricsOption) *ProducerMetrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	factory := promauto.With(reg)
+
+	m := &ProducerMetrics{
+		publishedEvents: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "producer_events_published_total",
-				Help: "Total number of events published",
+				Help: "Total number of events published; delivery is unconfirmed when acks=0",
 			},
-			[]string{"event_type"},
+			[]string{"event_type", "delivery"},
 		),
-		failedEvents: promauto.NewCounterVec(
+		failedEvents: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "producer_events_failed_total",
 				Help: "Total number of failed events",
 			},
 			[]string{"event_type", "reason"},
 		),
-		publishDuration: promauto.NewHistogramVec(
+		publishDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "producer_publish_duration_seconds",
-				Help:    "Duration of event publishing",
+				Help:    "Duration of the Publish call; in async batching mode this covers only queuing, see producer_enqueue_to_send_seconds",
 				Buckets: prometheus.DefBuckets,
 			},
 			[]string{"event_type"},
 		),
+		enqueueToSend: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "producer_enqueue_to_send_seconds",
+				Help:    "Time from queuing an event for batching until its batch is written to Kafka",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			[]string{"event_type"},
+		),
+		batchSize: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "producer_effective_batch_size",
+				Help: "Current batch flush threshold of the producer",
+			},
+		),
+		workerPanics: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "producer_worker_panics_total",
+				Help: "Total number of recovered panics in producer workers",
+			},
+			[]string{"worker"},
+		),
+		retryAttempts: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "producer_publish_retry_attempts_total",
+				Help: "Total number of publish retry attempts",
+			},
+			[]string{"event_type", "attempt"},
+		),
+		writerTotals: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "producer_kafka_writer_total",
+				Help: "Kafka writer counters: writes, messages, bytes, errors, retries",
+			},
+			[]string{"stat"},
+		),
+		writerStats: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "producer_kafka_writer_stats",
+				Help: "Kafka writer timings (seconds), batch sizes and settings over the last collection interval",
+			},
+			[]string{"stat"},
+		),
+		topicMissing: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "producer_topic_missing_total",
+				Help: "Total number of writes that found the topic missing, by outcome: failed, recreated, recreate_failed",
+			},
+			[]string{"result"},
+		),
+		fanoutPublish: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "producer_fanout_publish_total",
+				Help: "Total number of fan-out publications per topic, by result: success, error",
+			},
+			[]string{"topic", "result"},
+		),
+		spoolEvents: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "producer_spool_events_total",
+				Help: "Total number of events handled by the local disk spool, by result: spooled, replayed, dropped",
+			},
+			[]string{"result"},
+		),
+	}
+
+	for _, opt := range opts {
+		opt(m, factory)
 	}
+	return m
+}
+
+// NewFanoutProducerMetrics создает метрики producer'а дополнительного fan-out топика.
+// Они регистрируются с префиксом fanout_ и меткой fanout_topic: общие producer_* метрики
+// продолжают считать одно логическое событие один раз, а gauge размера batch'а
+// основного producer'а не перезаписывается.
+func NewFanoutProducerMetrics(reg prometheus.Registerer, topic string, opts ...ProducerMetricsOption) *ProducerMetrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	reg = prometheus.WrapRegistererWithPrefix("fanout_", prometheus.WrapRegistererWith(prometheus.Labels{"fanout_topic": topic}, reg))
+	return NewProducerMetrics(reg, opts...)
 }
 
 // IncPublishedEvents увеличивает счетчик опубликованных событий
-func (m *ProducerMetrics) IncPublishedEvents(eventType string) {
-	m.publishedEvents.WithLabelValues(eventType).Inc()
+func (m *ProducerMetrics) IncPublishedEvents(eventType string, confirmed bool) {
+	delivery := "confirmed"
+	if !confirmed {
+		delivery = "unconfirmed"
+	}
+	m.publishedEvents.WithLabelValues(eventType, delivery).Inc()
 }
 
 // IncFailedEvents увеличивает счетчик неудачных событий
@@ -55,4 +185,86 @@ func (m *ProducerMetrics) IncFailedEvents(eventType string, reason string) {
 // ObservePublishDuration записывает время публикации события
 func (m *ProducerMetrics) ObservePublishDuration(eventType string, duration time.Duration) {
 	m.publishDuration.WithLabelValues(eventType).Observe(duration.Seconds())
+	if m.publishSummary != nil {
+		m.publishSummary.WithLabelValues(eventType).Observe(duration.Seconds())
+	}
+}
+
+// SetEffectiveBatchSize устанавливает текущий порог сброса batch'а
+func (m *ProducerMetrics) SetEffectiveBatchSize(size int) {
+	m.batchSize.Set(float64(size))
+}
+
+// ObserveEnqueueToSend записывает задержку от постановки события в очередь до записи его batch'а
+func (m *ProducerMetrics) ObserveEnqueueToSend(eventType string, latency time.Duration) {
+	m.enqueueToSend.WithLabelValues(eventType).Observe(latency.Seconds())
+}
+
+// IncWorkerPanics увеличивает счетчик перехваченных паник worker'а
+func (m *ProducerMetrics) IncWorkerPanics(worker string) {
+	m.workerPanics.WithLabelValues(worker).Inc()
+}
+
+// IncFanoutPublish увеличивает счетчик публикаций в топик fan-out
+func (m *ProducerMetrics) IncFanoutPublish(topic, result string) {
+	m.fanoutPublish.WithLabelValues(topic, result).Inc()
+}
+
+// IncSpoolEvents увеличивает счетчик событий локального спула
+func (m *ProducerMetrics) IncSpoolEvents(result string) {
+	m.spoolEvents.WithLabelValues(result).Inc()
+}
+
+// IncTopicMissing увеличивает счетчик записей в отсутствующий топик
+func (m *ProducerMetrics) IncTopicMissing(result string) {
+	m.topicMissing.WithLabelValues(result).Inc()
+}
+
+// IncRetryAttempts увеличивает счетчик повторных попыток публикации
+func (m *ProducerMetrics) IncRetryAttempts(eventType string, attempt int) {
+	m.retryAttempts.WithLabelValues(eventType, strconv.Itoa(attempt)).Inc()
+}
+
+// ObserveWriterStats переносит статистику kafka.Writer в метрики.
+// Счетчики в stats — приращения с прошлого вызова Writer.Stats, остальные поля — срез.
+func (m *ProducerMetrics) ObserveWriterStats(stats kafka.WriterStats) {
+	m.writerTotals.WithLabelValues("writes").Add(float64(stats.Writes))
+	m.writerTotals.WithLabelValues("messages").Add(float64(stats.Messages))
+	m.writerTotals.WithLabelValues("bytes").Add(float64(stats.Bytes))
+	m.writerTotals.WithLabelValues("errors").Add(float64(stats.Errors))
+	m.writerTotals.WithLabelValues("retries").Add(float64(stats.Retries))
+
+	m.setDurationStats("batch_time", stats.BatchTime)
+	m.setDurationStats("batch_queue_time", stats.BatchQueueTime)
+	m.setDurationStats("write_time", stats.WriteTime)
+	m.setDurationStats("wait_time", stats.WaitTime)
+	m.setSummaryStats("batch_size", stats.BatchSize)
+	m.setSummaryStats("batch_bytes", stats.BatchBytes)
+
+	m.writerStats.WithLabelValues("max_attempts").Set(float64(stats.MaxAttempts))
+	m.writerStats.WithLabelValues("max_batch_size").Set(float64(stats.MaxBatchSize))
+	m.writerStats.WithLabelValues("batch_timeout").Set(stats.BatchTimeout.Seconds())
+	m.writerStats.WithLabelValues("write_backoff_min").Set(stats.WriteBackoffMin.Seconds())
+	m.writerStats.WithLabelValues("write_backoff_max").Set(stats.WriteBackoffMax.Seconds())
+	m.writerStats.WithLabelValues("required_acks").Set(float64(stats.RequiredAcks))
+
+	async := 0.0
+	if stats.Async {
+		async = 1
+	}
+	m.writerStats.WithLabelValues("async").Set(async)
+}
+
+// setDurationStats выставляет avg/min/max длительности в секундах
+func (m *ProducerMetrics) setDurationStats(name string, stats kafka.DurationStats) {
+	m.writerStats.WithLabelValues(name + "_avg").Set(stats.Avg.Seconds())
+	m.writerStats.WithLabelValues(name + "_min").Set(stats.Min.Seconds())
+	m.writerStats.WithLabelValues(name + "_max").Set(stats.Max.Seconds())
+}
+
+// setSummaryStats выставляет avg/min/max сводной статистики
+func (m *ProducerMetrics) setSummaryStats(name string, stats kafka.SummaryStats) {
+	m.writerStats.WithLabelValues(name + "_avg").Set(float64(stats.Avg))
+	m.writerStats.WithLabelValues(name + "_min").Set(float64(stats.Min))
+	m.writerStats.WithLabelValues(name + "_max").Set(float64(stats.Max))
 }
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatherValue возвращает значение серии метрики name с метками labels
+func gatherValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			pairs := metric.GetLabel()
+			if len(pairs) != len(labels) {
+				continue
+			}
+			for _, pair := range pairs {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			if counter := metric.GetCounter(); counter != nil {
+				return counter.GetValue()
+			}
+			return metric.GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("series %s%v not found", name, labels)
+	return 0
+}
+
+func TestFanoutProducerMetricsAreSeparate(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	primary := NewProducerMetrics(reg)
+	orders := NewFanoutProducerMetrics(reg, "orders")
+	audit := NewFanoutProducerMetrics(reg, "audit")
+
+	primary.IncPublishedEvents("user_created", true)
+	orders.IncPublishedEvents("user_created", true)
+	audit.IncPublishedEvents("user_created", true)
+
+	published := map[string]string{"event_type": "user_created", "delivery": "confirmed"}
+	if got := gatherValue(t, reg, "producer_events_published_total", published); got != 1 {
+		t.Fatalf("producer_events_published_total = %v, want 1 per logical event", got)
+	}
+	published["fanout_topic"] = "orders"
+	if got := gatherValue(t, reg, "fanout_producer_events_published_total", published); got != 1 {
+		t.Fatalf("fanout_producer_events_published_total{fanout_topic=orders} = %v, want 1", got)
+	}
+
+	primary.SetEffectiveBatchSize(100)
+	orders.SetEffectiveBatchSize(10)
+	if got := gatherValue(t, reg, "producer_effective_batch_size", map[string]string{}); got != 100 {
+		t.Fatalf("producer_effective_batch_size = %v, want 100 after a fan-out update", got)
+	}
+}
+
+func TestMetricsOnSeparateRegistries(t *testing.T) {
+	tests := []struct {
+		name string
+		// build создает набор метрик в reg и возвращает функцию инкремента счетчика
+		build  func(reg *prometheus.Registry) func()
+		metric string
+		labels map[string]string
+	}{
+		{
+			name: "producer metrics",
+			build: func(reg *prometheus.Registry) func() {
+				m := NewProducerMetrics(reg)
+				return func() { m.IncFailedEvents("user_created", "timeout") }
+			},
+			metric: "producer_events_failed_total",
+			labels: map[string]string{"event_type": "user_created", "reason": "timeout"},
+		},
+		{
+			name: "http metrics",
+			build: func(reg *prometheus.Registry) func() {
+				m := NewHTTPMetrics(reg)
+				return func() { m.IncHTTPRequests("POST", "/events/user", "202") }
+			},
+			metric: "http_requests_total",
+			labels: map[string]string{"method": "POST", "endpoint": "/events/user", "status": "202"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Второй набор метрик с теми же именами не паникует на duplicate registration
+			first, second := prometheus.NewRegistry(), prometheus.NewRegistry()
+			incFirst, incSecond := tt.build(first), tt.build(second)
+			incFirst()
+			incSecond()
+			incSecond()
+
+			if got := gatherValue(t, first, tt.metric, tt.labels); got != 1 {
+				t.Fatalf("%s in first registry = %v, want 1", tt.metric, got)
+			}
+			if got := gatherValue(t, second, tt.metric, tt.labels); got != 2 {
+				t.Fatalf("%s in second registry = %v, want 2", tt.metric, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"producer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrAsyncClosed возвращается при попытке поставить событие после остановки пула
+var ErrAsyncClosed = errors.New("async publisher is closed")
+
+// asyncJob событие, ожидающее обогащения и публикации
+type asyncJob struct {
+	ctx    context.Context
+	event  *domain.Event
+	queued time.Time
+}
+
+// AsyncEventService публикует события через ограниченный пул worker'ов.
+// Медленное обогащение выполняется вне HTTP обработчика: клиент сразу
+// получает ID события и может запросить его статус.
+type AsyncEventService struct {
+	service *EventService
+	logger  *logrus.Logger
+	jobs    chan asyncJob
+	workers int
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	statuses *statusStore
+}
+
+// NewAsyncEventService создает пул асинхронной публикации поверх EventService
+func NewAsyncEventService(service *EventService, workers, queueSize, statusCapacity int, logger *logrus.Logger) *AsyncEventService {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = workers
+	}
+
+	return &AsyncEventService{
+		service:  service,
+		logger:   logger,
+		jobs:     make(chan asyncJob, queueSize),
+		workers:  workers,
+		statuses: newStatusStore(statusCapacity),
+	}
+}
+
+// Start запускает worker'ы пула
+func (s *AsyncEventService) Start() {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"workers":    s.workers,
+		"queue_size": cap(s.jobs),
+	}).Info("Async event publisher started")
+}
+
+// Submit создает событие и ставит его в очередь на публикацию.
+// Возвращает domain.ErrQueueFull, если очередь заполнена (backpressure).
+func (s *AsyncEventService) Submit(ctx context.Context, eventType domain.EventType, data string) (*domain.Event, error) {
+	event, err := s.service.factory.NewEvent(eventType, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrAsyncClosed
+	}
+
+	// Контекст запроса отменяется после ответа клиенту, значения (correlation ID) сохраняем
+	job := asyncJob{ctx: context.WithoutCancel(ctx), event: event, queued: time.Now()}
+
+	// Статус фиксируем до постановки в очередь, чтобы worker не обогнал его
+	s.statuses.set(event, domain.StatusPending, nil)
+
+	select {
+	case s.jobs <- job:
+		return event, nil
+	default:
+		s.statuses.remove(event.ID)
+		return nil, domain.ErrQueueFull
+	}
+}
+
+// SubmitUserEvent ставит в очередь событие создания пользователя
+func (s *AsyncEventService) SubmitUserEvent(ctx context.Context, data string) (*domain.Event, error) {
+	return s.Submit(ctx, domain.UserCreatedEvent, data)
+}
+
+// Status возвращает статус события по ID
+func (s *AsyncEventService) Status(eventID string) (*domain.EventStatus, bool) {
+	return s.statuses.get(eventID)
+}
+
+// Close прекращает прием событий и дожидается публикации поставленных в очередь
+func (s *AsyncEventService) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.jobs)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("Async event publisher stopped")
+}
+
+// Check сообщает о почти заполненной очереди (advisory проверка готовности)
+func (s *AsyncEventService) Check(ctx context.Context) error {
+	if queued, capacity := len(s.jobs), cap(s.jobs); queued*10 >= capacity*9 {
+		return fmt.Errorf("async queue is %d/%d full", queued, capacity)
+	}
+	return nil
+}
+
+// worker обогащает и публикует события из очереди
+func (s *AsyncEventService) worker() {
+	defer s.wg.Done()
+
+	for job := range s.jobs {
+		err := s.service.publish(job.ctx, job.event, job.queued)
+		if err != nil {
+			s.statuses.set(job.event, domain.StatusFailed, err)
+			continue
+		}
+		s.statuses.set(job.event, domain.StatusPublished, nil)
+	}
+}
+
+// statusStore хранит статусы последних событий, вытесняя самые старые
+type statusStore struct {
+	mu       sync.RWMutex
+	capacity int
+	order    []string
+	items    map[string]*domain.EventStatus
+}
+
+// newStatusStore создает хранилище статусов заданной емкости
+func newStatusStore(capacity int) *statusStore {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &statusStore{
+		capacity: capacity,
+		order:    make([]string, 0, capacity),
+		items:    make(map[string]*domain.EventStatus, capacity),
+	}
+}
+
+// set обновляет статус события
+func (st *statusStore) set(event *domain.Event, status string, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	item, ok := st.items[event.ID]
+	if !ok {
+		if len(st.order) >= st.capacity {
+			delete(st.items, st.order[0])
+			st.order = st.order[1:]
+		}
+		item = &domain.EventStatus{EventID: event.ID, EventType: event.Type}
+		st.items[event.ID] = item
+		st.order = append(st.order, event.ID)
+	}
+
+	item.Status = status
+	item.Error = ""
+	if err != nil {
+		item.Error = err.Error()
+	}
+	item.UpdatedAt = time.Now().UTC()
+}
+
+// remove удаляет статус события
+func (st *statusStore) remove(eventID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if _, ok := st.items[eventID]; !ok {
+		return
+	}
+	delete(st.items, eventID)
+
+	for i, id := range st.order {
+		if id == eventID {
+			st.order = append(st.order[:i], st.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// get возвращает копию статуса события
+func (st *statusStore) get(eventID string) (*domain.EventStatus, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	item, ok := st.items[eventID]
+	if !ok {
+		return nil, false
+	}
+
+	status := *item
+	return &status, true
+}
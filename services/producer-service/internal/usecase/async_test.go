@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"producer-service/internal/domain"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stubPublisher завершает каждую публикацию ошибкой err
+type stubPublisher struct {
+	err error
+}
+
+func (p *stubPublisher) Publish(context.Context, *domain.Event) error { return p.err }
+
+func (p *stubPublisher) Close() error { return nil }
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestAsyncEventServiceStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		publishErr error
+		closed     bool
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "published", wantStatus: domain.StatusPublished},
+		{name: "publish failed", publishErr: errors.New("kafka unavailable"), wantStatus: domain.StatusFailed},
+		{name: "submit after close", closed: true, wantErr: ErrAsyncClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewEventService(&stubPublisher{err: tt.publishErr}, nil, discardLogger())
+			async := NewAsyncEventService(service, 1, 1, 8, discardLogger())
+			async.Start()
+			if tt.closed {
+				async.Close()
+			}
+
+			event, err := async.SubmitUserEvent(context.Background(), `{"id":1}`)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SubmitUserEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			// Close дожидается публикации поставленных в очередь событий
+			async.Close()
+			status, ok := async.Status(event.ID)
+			if !ok || status.Status != tt.wantStatus {
+				t.Fatalf("Status(%s) = %+v, want %s", event.ID, status, tt.wantStatus)
+			}
+			if (status.Error != "") != (tt.publishErr != nil) {
+				t.Fatalf("status error = %q, want it set only on failure", status.Error)
+			}
+		})
+	}
+}
+
+func TestStatusStoreEvictsOldest(t *testing.T) {
+	store := newStatusStore(2)
+	events := make([]*domain.Event, 3)
+	for i := range events {
+		event, err := domain.NewEvent(domain.UserCreatedEvent, `{"id":1}`)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		events[i] = event
+		store.set(event, domain.StatusPending, nil)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "oldest evicted", id: events[0].ID, want: false},
+		{name: "recent kept", id: events[1].ID, want: true},
+		{name: "newest kept", id: events[2].ID, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := store.get(tt.id); ok != tt.want {
+				t.Fatalf("get(%s) found = %v, want %v", tt.id, ok, tt.want)
+			}
+		})
+	}
+}
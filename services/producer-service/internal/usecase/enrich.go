@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+
+	"producer-service/internal/domain"
+)
+
+// EnrichFunc дополняет событие стандартными атрибутами перед публикацией
+type EnrichFunc func(ctx context.Context, event *domain.Event)
+
+// SourceEnricher устанавливает источник события
+func SourceEnricher(source string) EnrichFunc {
+	return func(_ context.Context, event *domain.Event) {
+		event.Source = source
+	}
+}
+
+// ServiceInfoEnricher добавляет версию сервиса и окружение в метаданные
+func ServiceInfoEnricher(version, environment string) EnrichFunc {
+	return func(_ context.Context, event *domain.Event) {
+		event.SetMetadata("service_version", version)
+		event.SetMetadata("environment", environment)
+	}
+}
+
+// CorrelationIDEnricher переносит correlation ID из контекста в метаданные
+func CorrelationIDEnricher() EnrichFunc {
+	return func(ctx context.Context, event *domain.Event) {
+		if id, ok := domain.CorrelationIDFromContext(ctx); ok {
+			event.SetMetadata(domain.MetadataCorrelationID, id)
+		}
+	}
+}
+
+// TenantEnricher переносит идентификатор tenant'а из контекста в метаданные
+func TenantEnricher() EnrichFunc {
+	return func(ctx context.Context, event *domain.Event) {
+		if id, ok := domain.TenantFromContext(ctx); ok {
+			event.SetMetadata(domain.MetadataTenantID, id)
+		}
+	}
+}
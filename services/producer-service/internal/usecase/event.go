@@ -15,7 +15,9 @@ import (
 // EventService реализует интерфейс domain.EventService
 type EventService struct {
 	publisher domain.EventPublisher
+	factory   *domain.EventFactory
 	logger    domain.Logger
+	enrichers []EnrichFunc
 	stats     *EventServiceStats
 	mu        sync.RWMutex
 }
@@ -28,11 +30,18 @@ type EventServiceStats struct {
 	LastEventTime *time.Time       `json:"last_event_time,omitempty"`
 }
 
-// NewEventService создает новый EventService
-func NewEventService(publisher domain.EventPublisher, logger *logrus.Logger) *EventService {
+// NewEventService создает новый EventService.
+// factory задает генерацию ID событий; nil — генератор по умолчанию.
+func NewEventService(publisher domain.EventPublisher, factory *domain.EventFactory, logger *logrus.Logger, enrichers ...EnrichFunc) *EventService {
+	if factory == nil {
+		factory = domain.NewEventFactory(nil)
+	}
+
 	return &EventService{
 		publisher: publisher,
+		factory:   factory,
 		logger:    &logrusAdapter{logger: logger},
+		enrichers: enrichers,
 		stats: &EventServiceStats{
 			EventsByType: make(map[string]int64),
 		},
@@ -44,7 +53,7 @@ func (s *EventService) CreateAndPublish(ctx context.Context, eventType domain.Ev
 	start := time.Now()
 
 	// Создаем событие
-	event, err := domain.NewEvent(eventType, data)
+	event, err := s.factory.NewEvent(eventType, data)
 	if err != nil {
 		s.incrementErrorCount()
 		s.logger.Error("Failed to create event",
@@ -53,6 +62,55 @@ func (s *EventService) CreateAndPublish(ctx context.Context, eventType domain.Ev
 		return nil, fmt.Errorf("failed to create event: %w", err)
 	}
 
+	if err := s.publish(ctx, event, start); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// publish обогащает, проверяет и публикует уже созданное событие
+func (s *EventService) publish(ctx context.Context, event *domain.Event, start time.Time) error {
+	// Обогащаем событие стандартными атрибутами
+	if len(s.enrichers) > 0 {
+		for _, enrich := range s.enrichers {
+			enrich(ctx, event)
+		}
+
+		if err := event.ValidateAt(s.factory.Now()); err != nil {
+			s.incrementErrorCount()
+			s.logger.Error("Enriched event is invalid",
+				"event_id", event.ID,
+				"event_type", event.Type,
+				"error", err)
+			return fmt.Errorf("enriched event is invalid: %w", err)
+		}
+	}
+
+	return s.send(ctx, event, start)
+}
+
+// PublishExisting публикует готовое событие, сохраняя его ID, timestamp
+// и атрибуты (без обогащения). Используется для replay и миграций.
+func (s *EventService) PublishExisting(ctx context.Context, event *domain.Event) error {
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	if err := event.ValidateAt(s.factory.Now()); err != nil {
+		s.incrementErrorCount()
+		s.logger.Error("Existing event is invalid",
+			"event_id", event.ID,
+			"event_type", event.Type,
+			"error", err)
+		return fmt.Errorf("existing event is invalid: %w", err)
+	}
+
+	return s.send(ctx, event, time.Now())
+}
+
+// send публикует событие и обновляет статистику
+func (s *EventService) send(ctx context.Context, event *domain.Event, start time.Time) error {
 	// Публикуем событие
 	if err := s.publisher.Publish(ctx, event); err != nil {
 		s.incrementErrorCount()
@@ -60,7 +118,7 @@ func (s *EventService) CreateAndPublish(ctx context.Context, eventType domain.Ev
 			"event_id", event.ID,
 			"event_type", event.Type,
 			"error", err)
-		return nil, fmt.Errorf("failed to publish event: %w", err)
+		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
 	// Обновляем статистику
@@ -71,7 +129,7 @@ func (s *EventService) CreateAndPublish(ctx context.Context, eventType domain.Ev
 		"event_type", event.Type,
 		"duration", time.Since(start))
 
-	return event, nil
+	return nil
 }
 
 // CreateAndPublishJSON создает и публикует событие из JSON данных
@@ -114,6 +172,17 @@ func (s *EventService) GetEventStats(ctx context.Context) (*domain.EventStats, e
 	}, nil
 }
 
+// ResetEventStats обнуляет статистику (для сравнимых прогонов нагрузочных тестов)
+func (s *EventService) ResetEventStats(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = &EventServiceStats{
+		EventsByType: make(map[string]int64),
+	}
+	return nil
+}
+
 // CreateUserEvent создает событие создания пользователя
 func (s *EventService) CreateUserEvent(ctx context.Context, data string) (*domain.Event, error) {
 	return s.CreateAndPublish(ctx, domain.UserCreatedEvent, data)
@@ -166,10 +235,21 @@ func (l *logrusAdapter) WithFields(fields map[string]interface{}) domain.Logger
 	return &logrusAdapter{logger: l.logger.WithFields(fields).Logger}
 }
 
+// extraFieldKey ключ для значения без пары в списке полей
+const extraFieldKey = "!EXTRA"
+
+// fieldsToLogrus разбирает поля парами ключ-значение. Пара с нестроковым ключом
+// пропускается целиком, чтобы не сдвигать разбор остальных пар; значение без
+// пары сохраняется под ключом !EXTRA.
 func (l *logrusAdapter) fieldsToLogrus(fields ...interface{}) logrus.Fields {
-	logrusFields := make(logrus.Fields)
-	for i := 0; i < len(fields)-1; i += 2 {
-		if key, ok := fields[i].(string); ok && i+1 < len(fields) {
+	logrusFields := make(logrus.Fields, (len(fields)+1)/2)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			logrusFields[extraFieldKey] = fields[i]
+			break
+		}
+
+		if key, ok := fields[i].(string); ok {
 			logrusFields[key] = fields[i+1]
 		}
 	}
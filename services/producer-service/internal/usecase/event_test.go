@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"producer-service/internal/domain"
+)
+
+func TestEventServiceStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		published   int
+		failed      int
+		reset       bool
+		wantTotal   int64
+		wantErrors  int64
+		// wantSuccess проверяется, когда в статистике нет ошибок
+		wantSuccess float64
+	}{
+		{name: "published events", published: 4, wantTotal: 4, wantSuccess: 100},
+		{name: "publish failures", published: 3, failed: 1, wantTotal: 3, wantErrors: 1},
+		{name: "reset zeroes counters", published: 3, failed: 2, reset: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &stubPublisher{}
+			service := NewEventService(publisher, nil, discardLogger())
+			for i := 0; i < tt.published; i++ {
+				if _, err := service.CreateUserEvent(context.Background(), `{"id":1}`); err != nil {
+					t.Fatalf("CreateUserEvent: %v", err)
+				}
+			}
+			publisher.err = errors.New("kafka unavailable")
+			for i := 0; i < tt.failed; i++ {
+				if _, err := service.CreateUserEvent(context.Background(), `{"id":1}`); err == nil {
+					t.Fatal("CreateUserEvent succeeded with a failing publisher")
+				}
+			}
+			if tt.reset {
+				if err := service.ResetEventStats(context.Background()); err != nil {
+					t.Fatalf("ResetEventStats: %v", err)
+				}
+			}
+
+			stats, err := service.GetEventStats(context.Background())
+			if err != nil {
+				t.Fatalf("GetEventStats: %v", err)
+			}
+			if stats.TotalEvents != tt.wantTotal || stats.ErrorCount != tt.wantErrors {
+				t.Fatalf("stats = %+v, want %d total and %d errors", stats, tt.wantTotal, tt.wantErrors)
+			}
+			if got := stats.EventsByType[string(domain.UserCreatedEvent)]; got != tt.wantTotal {
+				t.Fatalf("user_created events = %d, want %d", got, tt.wantTotal)
+			}
+			if tt.wantErrors == 0 && stats.SuccessRate != tt.wantSuccess {
+				t.Fatalf("success rate = %v, want %v", stats.SuccessRate, tt.wantSuccess)
+			}
+			if (stats.LastEventTime == nil) != (tt.wantTotal == 0) {
+				t.Fatalf("last event time = %v, want it set only after published events", stats.LastEventTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"producer-service/internal/domain"
+)
+
+// healthCheck зарегистрированная проверка компонента
+type healthCheck struct {
+	name     string
+	checker  domain.HealthChecker
+	critical bool
+}
+
+// HealthRegistry объединяет проверки компонентов для /ready.
+// Некритичные (advisory) проверки отражаются в ответе, но не влияют на готовность.
+type HealthRegistry struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  []healthCheck
+}
+
+// NewHealthRegistry создает реестр проверок с общим бюджетом времени:
+// проверки идут параллельно, и Check возвращается не позже timeout,
+// даже если отдельная проверка (например, метаданные Kafka) зависла
+func NewHealthRegistry(timeout time.Duration) *HealthRegistry {
+	if timeout <= 0 {
+		timeout = 2 * time.Second // default check timeout
+	}
+
+	return &HealthRegistry{timeout: timeout}
+}
+
+// Register добавляет проверку компонента
+func (r *HealthRegistry) Register(name string, checker domain.HealthChecker, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, healthCheck{name: name, checker: checker, critical: critical})
+}
+
+// Check выполняет все проверки параллельно в пределах бюджета
+func (r *HealthRegistry) Check(ctx context.Context) domain.HealthReport {
+	r.mu.RLock()
+	checks := make([]healthCheck, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	results := make([]domain.ComponentHealth, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := domain.HealthReport{
+		Ready:      true,
+		Components: make(map[string]domain.ComponentHealth, len(checks)),
+	}
+	for i, check := range checks {
+		report.Components[check.name] = results[i]
+		if check.critical && results[i].Status != domain.HealthStatusOK {
+			report.Ready = false
+			report.TimedOut = report.TimedOut || results[i].Status == domain.HealthStatusTimeout
+		}
+	}
+
+	return report
+}
+
+// runCheck выполняет одну проверку, не дожидаясь ее дольше ctx
+func runCheck(ctx context.Context, check healthCheck) domain.ComponentHealth {
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := domain.ComponentHealth{
+		Status:   domain.HealthStatusOK,
+		Critical: check.critical,
+		Duration: time.Since(start).String(),
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		result.Status = domain.HealthStatusTimeout
+		result.Error = err.Error()
+	default:
+		result.Status = domain.HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}